@@ -0,0 +1,185 @@
+package cache
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+
+	"github.com/nishanth-gowda/kv-store/wal"
+)
+
+const (
+	// DefaultBloomErrorRate and DefaultBloomCapacity size the Bloom filters
+	// created by BFAdd on a missing key
+	DefaultBloomErrorRate = 0.01
+	DefaultBloomCapacity  = 1000
+
+	// MaxBloomBits caps the size of a reserved Bloom filter at 256 MiB
+	MaxBloomBits = 1 << 31
+)
+
+var (
+	// ErrExists is returned by BFReserve when the key already exists
+	ErrExists = errors.New("key already exists")
+
+	// ErrInvalidBloomFilter is returned by BFReserve for an error rate
+	// outside (0, 1), a capacity below 1, or a filter over MaxBloomBits
+	ErrInvalidBloomFilter = errors.New("error rate must be between 0 and 1, capacity must be positive and the filter must fit in 256 MiB")
+)
+
+// BloomFilter is the value stored under a Bloom filter key. Membership tests
+// have no false negatives and a false positive rate near the one it was
+// reserved with until more than its capacity of elements is added.
+// Get returns a copy, so modifying it does not change the cache.
+type BloomFilter struct {
+	Bits   []uint64
+	Hashes uint32
+}
+
+func init() {
+	// Snapshots and BFReserve store whole Bloom filters as SET values
+	gob.Register(&BloomFilter{})
+}
+
+// bloomBits returns the number of bits for capacity elements at errorRate
+func bloomBits(errorRate float64, capacity int) float64 {
+	return math.Ceil(-float64(capacity) * math.Log(errorRate) / (math.Ln2 * math.Ln2))
+}
+
+// newBloomFilter sizes a filter for capacity elements at errorRate
+func newBloomFilter(errorRate float64, capacity int) *BloomFilter {
+	m := bloomBits(errorRate, capacity)
+	words := int(math.Ceil(m / 64))
+	hashes := math.Round(float64(words*64) / float64(capacity) * math.Ln2)
+	return &BloomFilter{
+		Bits:   make([]uint64, words),
+		Hashes: uint32(max(hashes, 1)),
+	}
+}
+
+// locations calls fn with each bit index of element, using double hashing
+// to derive every hash from one 64-bit hash
+func (bf *BloomFilter) locations(element string, fn func(bit uint64)) {
+	h1 := hashString(element)
+	h2 := bits.RotateLeft64(h1, 32) | 1
+	m := uint64(len(bf.Bits)) * 64
+	for i := uint64(0); i < uint64(bf.Hashes); i++ {
+		fn((h1 + i*h2) % m)
+	}
+}
+
+// add sets the bits of element and reports whether any was unset
+func (bf *BloomFilter) add(element string) bool {
+	added := false
+	bf.locations(element, func(bit uint64) {
+		word, mask := bit/64, uint64(1)<<(bit%64)
+		if bf.Bits[word]&mask == 0 {
+			bf.Bits[word] |= mask
+			added = true
+		}
+	})
+	return added
+}
+
+// contains reports whether element may have been added
+func (bf *BloomFilter) contains(element string) bool {
+	found := true
+	bf.locations(element, func(bit uint64) {
+		if bf.Bits[bit/64]&(1<<(bit%64)) == 0 {
+			found = false
+		}
+	})
+	return found
+}
+
+func (bf *BloomFilter) clone() *BloomFilter {
+	return &BloomFilter{Bits: append([]uint64(nil), bf.Bits...), Hashes: bf.Hashes}
+}
+
+// BFReserve creates an empty Bloom filter under key sized for capacity
+// elements with the given false positive rate
+func (cache *LRUCache) BFReserve(key string, errorRate float64, capacity int) error {
+	if !(errorRate > 0 && errorRate < 1) || capacity < 1 || bloomBits(errorRate, capacity) > MaxBloomBits {
+		return ErrInvalidBloomFilter
+	}
+
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	if _, ok := cache.lookup(key); ok {
+		return ErrExists
+	}
+	return cache.replace(key, nil, newBloomFilter(errorRate, capacity))
+}
+
+// BFAdd adds element to the Bloom filter stored under key, creating one with
+// the default error rate and capacity if needed. It reports whether the
+// element was added, false meaning it may already have been present.
+func (cache *LRUCache) BFAdd(key, element string) (bool, error) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	entry, bf, err := lookupAs[*BloomFilter](cache, key)
+	if err != nil {
+		return false, err
+	}
+	if entry != nil && bf.contains(element) {
+		return false, nil
+	}
+
+	exp, err := cache.updateExpiry(entry)
+	if err != nil {
+		return false, err
+	}
+	if err := cache.appendOp(wal.EntryTypeBFADD, key, []string{element}, exp.expiresAtUnixNano()); err != nil {
+		return false, err
+	}
+	cache.applyBFAdd(key, []string{element}, exp)
+	cache.notify(EventSet, key, nil)
+	return true, nil
+}
+
+// BFExists reports whether element may have been added to the Bloom filter
+// stored under key. False positives are possible; false negatives are not.
+func (cache *LRUCache) BFExists(key, element string) (bool, error) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	entry, bf, err := lookupAs[*BloomFilter](cache, key)
+	if err != nil || entry == nil {
+		return false, err
+	}
+	cache.evictList.MoveToFront(entry.element)
+	return bf.contains(element), nil
+}
+
+// applyBFAdd adds elements to the Bloom filter under key, creating a default
+// one with exp if it does not exist. The caller must hold cache.mu.
+func (cache *LRUCache) applyBFAdd(key string, elements []string, exp expiry) {
+	entry, ok := cache.entries[key]
+	var bf *BloomFilter
+	if ok {
+		bf, ok = entry.value.(*BloomFilter)
+	}
+	if !ok {
+		bf = newBloomFilter(DefaultBloomErrorRate, DefaultBloomCapacity)
+		entry = cache.store(key, bf, exp, len(key)+8*len(bf.Bits))
+	}
+
+	for _, element := range elements {
+		bf.add(element)
+	}
+	cache.evictList.MoveToFront(entry.element)
+}
+
+// replayBloomFilter applies a BFADD read from the WAL during recovery
+func (cache *LRUCache) replayBloomFilter(entry *wal.WAL_Entry, exp expiry) error {
+	var elements []string
+	if err := decodeOp(entry.Value, &elements); err != nil {
+		return fmt.Errorf("failed to decode Bloom filter elements: %w", err)
+	}
+	cache.applyBFAdd(entry.Key, elements, exp)
+	return nil
+}
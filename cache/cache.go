@@ -3,8 +3,14 @@ package cache
 import (
 	"bytes"
 	"container/list"
+	"context"
 	"encoding/gob"
+	"errors"
 	"fmt"
+	"log"
+	"reflect"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -13,31 +19,128 @@ import (
 
 type CacheItem struct {
 	value     any
-	TTL       time.Duration
 	element   *list.Element
 	createdAt time.Time
+	size      int // key plus serialized value, in bytes
+	expiry
+	// logged is the deadline last written to the WAL, which reads of a key
+	// with sliding expiration move ahead of deadline
+	logged time.Time
 }
 
+// EvictionPolicy selects which entry makes room for a new key in a full cache
+type EvictionPolicy string
+
+const (
+	// EvictLRU evicts the least recently used entry
+	EvictLRU EvictionPolicy = "lru"
+	// EvictRandom evicts an arbitrary entry, avoiding the cost of keeping
+	// recency order meaningful for workloads without locality
+	EvictRandom EvictionPolicy = "random"
+	// EvictNone never evicts; writes that would add a key fail with ErrFull
+	EvictNone EvictionPolicy = "noeviction"
+)
+
+// ErrFull is returned when adding a key to a full cache whose eviction
+// policy is EvictNone
+var ErrFull = errors.New("cache is full and its eviction policy is noeviction")
+
+// ParseEvictionPolicy returns the policy named s
+func ParseEvictionPolicy(s string) (EvictionPolicy, error) {
+	switch policy := EvictionPolicy(s); policy {
+	case EvictLRU, EvictRandom, EvictNone:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown eviction policy %q, want lru, random or noeviction", s)
+}
+
+// NoExpiration can be passed as the ttl to Set to store a key without
+// expiration even when the cache has a default TTL
+const NoExpiration time.Duration = -1
+
 type LRUCache struct {
-	mu        sync.RWMutex
-	entries   map[string]*CacheItem
-	evictList *list.List
-	capacity  int
-	wal       *wal.WAL
+	mu         sync.RWMutex
+	entries    map[string]*CacheItem
+	evictList  *list.List
+	capacity   int
+	policy     EvictionPolicy
+	defaultTTL time.Duration
+	clock      Clock
+	bytes      int64
+	wal        *wal.WAL
+	walOptions []wal.Option
+	walStorage wal.Storage
+	metrics    *cacheMetrics
+
+	// statsBaseline holds the counters as of the last ResetStats, which
+	// Stats subtracts
+	statsBaseline statsCounters
+
+	// slidingGranularity is how far reads extend a sliding expiration
+	// before the extension is logged
+	slidingGranularity time.Duration
+
+	// compressionThreshold is the size from which string and []byte values
+	// are kept compressed in memory; 0 disables compression
+	compressionThreshold int
+
+	// revision is the sequence number of the last change event, and epoch
+	// tells the revisions of this cache from those of one opened earlier
+	revision uint64
+	epoch    string
+	history  []Event
+	watchers map[*Watcher]struct{}
+
+	// pushed is closed and replaced whenever elements are pushed to a list
+	// or added to a stream, waking blocked reads
+	pushed chan struct{}
+	closed bool
+
+	// loader fills misses read through GetContext; loads holds the loads in
+	// progress and loadErrors the failures cached by WithErrorTTL
+	loader        Loader
+	loaderOptions loaderOptions
+	loads         map[string]*loadCall
+	loadErrors    map[string]loadError
+
+	// writer receives Set, CompareAndSwap and Delete; writeLocks keep writes
+	// to the same key in the same order in the cache and the backing store
+	writer      Writer
+	writeBehind *writeBehind
+	writeLocks  [writeLockStripes]sync.Mutex
+
+	// onRemoval is called with the removals collected while cache.mu is
+	// held once it is released by unlock
+	onRemoval func(key string, value any, reason RemovalReason)
+	removals  []removal
 }
 
 // NewLRUCache creates a new LRU cache with optional WAL support
-// If walDirectory is empty, WAL is disabled
-func NewLRUCache(capacity int, walDirectory string, forceSync bool, maxFileSize int, maxSegments int) (*LRUCache, error) {
+// If walDirectory is empty and WithWALStorage is not given, WAL is disabled
+func NewLRUCache(capacity int, walDirectory string, forceSync bool, maxFileSize int, maxSegments int, opts ...Option) (*LRUCache, error) {
 	cache := &LRUCache{
 		entries:   make(map[string]*CacheItem),
 		evictList: list.New(),
 		capacity:  capacity,
+		policy:    EvictLRU,
+		clock:     systemClock{},
+		// The wall clock, since a fake clock may start at the same time again
+		epoch: strconv.FormatInt(time.Now().UnixNano(), 36),
+
+		slidingGranularity: DefaultSlidingGranularity,
+	}
+	for _, opt := range opts {
+		opt(cache)
 	}
+	cache.metrics = newCacheMetrics(cache)
 
-	// Initialize WAL if directory is provided
-	if walDirectory != "" {
-		walInstance, err := wal.NewWal(walDirectory, forceSync, maxFileSize, maxSegments)
+	// Initialize WAL if directory or storage is provided
+	if walDirectory != "" || cache.walStorage != nil {
+		walOptions := cache.walOptions
+		if cache.walStorage != nil {
+			walOptions = append(walOptions, wal.WithStorage(cache.walStorage))
+		}
+		walInstance, err := wal.NewWal(walDirectory, forceSync, maxFileSize, maxSegments, walOptions...)
 		if err != nil {
 			return nil, fmt.Errorf("failed to initialize WAL: %w", err)
 		}
@@ -47,14 +150,64 @@ func NewLRUCache(capacity int, walDirectory string, forceSync bool, maxFileSize
 		if err := cache.recoverFromWAL(); err != nil {
 			return nil, fmt.Errorf("failed to recover from WAL: %w", err)
 		}
+		go cache.compactLoop(walInstance.SnapshotNeeded())
 	}
 
 	return cache, nil
 }
 
-func (cache *LRUCache) Set(key string, value any, ttl time.Duration) error {
-	cache.mu.Lock()
-	defer cache.mu.Unlock()
+// WithEncryption encrypts the records the cache writes to its WAL with the
+// active key of keys, which must also hold the keys of existing records
+func WithEncryption(keys *wal.KeyRing) Option {
+	return func(cache *LRUCache) {
+		cache.walOptions = append(cache.walOptions, wal.WithKeyRing(keys))
+	}
+}
+
+// WithWALCompression compresses WAL records whose value is at least
+// threshold bytes; see wal.WithCompression
+func WithWALCompression(threshold int) Option {
+	return func(cache *LRUCache) {
+		cache.walOptions = append(cache.walOptions, wal.WithCompression(threshold))
+	}
+}
+
+// WithWALStorage keeps the WAL in storage, e.g. a wal.MemoryStorage in
+// tests, instead of walDirectory, and enables it even if walDirectory is empty
+func WithWALStorage(storage wal.Storage) Option {
+	return func(cache *LRUCache) {
+		cache.walStorage = storage
+	}
+}
+
+func (cache *LRUCache) Set(key string, value any, ttl time.Duration, opts ...SetOption) error {
+	var options setOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return cache.withWriter(writeOp{key: key, value: value}, func() error {
+		cache.mu.Lock()
+		defer cache.unlock()
+
+		return cache.set(key, value, ttl, options)
+	})
+}
+
+// set writes the entry to the WAL and stores it in the cache.
+// A ttl of zero falls back to the default TTL. The caller must hold cache.mu
+// and release it with unlock so the replaced value is reported.
+func (cache *LRUCache) set(key string, value any, ttl time.Duration, opts setOptions) error {
+	if ttl == 0 {
+		ttl = cache.defaultTTL
+	}
+	var oldValue any
+	old, exists := cache.lookup(key)
+	if exists {
+		// store updates the entry in place
+		oldValue = old.load()
+	} else if cache.full() {
+		return ErrFull
+	}
 
 	// Serialize value for WAL
 	valueBytes, err := serializeValue(value)
@@ -62,38 +215,57 @@ func (cache *LRUCache) Set(key string, value any, ttl time.Duration) error {
 		return fmt.Errorf("failed to serialize value: %w", err)
 	}
 
-	// Calculate expiration timestamp
-	var expiresAtUnixNano int64
-	if ttl > 0 {
-		expiresAtUnixNano = time.Now().Add(ttl).UnixNano()
-	}
+	exp := cache.expiryAfter(ttl)
+	exp.sliding = opts.sliding && !exp.deadline.IsZero()
 
 	// Write to WAL before updating cache
 	if cache.wal != nil {
-		if err := cache.wal.Append(wal.EntryTypeSET, key, valueBytes, expiresAtUnixNano); err != nil {
+		if err := cache.wal.Append(wal.EntryTypeSET, key, valueBytes, exp.expiresAtUnixNano()); err != nil {
 			return fmt.Errorf("failed to write to WAL: %w", err)
 		}
 	}
+	if err := cache.logSliding(key, exp); err != nil {
+		return err
+	}
+
+	cache.store(key, value, exp, len(key)+len(valueBytes))
+	if exists {
+		cache.removed(key, oldValue, RemovalReplaced)
+	}
+	cache.notify(EventSet, key, cloneValue(value))
+
+	return nil
+}
+
+// store puts value under key without writing to the WAL, replacing any
+// existing value and evicting an entry if a new key does not fit. The
+// caller must hold cache.mu.
+func (cache *LRUCache) store(key string, value any, exp expiry, size int) *CacheItem {
+	if compressed, ok := cache.compressValue(value); ok {
+		value, size = compressed, len(key)+len(compressed.data)
+	}
 
 	// update existing item if it exists and move it to the front of the evict list
 	if entry, ok := cache.entries[key]; ok {
 		entry.value = value
-		entry.TTL = ttl
-		entry.createdAt = time.Now()
+		entry.setExpiry(exp)
+		entry.createdAt = cache.now()
+		cache.resize(entry, size)
 		cache.evictList.MoveToFront(entry.element)
-		return nil
+		return entry
 	}
 
 	if len(cache.entries) >= cache.capacity {
-		cache.evictLRU()
+		cache.evict()
 	}
 
 	// create new item and add to the cache
 	entry := &CacheItem{
 		value:     value,
-		TTL:       ttl,
-		createdAt: time.Now(),
+		createdAt: cache.now(),
+		size:      size,
 	}
+	entry.setExpiry(exp)
 
 	// push new item to the front of the evict list
 	element := cache.evictList.PushFront(key)
@@ -102,48 +274,154 @@ func (cache *LRUCache) Set(key string, value any, ttl time.Duration) error {
 
 	// add new item to the cache
 	cache.entries[key] = entry
+	cache.bytes += int64(size)
 
-	return nil
+	return entry
+}
+
+// resize updates the tracked size of an entry. The caller must hold cache.mu.
+func (cache *LRUCache) resize(entry *CacheItem, size int) {
+	cache.bytes += int64(size - entry.size)
+	entry.size = size
 }
 
-func (cache *LRUCache) evictLRU() {
-	element := cache.evictList.Back()
-	if element != nil {
-		key := cache.evictList.Remove(element).(string)
-		delete(cache.entries, key)
+// CompareAndSwap replaces the value stored under key with newValue only if
+// the current value equals oldValue. It reports whether the swap happened.
+// A missing or expired key never matches.
+func (cache *LRUCache) CompareAndSwap(key string, oldValue, newValue any, ttl time.Duration) (bool, error) {
+	cache.mu.RLock()
+	writer, behind := cache.writer, cache.writeBehind
+	cache.mu.RUnlock()
+	if writer == nil {
+		cache.mu.Lock()
+		defer cache.unlock()
+		return cache.compareAndSwap(key, oldValue, newValue, ttl)
 	}
+
+	// The key's write lock keeps the value from changing between the
+	// comparison and the write to the backing store
+	unlock := cache.lockKey(key)
+	defer unlock()
+
+	cache.mu.Lock()
+	entry, ok := cache.lookup(key)
+	matched := ok && reflect.DeepEqual(entry.load(), oldValue)
+	cache.unlock()
+	if !matched {
+		return false, nil
+	}
+
+	var swapped bool
+	err := writeBack(writer, behind, writeOp{key: key, value: newValue}, func() error {
+		cache.mu.Lock()
+		defer cache.unlock()
+
+		var err error
+		swapped, err = cache.compareAndSwap(key, oldValue, newValue, ttl)
+		return err
+	})
+	return swapped, err
+}
+
+// compareAndSwap implements CompareAndSwap. The caller must hold cache.mu.
+func (cache *LRUCache) compareAndSwap(key string, oldValue, newValue any, ttl time.Duration) (bool, error) {
+	entry, ok := cache.lookup(key)
+	if !ok || !reflect.DeepEqual(entry.load(), oldValue) {
+		return false, nil
+	}
+
+	if err := cache.set(key, newValue, ttl, setOptions{}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// full reports whether a new key would be rejected under EvictNone.
+// The caller must hold cache.mu.
+func (cache *LRUCache) full() bool {
+	return cache.policy == EvictNone && len(cache.entries) >= cache.capacity
+}
+
+// evict removes one entry chosen by the eviction policy and reports whether
+// it did. The caller must hold cache.mu.
+func (cache *LRUCache) evict() bool {
+	var key string
+	switch cache.policy {
+	case EvictNone:
+		return false
+	case EvictRandom:
+		// Map iteration starts at a random entry
+		for k := range cache.entries {
+			key = k
+			break
+		}
+	default:
+		element := cache.evictList.Back()
+		if element == nil {
+			return false
+		}
+		key = element.Value.(string)
+	}
+
+	entry, ok := cache.entries[key]
+	if !ok {
+		return false
+	}
+	cache.removeEntry(key, entry)
+	cache.removed(key, entry.value, RemovalEvicted)
+	cache.metrics.evictions.Inc()
+	cache.notify(EventEvict, key, nil)
+	return true
 }
 
+// removeEntry drops an entry from the map and the evict list.
+// The caller must hold cache.mu.
+func (cache *LRUCache) removeEntry(key string, entry *CacheItem) {
+	cache.evictList.Remove(entry.element)
+	delete(cache.entries, key)
+	cache.bytes -= int64(entry.size)
+}
+
+// Get returns the value stored under key, loading it on a miss if the
+// cache has a loader. Use GetContext to see why a load failed.
 func (cache *LRUCache) Get(key string) (any, bool) {
-	cache.mu.Lock()
-	defer cache.mu.Unlock()
+	value, err := cache.GetContext(context.Background(), key)
+	return value, err == nil
+}
 
+// lookup returns the live entry for key, removing it if its TTL has passed.
+// The caller must hold cache.mu.
+func (cache *LRUCache) lookup(key string) (*CacheItem, bool) {
 	entry, ok := cache.entries[key]
 	if !ok {
 		return nil, false
 	}
 
 	// Check TTL expiration
-	if entry.TTL > 0 {
-		expiresAt := entry.createdAt.Add(entry.TTL)
-		if time.Now().After(expiresAt) {
-			// Item has expired, remove it
-			cache.evictList.Remove(entry.element)
-			delete(cache.entries, key)
-			return nil, false
-		}
+	if entry.expired(cache.now()) {
+		// Item has expired, remove it
+		cache.removeEntry(key, entry)
+		cache.removed(key, entry.value, RemovalExpired)
+		cache.metrics.expirations.Inc()
+		cache.notify(EventExpire, key, nil)
+		return nil, false
 	}
 
-	// Item is valid, move to front and return
-	cache.evictList.MoveToFront(entry.element)
-	return entry.value, true
+	return entry, true
 }
 
 // Delete removes a key from the cache and writes to WAL
 func (cache *LRUCache) Delete(key string) error {
-	cache.mu.Lock()
-	defer cache.mu.Unlock()
+	return cache.withWriter(writeOp{key: key, delete: true}, func() error {
+		cache.mu.Lock()
+		defer cache.unlock()
+
+		return cache.delete(key)
+	})
+}
 
+// delete implements Delete. The caller must hold cache.mu.
+func (cache *LRUCache) delete(key string) error {
 	entry, ok := cache.entries[key]
 	if !ok {
 		return nil // Key doesn't exist, nothing to delete
@@ -157,25 +435,217 @@ func (cache *LRUCache) Delete(key string) error {
 	}
 
 	// Remove from cache
-	cache.evictList.Remove(entry.element)
-	delete(cache.entries, key)
+	cache.removeEntry(key, entry)
+	cache.removed(key, entry.value, RemovalDeleted)
+	cache.notify(EventDelete, key, nil)
 
 	return nil
 }
 
-// Close closes the WAL if it exists
+// TTL returns the remaining time to live of key. A TTL of zero means the
+// key never expires. The boolean is false if the key does not exist.
+func (cache *LRUCache) TTL(key string) (time.Duration, bool) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	entry, ok := cache.lookup(key)
+	if !ok {
+		return 0, false
+	}
+	if entry.deadline.IsZero() {
+		return 0, true
+	}
+	return entry.deadline.Sub(cache.now()), true
+}
+
+// Keys returns the live keys starting with prefix, most recently used first
+func (cache *LRUCache) Keys(prefix string) []string {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	keys := make([]string, 0, len(cache.entries))
+	for element := cache.evictList.Front(); element != nil; {
+		key := element.Value.(string)
+		// lookup may remove the element, so advance first
+		element = element.Next()
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if _, ok := cache.lookup(key); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Len returns the number of entries currently held, including expired
+// entries that have not been accessed since they expired
+func (cache *LRUCache) Len() int {
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+
+	return len(cache.entries)
+}
+
+// Capacity returns the maximum number of entries the cache holds
+func (cache *LRUCache) Capacity() int {
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+
+	return cache.capacity
+}
+
+// SetCapacity changes the maximum number of entries, evicting entries if the
+// cache currently holds more. Under EvictNone the extra entries are kept and
+// new keys are rejected until enough are deleted.
+func (cache *LRUCache) SetCapacity(capacity int) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	cache.capacity = capacity
+	for len(cache.entries) > cache.capacity {
+		if !cache.evict() {
+			break
+		}
+	}
+}
+
+// EvictionPolicy returns the policy choosing which entry a new key evicts
+func (cache *LRUCache) EvictionPolicy() EvictionPolicy {
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+
+	return cache.policy
+}
+
+// SetEvictionPolicy changes the policy choosing which entry a new key evicts
+func (cache *LRUCache) SetEvictionPolicy(policy EvictionPolicy) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	cache.policy = policy
+}
+
+// DefaultTTL returns the TTL applied by Set when it is called with a ttl of zero
+func (cache *LRUCache) DefaultTTL() time.Duration {
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+
+	return cache.defaultTTL
+}
+
+// SetDefaultTTL sets the TTL applied by Set when it is called with a ttl of zero.
+// A default of zero means such keys never expire.
+func (cache *LRUCache) SetDefaultTTL(ttl time.Duration) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	cache.defaultTTL = ttl
+}
+
+// Snapshot writes every live entry to a WAL snapshot so that recovery no
+// longer needs the segments written so far, which are then removed
+func (cache *LRUCache) Snapshot() error {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	return cache.snapshot()
+}
+
+// compactLoop snapshots the WAL whenever it holds more segments than it
+// keeps, so it can drop them, until the WAL is closed. It runs on its own
+// goroutine to keep snapshots out of the operations that fill a segment.
+// A failure leaves the segments in place, so no write is lost, and the next
+// rotation tries again.
+func (cache *LRUCache) compactLoop(needed <-chan struct{}) {
+	for range needed {
+		cache.mu.Lock()
+		var err error
+		if !cache.closed && cache.wal.NeedsSnapshot() {
+			err = cache.snapshot()
+		}
+		cache.unlock()
+
+		if err != nil {
+			log.Printf("failed to compact WAL: %v", err)
+		}
+	}
+}
+
+// snapshot implements Snapshot. The caller must hold cache.mu.
+func (cache *LRUCache) snapshot() error {
+	if cache.wal == nil {
+		return fmt.Errorf("cannot snapshot: WAL is disabled")
+	}
+
+	now := cache.now()
+	entries := make([]*wal.WAL_Entry, 0, len(cache.entries))
+
+	// Least recently used first, so replaying the snapshot restores the LRU order
+	for element := cache.evictList.Back(); element != nil; element = element.Prev() {
+		key := element.Value.(string)
+		item := cache.entries[key]
+
+		if item.expired(now) {
+			continue
+		}
+
+		valueBytes, err := serializeValue(item.load())
+		if err != nil {
+			return fmt.Errorf("failed to serialize value for key %s: %w", key, err)
+		}
+
+		entries = append(entries, &wal.WAL_Entry{
+			Type:              wal.EntryTypeSET,
+			Key:               key,
+			Value:             valueBytes,
+			ExpiresAtUnixNano: item.expiresAtUnixNano(),
+		})
+		if item.sliding {
+			window, err := encodeOp(item.ttl)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, &wal.WAL_Entry{
+				Type:              wal.EntryTypeEXPIRE,
+				Key:               key,
+				Value:             window,
+				ExpiresAtUnixNano: item.expiresAtUnixNano(),
+			})
+		}
+	}
+
+	return cache.wal.WriteSnapshot(entries)
+}
+
+// Close closes every watcher, wakes blocked pops and closes the WAL if it exists
 func (cache *LRUCache) Close() error {
+	cache.mu.Lock()
+	for w := range cache.watchers {
+		cache.removeWatcher(w, nil)
+	}
+	cache.closed = true
+	cache.signalPush()
+	behind := cache.writeBehind
+	cache.unlock()
+
+	// Queued writes reach the backing store before Close returns
+	if behind != nil {
+		behind.close()
+	}
 	if cache.wal != nil {
 		return cache.wal.Close()
 	}
 	return nil
 }
 
-// serializeValue serializes a value to bytes using gob encoding
+// serializeValue serializes a value to bytes using gob encoding.
+// The value is encoded as an interface so its concrete type can be restored;
+// types other than the gob basics must be registered with gob.Register.
 func serializeValue(value any) ([]byte, error) {
 	var buf bytes.Buffer
 	encoder := gob.NewEncoder(&buf)
-	if err := encoder.Encode(value); err != nil {
+	if err := encoder.Encode(&value); err != nil {
 		return nil, err
 	}
 	return buf.Bytes(), nil
@@ -197,25 +667,23 @@ func (cache *LRUCache) recoverFromWAL() error {
 		return nil
 	}
 
+	start := time.Now()
+	defer func() {
+		cache.metrics.recoveryDuration.Store(int64(time.Since(start)))
+	}()
+
 	entries, err := cache.wal.ReadAll()
 	if err != nil {
 		return err
 	}
 
-	now := time.Now()
+	now := cache.now()
 
-	// Replay entries in order
+	// Replay entries in order with the deadlines they were logged with;
+	// expiry is checked once at the end, as later records may change them
 	for _, entry := range entries {
 		switch entry.Type {
 		case wal.EntryTypeSET:
-			// Check if entry has expired
-			if entry.ExpiresAtUnixNano > 0 {
-				if now.UnixNano() >= entry.ExpiresAtUnixNano {
-					// Entry has expired, skip it
-					continue
-				}
-			}
-
 			// Deserialize value
 			value, err := deserializeValue(entry.Value)
 			if err != nil {
@@ -224,38 +692,45 @@ func (cache *LRUCache) recoverFromWAL() error {
 				continue
 			}
 
-			// Calculate TTL from expiration timestamp
-			var ttl time.Duration
-			var createdAt time.Time
-			if entry.ExpiresAtUnixNano > 0 {
-				expiresAt := time.Unix(0, entry.ExpiresAtUnixNano)
-				ttl = expiresAt.Sub(now)
-				createdAt = now
-			}
-
 			// Add to cache (without writing to WAL to avoid recursion)
-			if len(cache.entries) >= cache.capacity {
-				cache.evictLRU()
-			}
-
-			cacheItem := &CacheItem{
-				value:     value,
-				TTL:       ttl,
-				createdAt: createdAt,
-			}
-
-			element := cache.evictList.PushFront(entry.Key)
-			cacheItem.element = element
-			cache.entries[entry.Key] = cacheItem
+			cache.store(entry.Key, value, recoveredExpiry(entry, now), len(entry.Key)+len(entry.Value))
 
 		case wal.EntryTypeDELETE:
 			// Remove from cache if it exists
 			if cacheEntry, exists := cache.entries[entry.Key]; exists {
-				cache.evictList.Remove(cacheEntry.element)
-				delete(cache.entries, entry.Key)
+				cache.removeEntry(entry.Key, cacheEntry)
+			}
+
+		case wal.EntryTypeEXPIRE:
+			cache.replayExpiry(entry, recoveredExpiry(entry, now))
+
+		default:
+			replay, ok := dataTypeReplays[entry.Type]
+			if !ok {
+				fmt.Printf("Warning: skipping WAL entry of unknown type %d for key %s\n", entry.Type, entry.Key)
+				continue
+			}
+			exp := recoveredExpiry(entry, now)
+			if existing, exists := cache.entries[entry.Key]; exists && !existing.deadline.Equal(exp.deadline) {
+				// Updates keep the deadline of the value they change, so the
+				// value this update saw had expired and it started a new one
+				cache.removeEntry(entry.Key, existing)
+			}
+			if err := replay(cache, entry, exp); err != nil {
+				fmt.Printf("Warning: failed to replay update for key %s: %v\n", entry.Key, err)
 			}
 		}
 	}
 
+	cache.dropExpired(now)
 	return nil
 }
+
+// dropExpired removes the recovered entries whose deadline has passed by now
+func (cache *LRUCache) dropExpired(now time.Time) {
+	for key, entry := range cache.entries {
+		if entry.expired(now) {
+			cache.removeEntry(key, entry)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/nishanth-gowda/kv-store/wal"
+)
+
+// Clock is the time source a cache uses for expiration. Readings should
+// carry a monotonic clock reading, as time.Now's do, so that deadlines set
+// while the cache runs are not moved by changes to the wall clock; deadlines
+// recovered from the WAL are compared by wall clock.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Option configures a cache created by NewLRUCache
+type Option func(*LRUCache)
+
+// WithClock makes the cache read the time from clock instead of time.Now,
+// including while it recovers from the WAL
+func WithClock(clock Clock) Option {
+	return func(cache *LRUCache) {
+		cache.clock = clock
+	}
+}
+
+// now returns the current time of the cache's clock
+func (cache *LRUCache) now() time.Time {
+	return cache.clock.Now()
+}
+
+// expiry is when an entry expires: an absolute deadline, zero if it never
+// does, and the lifetime the deadline was set with, which Touch restarts.
+// Reads restart it too when sliding is set.
+type expiry struct {
+	deadline time.Time
+	ttl      time.Duration
+	sliding  bool
+}
+
+// expiryAfter returns an expiry ttl from now, or none if ttl is not positive
+func (cache *LRUCache) expiryAfter(ttl time.Duration) expiry {
+	if ttl <= 0 {
+		return expiry{}
+	}
+	return expiry{deadline: cache.now().Add(ttl), ttl: ttl}
+}
+
+// expired reports whether the deadline has been reached at now. Live
+// entries and WAL records are both checked with it, so an entry expires at
+// the same instant whether or not the cache restarted.
+func (e expiry) expired(now time.Time) bool {
+	return !e.deadline.IsZero() && !now.Before(e.deadline)
+}
+
+// expiresAtUnixNano returns the deadline as logged in the WAL, 0 for none
+func (e expiry) expiresAtUnixNano() int64 {
+	if e.deadline.IsZero() {
+		return 0
+	}
+	return e.deadline.UnixNano()
+}
+
+// recoveredExpiry returns the expiry of a WAL entry. The lifetime it was
+// set with is not logged, so the time left at recovery stands in for it.
+func recoveredExpiry(entry *wal.WAL_Entry, now time.Time) expiry {
+	if entry.ExpiresAtUnixNano == 0 {
+		return expiry{}
+	}
+	deadline := time.Unix(0, entry.ExpiresAtUnixNano)
+	return expiry{deadline: deadline, ttl: deadline.Sub(now)}
+}
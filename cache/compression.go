@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Compressors keep large internal state, so they are reused across values
+var (
+	flateWriters = sync.Pool{New: func() any {
+		w, _ := flate.NewWriter(nil, flate.BestSpeed)
+		return w
+	}}
+	flateReaders = sync.Pool{New: func() any {
+		return flate.NewReader(nil)
+	}}
+)
+
+// compressedValue holds a string or []byte value deflated in memory
+type compressedValue struct {
+	data     []byte
+	isString bool
+}
+
+// CompressionThreshold returns the size from which string and []byte values
+// are kept compressed in memory, or 0 if they never are
+func (cache *LRUCache) CompressionThreshold() int {
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+
+	return cache.compressionThreshold
+}
+
+// SetCompressionThreshold keeps string and []byte values of at least
+// threshold bytes compressed in memory when that makes them smaller,
+// trading CPU on every read for memory. It applies to values stored from
+// now on; a threshold of zero turns compression off.
+func (cache *LRUCache) SetCompressionThreshold(threshold int) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	cache.compressionThreshold = threshold
+}
+
+// compressValue compresses value if it is a string or []byte at least as
+// long as the compression threshold, reporting false if it is not or
+// compressing it saves no memory. The caller must hold cache.mu.
+func (cache *LRUCache) compressValue(value any) (compressedValue, bool) {
+	if cache.compressionThreshold <= 0 {
+		return compressedValue{}, false
+	}
+	var raw []byte
+	switch v := value.(type) {
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		return compressedValue{}, false
+	}
+	if len(raw) < cache.compressionThreshold {
+		return compressedValue{}, false
+	}
+
+	var buf bytes.Buffer
+	w := flateWriters.Get().(*flate.Writer)
+	defer flateWriters.Put(w)
+	w.Reset(&buf)
+	if _, err := w.Write(raw); err != nil || w.Close() != nil || buf.Len() >= len(raw) {
+		return compressedValue{}, false
+	}
+	_, isString := value.(string)
+	return compressedValue{data: buf.Bytes(), isString: isString}, true
+}
+
+// loadValue returns value decompressed if it was stored compressed
+func loadValue(value any) any {
+	compressed, ok := value.(compressedValue)
+	if !ok {
+		return value
+	}
+	r := flateReaders.Get().(io.ReadCloser)
+	defer flateReaders.Put(r)
+	r.(flate.Resetter).Reset(bytes.NewReader(compressed.data), nil)
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		// Only compressValue writes the data, so this is a bug
+		panic(fmt.Sprintf("failed to decompress cached value: %v", err))
+	}
+	if compressed.isString {
+		return string(raw)
+	}
+	return raw
+}
+
+// load returns the value of the entry, decompressing it if needed
+func (entry *CacheItem) load() any {
+	return loadValue(entry.value)
+}
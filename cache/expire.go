@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nishanth-gowda/kv-store/wal"
+)
+
+// Expire sets key to expire after ttl without rewriting its value, replacing
+// any sliding expiration. A ttl of zero or less deletes the key. It reports
+// whether the key exists.
+func (cache *LRUCache) Expire(key string, ttl time.Duration) (bool, error) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	now := cache.now()
+	return cache.expire(key, expiry{deadline: now.Add(ttl), ttl: ttl}, now)
+}
+
+// ExpireAt sets key to expire at the given time without rewriting its
+// value, replacing any sliding expiration. A time that has passed deletes
+// the key. It reports whether the key exists.
+func (cache *LRUCache) ExpireAt(key string, at time.Time) (bool, error) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	now := cache.now()
+	return cache.expire(key, expiry{deadline: at, ttl: at.Sub(now)}, now)
+}
+
+// Persist removes the expiration of key. It reports whether the key existed
+// and had one.
+func (cache *LRUCache) Persist(key string) (bool, error) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	entry, ok := cache.lookup(key)
+	if !ok || entry.deadline.IsZero() {
+		return false, nil
+	}
+	if err := cache.logExpiry(key, expiry{}); err != nil {
+		return false, err
+	}
+	entry.setExpiry(expiry{})
+	return true, nil
+}
+
+// Touch marks key as recently used and restarts its TTL, if it has one, so
+// it expires a full TTL from now. For keys recovered from the WAL the TTL
+// restarted is the time that was left at recovery. It reports whether the
+// key exists.
+func (cache *LRUCache) Touch(key string) (bool, error) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	entry, ok := cache.lookup(key)
+	if !ok {
+		return false, nil
+	}
+	if !entry.deadline.IsZero() {
+		exp := cache.expiryAfter(entry.ttl)
+		exp.sliding = entry.sliding
+		if err := cache.logExpiry(key, exp); err != nil {
+			return false, err
+		}
+		entry.setExpiry(exp)
+	}
+	cache.evictList.MoveToFront(entry.element)
+	return true, nil
+}
+
+// expire implements Expire and ExpireAt, deleting the key if exp has
+// already expired at now. The caller must hold cache.mu.
+func (cache *LRUCache) expire(key string, exp expiry, now time.Time) (bool, error) {
+	entry, ok := cache.lookup(key)
+	if !ok {
+		return false, nil
+	}
+	if exp.expired(now) {
+		return true, cache.delete(key)
+	}
+	if err := cache.logExpiry(key, exp); err != nil {
+		return false, err
+	}
+	entry.setExpiry(exp)
+	return true, nil
+}
+
+// setExpiry sets the expiry of entry to one that has been logged
+func (entry *CacheItem) setExpiry(exp expiry) {
+	entry.expiry = exp
+	entry.logged = exp.deadline
+}
+
+// logExpiry writes an EXPIRE record carrying only the new deadline of key
+// and, for a sliding expiration, its window. The caller must hold cache.mu.
+func (cache *LRUCache) logExpiry(key string, exp expiry) error {
+	if cache.wal == nil {
+		return nil
+	}
+	var window []byte
+	if exp.sliding {
+		var err error
+		if window, err = encodeOp(exp.ttl); err != nil {
+			return err
+		}
+	}
+	if err := cache.wal.Append(wal.EntryTypeEXPIRE, key, window, exp.expiresAtUnixNano()); err != nil {
+		return fmt.Errorf("failed to write to WAL: %w", err)
+	}
+	return nil
+}
+
+// logSliding follows the SET record of a value with sliding expiration with
+// the EXPIRE record that makes recovery restore its window. A crash between
+// the two leaves a fixed deadline. The caller must hold cache.mu.
+func (cache *LRUCache) logSliding(key string, exp expiry) error {
+	if !exp.sliding {
+		return nil
+	}
+	return cache.logExpiry(key, exp)
+}
+
+// replayExpiry applies an EXPIRE record read from the WAL during recovery
+func (cache *LRUCache) replayExpiry(entry *wal.WAL_Entry, exp expiry) {
+	existing, ok := cache.entries[entry.Key]
+	if !ok {
+		return
+	}
+	if len(entry.Value) > 0 && !exp.deadline.IsZero() {
+		var window time.Duration
+		if err := decodeOp(entry.Value, &window); err != nil {
+			fmt.Printf("Warning: failed to decode sliding expiration for key %s: %v\n", entry.Key, err)
+		} else {
+			exp.ttl, exp.sliding = window, true
+		}
+	}
+	existing.setExpiry(exp)
+}
@@ -0,0 +1,237 @@
+package cache
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"strconv"
+
+	"github.com/nishanth-gowda/kv-store/wal"
+)
+
+// ErrNotInteger is returned by HIncrBy when the field does not hold an
+// integer or the increment would overflow
+var ErrNotInteger = errors.New("hash value is not an integer or out of range")
+
+// Hash is the value stored under a hash key: field names mapped to values.
+// Get returns a copy, so modifying it does not change the cache.
+type Hash map[string]string
+
+func init() {
+	// Snapshots store whole hashes as SET values
+	gob.Register(Hash{})
+}
+
+// HSet sets fields of the hash stored under key, creating it if needed, and
+// returns the number of fields that were added rather than updated.
+// Only the given fields are written to the WAL.
+func (cache *LRUCache) HSet(key string, fields map[string]string) (int, error) {
+	if len(fields) == 0 {
+		return 0, nil
+	}
+
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	entry, _, err := lookupAs[Hash](cache, key)
+	if err != nil {
+		return 0, err
+	}
+
+	pairs := make([]string, 0, 2*len(fields))
+	for field, value := range fields {
+		pairs = append(pairs, field, value)
+	}
+	return cache.hset(key, entry, pairs)
+}
+
+// HGet returns the value of a field in the hash stored under key
+func (cache *LRUCache) HGet(key, field string) (string, bool, error) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	entry, hash, err := lookupAs[Hash](cache, key)
+	if err != nil || entry == nil {
+		return "", false, err
+	}
+	cache.evictList.MoveToFront(entry.element)
+
+	value, ok := hash[field]
+	return value, ok, nil
+}
+
+// HGetAll returns a copy of the hash stored under key. A missing key
+// returns an empty hash.
+func (cache *LRUCache) HGetAll(key string) (Hash, error) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	entry, hash, err := lookupAs[Hash](cache, key)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return Hash{}, nil
+	}
+	cache.evictList.MoveToFront(entry.element)
+	return cloneValue(hash).(Hash), nil
+}
+
+// HDel removes fields from the hash stored under key and returns how many
+// existed. The key is deleted once its last field is removed.
+func (cache *LRUCache) HDel(key string, fields ...string) (int, error) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	entry, hash, err := lookupAs[Hash](cache, key)
+	if err != nil || entry == nil {
+		return 0, err
+	}
+
+	var present []string
+	for _, field := range fields {
+		if _, ok := hash[field]; ok {
+			present = append(present, field)
+		}
+	}
+	if len(present) == 0 {
+		return 0, nil
+	}
+
+	if err := cache.appendOp(wal.EntryTypeHDEL, key, present, entry.expiresAtUnixNano()); err != nil {
+		return 0, err
+	}
+	// Removing the last fields deletes the key; the callback gets a copy of them
+	var last Hash
+	if len(present) == len(hash) {
+		last = maps.Clone(hash)
+	}
+	cache.applyHDel(key, present)
+
+	if _, ok := cache.entries[key]; ok {
+		cache.notify(EventSet, key, nil)
+	} else {
+		cache.removed(key, last, RemovalDeleted)
+		cache.notify(EventDelete, key, nil)
+	}
+	return len(present), nil
+}
+
+// HIncrBy adds delta to the integer stored in a field of the hash under key
+// and returns the result. A missing field counts as zero.
+func (cache *LRUCache) HIncrBy(key, field string, delta int64) (int64, error) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	entry, hash, err := lookupAs[Hash](cache, key)
+	if err != nil {
+		return 0, err
+	}
+
+	var current int64
+	if value, ok := hash[field]; ok {
+		if current, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return 0, ErrNotInteger
+		}
+	}
+	if (delta > 0 && current > math.MaxInt64-delta) || (delta < 0 && current < math.MinInt64-delta) {
+		return 0, ErrNotInteger
+	}
+
+	result := current + delta
+	// The result is logged as a plain field update so replay is idempotent
+	if _, err := cache.hset(key, entry, []string{field, strconv.FormatInt(result, 10)}); err != nil {
+		return 0, err
+	}
+	return result, nil
+}
+
+// hset logs and applies field/value pairs to the hash under key. entry is
+// the live hash, or nil to create one. The caller must hold cache.mu.
+func (cache *LRUCache) hset(key string, entry *CacheItem, pairs []string) (int, error) {
+	exp, err := cache.updateExpiry(entry)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := cache.appendOp(wal.EntryTypeHSET, key, pairs, exp.expiresAtUnixNano()); err != nil {
+		return 0, err
+	}
+
+	added := cache.applyHSet(key, pairs, exp)
+	cache.notify(EventSet, key, nil)
+	return added, nil
+}
+
+// applyHSet sets field/value pairs in the hash under key, creating it with
+// exp if it does not exist. The caller must hold cache.mu.
+func (cache *LRUCache) applyHSet(key string, pairs []string, exp expiry) int {
+	entry, ok := cache.entries[key]
+	var hash Hash
+	if ok {
+		hash, ok = entry.value.(Hash)
+	}
+	if !ok {
+		hash = Hash{}
+		entry = cache.store(key, hash, exp, len(key))
+	}
+
+	added, size := 0, entry.size
+	for i := 0; i+1 < len(pairs); i += 2 {
+		field, value := pairs[i], pairs[i+1]
+		if old, exists := hash[field]; exists {
+			size += len(value) - len(old)
+		} else {
+			added++
+			size += len(field) + len(value)
+		}
+		hash[field] = value
+	}
+	cache.resize(entry, size)
+	cache.evictList.MoveToFront(entry.element)
+	return added
+}
+
+// applyHDel removes fields from the hash under key, deleting the key when it
+// becomes empty. The caller must hold cache.mu.
+func (cache *LRUCache) applyHDel(key string, fields []string) {
+	entry, ok := cache.entries[key]
+	if !ok {
+		return
+	}
+	hash, ok := entry.value.(Hash)
+	if !ok {
+		return
+	}
+
+	size := entry.size
+	for _, field := range fields {
+		if value, exists := hash[field]; exists {
+			size -= len(field) + len(value)
+			delete(hash, field)
+		}
+	}
+	cache.resize(entry, size)
+
+	if len(hash) == 0 {
+		cache.removeEntry(key, entry)
+	}
+}
+
+// replayHash applies a hash update read from the WAL during recovery
+func (cache *LRUCache) replayHash(entry *wal.WAL_Entry, exp expiry) error {
+	var fields []string
+	if err := decodeOp(entry.Value, &fields); err != nil {
+		return fmt.Errorf("failed to decode hash fields: %w", err)
+	}
+
+	switch entry.Type {
+	case wal.EntryTypeHSET:
+		cache.applyHSet(entry.Key, fields, exp)
+	case wal.EntryTypeHDEL:
+		cache.applyHDel(entry.Key, fields)
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package cache
+
+import (
+	"encoding/gob"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+
+	"github.com/nishanth-gowda/kv-store/wal"
+)
+
+const (
+	// hllPrecision is the number of hash bits selecting a register; 2^14
+	// registers give a standard error of about 0.81%
+	hllPrecision = 14
+	hllRegisters = 1 << hllPrecision
+)
+
+// HyperLogLog is the value stored under a HyperLogLog key: one register per
+// hash bucket holding the longest run of leading zeros seen. It estimates
+// the number of distinct elements added in a fixed 16 KB.
+// Get returns a copy, so modifying it does not change the cache.
+type HyperLogLog []uint8
+
+func init() {
+	// Snapshots and PFMERGE store whole HyperLogLogs as SET values
+	gob.Register(HyperLogLog{})
+}
+
+func newHyperLogLog() HyperLogLog {
+	return make(HyperLogLog, hllRegisters)
+}
+
+// hashString returns a well-mixed 64-bit hash of s. It must not change
+// between releases because the results are persisted.
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	// FNV alone mixes the high bits poorly; finish with the murmur3 fmix64 step
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// register returns the register index of element and the rank it observes
+func register(element string) (uint64, uint8) {
+	x := hashString(element)
+	index := x >> (64 - hllPrecision)
+	// Position of the first set bit in the remaining bits, counting from 1
+	rank := uint8(bits.LeadingZeros64(x<<hllPrecision|1<<(hllPrecision-1)) + 1)
+	return index, rank
+}
+
+// add records element and reports whether a register changed
+func (hll HyperLogLog) add(element string) bool {
+	index, rank := register(element)
+	if rank > hll[index] {
+		hll[index] = rank
+		return true
+	}
+	return false
+}
+
+// changes reports whether adding any of elements would change a register
+func (hll HyperLogLog) changes(elements []string) bool {
+	for _, element := range elements {
+		if index, rank := register(element); rank > hll[index] {
+			return true
+		}
+	}
+	return false
+}
+
+// merge raises every register to the maximum of hll and other
+func (hll HyperLogLog) merge(other HyperLogLog) {
+	for i, rank := range other {
+		hll[i] = max(hll[i], rank)
+	}
+}
+
+// Count returns the estimated number of distinct elements added
+func (hll HyperLogLog) Count() uint64 {
+	m := float64(len(hll))
+	if m == 0 {
+		return 0
+	}
+
+	sum, zeros := 0.0, 0
+	for _, rank := range hll {
+		sum += math.Ldexp(1, -int(rank))
+		if rank == 0 {
+			zeros++
+		}
+	}
+
+	alpha := 0.7213 / (1 + 1.079/m)
+	estimate := alpha * m * m / sum
+	// Linear counting is more accurate while many registers are still empty
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(estimate + 0.5)
+}
+
+// PFAdd adds elements to the HyperLogLog stored under key, creating it if
+// needed, and reports whether the estimate may have changed
+func (cache *LRUCache) PFAdd(key string, elements ...string) (bool, error) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	entry, hll, err := lookupAs[HyperLogLog](cache, key)
+	if err != nil {
+		return false, err
+	}
+
+	// Elements that change nothing are not logged
+	if entry != nil && !hll.changes(elements) {
+		return false, nil
+	}
+
+	exp, err := cache.updateExpiry(entry)
+	if err != nil {
+		return false, err
+	}
+	if err := cache.appendOp(wal.EntryTypePFADD, key, elements, exp.expiresAtUnixNano()); err != nil {
+		return false, err
+	}
+	cache.applyPFAdd(key, elements, exp)
+	cache.notify(EventSet, key, nil)
+	return true, nil
+}
+
+// PFCount returns the estimated number of distinct elements in the union of
+// the HyperLogLogs stored under keys. Missing keys count as empty.
+func (cache *LRUCache) PFCount(keys ...string) (uint64, error) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	union, err := cache.unionHyperLogLogs(keys)
+	if err != nil {
+		return 0, err
+	}
+	return union.Count(), nil
+}
+
+// PFMerge stores the union of the HyperLogLogs under dest and sources in
+// dest, creating it if needed. The merged value is logged as a whole.
+func (cache *LRUCache) PFMerge(dest string, sources ...string) error {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	union, err := cache.unionHyperLogLogs(append([]string{dest}, sources...))
+	if err != nil {
+		return err
+	}
+
+	entry, _, _ := lookupAs[HyperLogLog](cache, dest)
+	return cache.replace(dest, entry, union)
+}
+
+// unionHyperLogLogs merges the HyperLogLogs under keys into a new one.
+// The caller must hold cache.mu.
+func (cache *LRUCache) unionHyperLogLogs(keys []string) (HyperLogLog, error) {
+	union := newHyperLogLog()
+	for _, key := range keys {
+		entry, hll, err := lookupAs[HyperLogLog](cache, key)
+		if err != nil {
+			return nil, err
+		}
+		if entry != nil {
+			cache.evictList.MoveToFront(entry.element)
+			union.merge(hll)
+		}
+	}
+	return union, nil
+}
+
+// applyPFAdd adds elements to the HyperLogLog under key, creating it with
+// exp if it does not exist. The caller must hold cache.mu.
+func (cache *LRUCache) applyPFAdd(key string, elements []string, exp expiry) {
+	entry, ok := cache.entries[key]
+	var hll HyperLogLog
+	if ok {
+		hll, ok = entry.value.(HyperLogLog)
+	}
+	if !ok {
+		hll = newHyperLogLog()
+		entry = cache.store(key, hll, exp, len(key)+len(hll))
+	}
+
+	for _, element := range elements {
+		hll.add(element)
+	}
+	cache.evictList.MoveToFront(entry.element)
+}
+
+// replayHyperLogLog applies a PFADD read from the WAL during recovery
+func (cache *LRUCache) replayHyperLogLog(entry *wal.WAL_Entry, exp expiry) error {
+	var elements []string
+	if err := decodeOp(entry.Value, &elements); err != nil {
+		return fmt.Errorf("failed to decode HyperLogLog elements: %w", err)
+	}
+	cache.applyPFAdd(entry.Key, elements, exp)
+	return nil
+}
@@ -0,0 +1,284 @@
+package cache
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/nishanth-gowda/kv-store/wal"
+)
+
+// ErrClosed is returned by blocking operations when the cache is closed
+var ErrClosed = errors.New("cache is closed")
+
+// List is the value stored under a list key, head first.
+// Get returns a copy, so modifying it does not change the cache.
+type List []string
+
+func init() {
+	// Snapshots store whole lists as SET values
+	gob.Register(List{})
+}
+
+// LPush inserts values at the head of the list stored under key, creating
+// it if needed, and returns the new length. Values are inserted one after
+// another, so the last value ends up first.
+func (cache *LRUCache) LPush(key string, values ...string) (int, error) {
+	return cache.push(key, wal.EntryTypeLPUSH, values)
+}
+
+// RPush appends values to the tail of the list stored under key, creating
+// it if needed, and returns the new length
+func (cache *LRUCache) RPush(key string, values ...string) (int, error) {
+	return cache.push(key, wal.EntryTypeRPUSH, values)
+}
+
+// LPop removes and returns the first element of the list stored under key.
+// The key is deleted once its last element is removed.
+func (cache *LRUCache) LPop(key string) (string, bool, error) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	return cache.pop(key, wal.EntryTypeLPOP)
+}
+
+// RPop removes and returns the last element of the list stored under key
+func (cache *LRUCache) RPop(key string) (string, bool, error) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	return cache.pop(key, wal.EntryTypeRPOP)
+}
+
+// BLPop pops the first element of the first non-empty list among keys,
+// waiting up to timeout for one to be pushed if they are all empty. A
+// timeout of zero waits indefinitely. The boolean is false if the timeout
+// passed; an error is returned if ctx is done or the cache is closed.
+func (cache *LRUCache) BLPop(ctx context.Context, timeout time.Duration, keys ...string) (string, string, bool, error) {
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	for {
+		cache.mu.Lock()
+		if cache.closed {
+			cache.unlock()
+			return "", "", false, ErrClosed
+		}
+		for _, key := range keys {
+			value, ok, err := cache.pop(key, wal.EntryTypeLPOP)
+			if err != nil || ok {
+				cache.unlock()
+				return key, value, ok, err
+			}
+		}
+		pushed := cache.pushSignal()
+		cache.unlock()
+
+		select {
+		case <-pushed:
+		case <-expired:
+			return "", "", false, nil
+		case <-ctx.Done():
+			return "", "", false, ctx.Err()
+		}
+	}
+}
+
+// LRange returns the elements of the list stored under key between start and
+// stop inclusive. Negative indexes count from the end, so 0 and -1 return
+// the whole list. Out of range indexes are clamped.
+func (cache *LRUCache) LRange(key string, start, stop int) ([]string, error) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	entry, list, err := lookupAs[List](cache, key)
+	if err != nil {
+		return nil, err
+	}
+	if entry != nil {
+		cache.evictList.MoveToFront(entry.element)
+	}
+
+	n := len(list)
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	start = max(start, 0)
+	stop = min(stop, n-1)
+	if start > stop {
+		return []string{}, nil
+	}
+	return slices.Clone(list[start : stop+1]), nil
+}
+
+// LLen returns the length of the list stored under key, 0 if it does not exist
+func (cache *LRUCache) LLen(key string) (int, error) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	_, list, err := lookupAs[List](cache, key)
+	return len(list), err
+}
+
+func (cache *LRUCache) push(key string, entryType wal.EntryType, values []string) (int, error) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	entry, list, err := lookupAs[List](cache, key)
+	if err != nil {
+		return 0, err
+	}
+	if len(values) == 0 {
+		return len(list), nil
+	}
+
+	exp, err := cache.updateExpiry(entry)
+	if err != nil {
+		return 0, err
+	}
+	if err := cache.appendOp(entryType, key, values, exp.expiresAtUnixNano()); err != nil {
+		return 0, err
+	}
+
+	length := cache.applyPush(key, entryType, values, exp)
+	cache.notify(EventSet, key, nil)
+	cache.signalPush()
+	return length, nil
+}
+
+// pop logs and removes one element from the head (EntryTypeLPOP) or tail
+// (EntryTypeRPOP) of the list under key. The caller must hold cache.mu.
+func (cache *LRUCache) pop(key string, entryType wal.EntryType) (string, bool, error) {
+	entry, list, err := lookupAs[List](cache, key)
+	if err != nil || entry == nil {
+		return "", false, err
+	}
+
+	if cache.wal != nil {
+		if err := cache.syncDeadline(key); err != nil {
+			return "", false, err
+		}
+		if err := cache.wal.Append(entryType, key, nil, entry.expiresAtUnixNano()); err != nil {
+			return "", false, fmt.Errorf("failed to write to WAL: %w", err)
+		}
+	}
+
+	// Popping the last element deletes the key; the callback gets a copy of it
+	var last List
+	if len(list) == 1 {
+		last = slices.Clone(list)
+	}
+	value := cache.applyPop(key, entryType)
+	if _, ok := cache.entries[key]; ok {
+		cache.notify(EventSet, key, nil)
+	} else {
+		cache.removed(key, last, RemovalDeleted)
+		cache.notify(EventDelete, key, nil)
+	}
+	return value, true, nil
+}
+
+// applyPush adds values to the list under key, creating it with exp if it
+// does not exist, and returns the new length. The caller must hold cache.mu.
+func (cache *LRUCache) applyPush(key string, entryType wal.EntryType, values []string, exp expiry) int {
+	entry, ok := cache.entries[key]
+	var list List
+	if ok {
+		list, ok = entry.value.(List)
+	}
+	if !ok {
+		entry = cache.store(key, List{}, exp, len(key))
+	}
+
+	size := entry.size
+	for _, value := range values {
+		size += len(value)
+	}
+
+	if entryType == wal.EntryTypeLPUSH {
+		head := slices.Clone(values)
+		slices.Reverse(head)
+		list = slices.Insert(list, 0, head...)
+	} else {
+		list = append(list, values...)
+	}
+
+	entry.value = list
+	cache.resize(entry, size)
+	cache.evictList.MoveToFront(entry.element)
+	return len(list)
+}
+
+// applyPop removes and returns one element from the list under key, deleting
+// the key when it becomes empty. The caller must hold cache.mu.
+func (cache *LRUCache) applyPop(key string, entryType wal.EntryType) string {
+	entry, ok := cache.entries[key]
+	if !ok {
+		return ""
+	}
+	list, ok := entry.value.(List)
+	if !ok || len(list) == 0 {
+		return ""
+	}
+
+	var value string
+	if entryType == wal.EntryTypeLPOP {
+		value = list[0]
+		list[0] = ""
+		list = list[1:]
+	} else {
+		value = list[len(list)-1]
+		list = list[:len(list)-1]
+	}
+
+	cache.resize(entry, entry.size-len(value))
+	entry.value = list
+	if len(list) == 0 {
+		cache.removeEntry(key, entry)
+		return value
+	}
+	cache.evictList.MoveToFront(entry.element)
+	return value
+}
+
+// pushSignal returns a channel that is closed when elements are next pushed
+// to any list or stream, or the cache is closed. The caller must hold cache.mu.
+func (cache *LRUCache) pushSignal() <-chan struct{} {
+	if cache.pushed == nil {
+		cache.pushed = make(chan struct{})
+	}
+	return cache.pushed
+}
+
+// signalPush wakes every blocked pop and stream read. The caller must hold cache.mu.
+func (cache *LRUCache) signalPush() {
+	if cache.pushed != nil {
+		close(cache.pushed)
+		cache.pushed = nil
+	}
+}
+
+// replayList applies a list update read from the WAL during recovery
+func (cache *LRUCache) replayList(entry *wal.WAL_Entry, exp expiry) error {
+	switch entry.Type {
+	case wal.EntryTypeLPUSH, wal.EntryTypeRPUSH:
+		var values []string
+		if err := decodeOp(entry.Value, &values); err != nil {
+			return fmt.Errorf("failed to decode list values: %w", err)
+		}
+		cache.applyPush(entry.Key, entry.Type, values, exp)
+	case wal.EntryTypeLPOP, wal.EntryTypeRPOP:
+		cache.applyPop(entry.Key, entry.Type)
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotFound is returned by GetContext for a key that is not cached and
+// could not be loaded. Loaders return it for keys missing from their store.
+var ErrNotFound = errors.New("key not found")
+
+// Loader loads values missing from the cache from a backing store. A ttl of
+// zero stores the value with the cache's default TTL.
+type Loader interface {
+	Load(ctx context.Context, key string) (value any, ttl time.Duration, err error)
+}
+
+// LoaderFunc adapts a function to the Loader interface
+type LoaderFunc func(ctx context.Context, key string) (any, time.Duration, error)
+
+// Load calls f(ctx, key)
+func (f LoaderFunc) Load(ctx context.Context, key string) (any, time.Duration, error) {
+	return f(ctx, key)
+}
+
+// LoaderOption configures how SetLoader's loader is used
+type LoaderOption func(*loaderOptions)
+
+type loaderOptions struct {
+	errorTTL     time.Duration
+	refreshAhead time.Duration
+	timeout      time.Duration
+}
+
+// WithErrorTTL caches load errors, including ErrNotFound, for ttl so that
+// reads of a failing key do not call the loader again until it passes
+func WithErrorTTL(ttl time.Duration) LoaderOption {
+	return func(o *loaderOptions) {
+		o.errorTTL = ttl
+	}
+}
+
+// WithRefreshAhead reloads an entry in the background when a read finds less
+// than d of its TTL left, so hot keys are replaced before they expire
+func WithRefreshAhead(d time.Duration) LoaderOption {
+	return func(o *loaderOptions) {
+		o.refreshAhead = d
+	}
+}
+
+// WithLoadTimeout bounds each call to the loader
+func WithLoadTimeout(timeout time.Duration) LoaderOption {
+	return func(o *loaderOptions) {
+		o.timeout = timeout
+	}
+}
+
+// loadCall is a load in progress, shared by every read missing the key
+type loadCall struct {
+	done  chan struct{}
+	value any
+	err   error
+	// stale is set when the key is written or deleted during the load, so
+	// the loaded value must not replace the newer one
+	stale bool
+}
+
+// loadError is a cached load failure
+type loadError struct {
+	err   error
+	until time.Time
+}
+
+// SetLoader makes reads of missing keys load them with loader, storing the
+// result. Concurrent reads of the same key share one call to the loader.
+// A nil loader turns read-through off.
+func (cache *LRUCache) SetLoader(loader Loader, opts ...LoaderOption) {
+	var o loaderOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	cache.loader = loader
+	cache.loaderOptions = o
+	cache.loadErrors = nil
+}
+
+// GetContext returns the value stored under key, loading it with the loader
+// set by SetLoader on a miss. It returns ErrNotFound for a missing key when
+// there is no loader, the loader's error if loading fails, and ctx.Err() if
+// ctx is done first; the load itself continues for other readers.
+func (cache *LRUCache) GetContext(ctx context.Context, key string) (any, error) {
+	cache.mu.Lock()
+	entry, ok := cache.lookup(key)
+	if ok {
+		cache.metrics.hits.Inc()
+		cache.slide(key, entry)
+		cache.evictList.MoveToFront(entry.element)
+		if cache.shouldRefresh(entry) {
+			cache.startLoad(key)
+		}
+		value := cloneValue(entry.load())
+		cache.unlock()
+		return value, nil
+	}
+
+	cache.metrics.misses.Inc()
+	if cache.loader == nil {
+		cache.unlock()
+		return nil, ErrNotFound
+	}
+	if err := cache.cachedLoadError(key); err != nil {
+		cache.unlock()
+		return nil, err
+	}
+	call := cache.startLoad(key)
+	cache.unlock()
+
+	select {
+	case <-call.done:
+		if call.err != nil {
+			return nil, call.err
+		}
+		return cloneValue(call.value), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// shouldRefresh reports whether entry is close enough to expiring to be
+// reloaded ahead of time. The caller must hold cache.mu.
+func (cache *LRUCache) shouldRefresh(entry *CacheItem) bool {
+	refreshAhead := cache.loaderOptions.refreshAhead
+	if cache.loader == nil || refreshAhead <= 0 || entry.deadline.IsZero() {
+		return false
+	}
+	return entry.deadline.Sub(cache.now()) < refreshAhead
+}
+
+// startLoad returns the load in progress for key, starting one if there is
+// none. The caller must hold cache.mu.
+func (cache *LRUCache) startLoad(key string) *loadCall {
+	if call, ok := cache.loads[key]; ok {
+		return call
+	}
+
+	call := &loadCall{done: make(chan struct{})}
+	if cache.loads == nil {
+		cache.loads = make(map[string]*loadCall)
+	}
+	cache.loads[key] = call
+	cache.metrics.loads.Inc()
+	go cache.load(key, call, cache.loader, cache.loaderOptions)
+	return call
+}
+
+// load calls the loader for key, stores the result unless the key changed
+// meanwhile, and releases the readers waiting on call
+func (cache *LRUCache) load(key string, call *loadCall, loader Loader, opts loaderOptions) {
+	ctx := context.Background()
+	if opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.timeout)
+		defer cancel()
+	}
+
+	value, ttl, err := loader.Load(ctx, key)
+	if err != nil {
+		err = fmt.Errorf("failed to load key %s: %w", key, err)
+	}
+
+	cache.mu.Lock()
+	if cache.loads[key] == call {
+		delete(cache.loads, key)
+	}
+	switch {
+	case err != nil:
+		cache.metrics.loadErrors.Inc()
+		if opts.errorTTL > 0 && !call.stale {
+			cache.rememberLoadError(key, err, opts.errorTTL)
+		}
+	case !call.stale && !cache.closed:
+		// The value is still returned if it cannot be cached, e.g. in a full
+		// noeviction cache
+		cache.set(key, value, ttl, setOptions{})
+	}
+	call.value, call.err = value, err
+	cache.unlock()
+
+	close(call.done)
+}
+
+// cachedLoadError returns the cached failure to load key, if any.
+// The caller must hold cache.mu.
+func (cache *LRUCache) cachedLoadError(key string) error {
+	failure, ok := cache.loadErrors[key]
+	if !ok {
+		return nil
+	}
+	if !cache.now().Before(failure.until) {
+		delete(cache.loadErrors, key)
+		return nil
+	}
+	return failure.err
+}
+
+// rememberLoadError caches a failure to load key for ttl. At most capacity
+// failures are kept. The caller must hold cache.mu.
+func (cache *LRUCache) rememberLoadError(key string, err error, ttl time.Duration) {
+	if cache.loadErrors == nil {
+		cache.loadErrors = make(map[string]loadError)
+	}
+	if _, ok := cache.loadErrors[key]; !ok && len(cache.loadErrors) >= cache.capacity {
+		// Drop an arbitrary failure; it is simply loaded again
+		for k := range cache.loadErrors {
+			delete(cache.loadErrors, k)
+			break
+		}
+	}
+	cache.loadErrors[key] = loadError{err: err, until: cache.now().Add(ttl)}
+}
+
+// invalidateLoad stops a load in progress from storing an outdated value
+// and forgets any cached load failure after key is written or deleted.
+// The caller must hold cache.mu.
+func (cache *LRUCache) invalidateLoad(key string) {
+	if call, ok := cache.loads[key]; ok {
+		call.stale = true
+	}
+	delete(cache.loadErrors, key)
+}
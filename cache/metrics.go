@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/nishanth-gowda/kv-store/metrics"
+)
+
+// cacheMetrics holds the instruments updated by LRUCache
+type cacheMetrics struct {
+	registry         *metrics.Registry
+	hits             *metrics.Counter
+	misses           *metrics.Counter
+	evictions        *metrics.Counter
+	expirations      *metrics.Counter
+	loads            *metrics.Counter
+	loadErrors       *metrics.Counter
+	recoveryDuration atomic.Int64 // time.Duration of the last WAL recovery
+}
+
+func newCacheMetrics(cache *LRUCache) *cacheMetrics {
+	registry := metrics.NewRegistry()
+	m := &cacheMetrics{
+		registry:    registry,
+		hits:        registry.NewCounter("kv_cache_hits_total", "Number of Get calls that found a live key."),
+		misses:      registry.NewCounter("kv_cache_misses_total", "Number of Get calls for missing or expired keys."),
+		evictions:   registry.NewCounter("kv_cache_evictions_total", "Number of entries evicted to stay within capacity."),
+		expirations: registry.NewCounter("kv_cache_expirations_total", "Number of entries removed because their TTL passed."),
+		loads:       registry.NewCounter("kv_cache_loads_total", "Number of calls to the loader."),
+		loadErrors:  registry.NewCounter("kv_cache_load_errors_total", "Number of calls to the loader that failed."),
+	}
+
+	registry.NewGaugeFunc("kv_cache_entries", "Number of entries currently held.", func() float64 {
+		cache.mu.RLock()
+		defer cache.mu.RUnlock()
+		return float64(len(cache.entries))
+	})
+	registry.NewGaugeFunc("kv_cache_bytes", "Approximate size of the held keys and serialized values.", func() float64 {
+		cache.mu.RLock()
+		defer cache.mu.RUnlock()
+		return float64(cache.bytes)
+	})
+	registry.NewGaugeFunc("kv_cache_capacity", "Maximum number of entries.", func() float64 {
+		cache.mu.RLock()
+		defer cache.mu.RUnlock()
+		return float64(cache.capacity)
+	})
+	registry.NewGaugeFunc("kv_wal_recovery_duration_seconds", "Time taken to replay the WAL at startup.", func() float64 {
+		return time.Duration(m.recoveryDuration.Load()).Seconds()
+	})
+
+	return m
+}
+
+// WriteMetrics writes the cache and WAL metrics in the Prometheus text format
+func (cache *LRUCache) WriteMetrics(w io.Writer) error {
+	if err := cache.metrics.registry.WriteText(w); err != nil {
+		return err
+	}
+	if cache.wal != nil {
+		return cache.wal.WriteMetrics(w)
+	}
+	return nil
+}
@@ -0,0 +1,359 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/nishanth-gowda/kv-store/wal"
+)
+
+var (
+	// ErrNamespaceExists is returned by Namespaces.Create for a name in use
+	ErrNamespaceExists = errors.New("namespace already exists")
+
+	// ErrNoNamespace is returned for operations on a missing namespace
+	ErrNoNamespace = errors.New("no such namespace")
+
+	// ErrInvalidNamespace is returned for names that are not 1 to 64
+	// letters, digits, underscores or dashes, or for invalid settings
+	ErrInvalidNamespace = errors.New("namespace names must be 1-64 letters, digits, '_' or '-' and capacity must be positive")
+)
+
+var namespaceName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// NamespaceConfig holds the settings of a namespace
+type NamespaceConfig struct {
+	Capacity       int
+	EvictionPolicy EvictionPolicy
+	DefaultTTL     time.Duration
+}
+
+func (cfg NamespaceConfig) validate() error {
+	if cfg.Capacity <= 0 || cfg.DefaultTTL < 0 {
+		return ErrInvalidNamespace
+	}
+	if _, err := ParseEvictionPolicy(string(cfg.EvictionPolicy)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Namespaces is a set of named caches, each with its own capacity, eviction
+// policy, default TTL and WAL, so that filling one never evicts keys from
+// another. The settings of every namespace are kept in a catalog WAL.
+type Namespaces struct {
+	mu      sync.Mutex
+	dir     string
+	catalog *wal.WAL
+	configs map[string]NamespaceConfig
+	caches  map[string]*LRUCache
+
+	forceSync   bool
+	maxFileSize int
+	maxSegments int
+	// opts are applied to the cache of every namespace
+	opts []Option
+}
+
+// NewNamespaces opens the namespaces stored under directory, recovering
+// each one from its WAL. If directory is empty, namespaces are not persisted.
+// The options configure the cache of each namespace, and WithEncryption the
+// catalog too. WithWALStorage is not supported, since every namespace keeps
+// its WAL in a directory of its own.
+func NewNamespaces(directory string, forceSync bool, maxFileSize int, maxSegments int, opts ...Option) (*Namespaces, error) {
+	ns := &Namespaces{
+		dir:         directory,
+		configs:     make(map[string]NamespaceConfig),
+		caches:      make(map[string]*LRUCache),
+		forceSync:   forceSync,
+		maxFileSize: maxFileSize,
+		maxSegments: maxSegments,
+		opts:        opts,
+	}
+	var options LRUCache
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.walStorage != nil {
+		return nil, fmt.Errorf("namespaces do not support WithWALStorage")
+	}
+	if directory == "" {
+		return ns, nil
+	}
+
+	catalog, err := wal.NewWal(directory, forceSync, maxFileSize, maxSegments, options.walOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize namespace catalog: %w", err)
+	}
+	ns.catalog = catalog
+
+	entries, err := catalog.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read namespace catalog: %w", err)
+	}
+	for _, entry := range entries {
+		switch entry.Type {
+		case wal.EntryTypeSET:
+			var cfg NamespaceConfig
+			if err := decodeOp(entry.Value, &cfg); err != nil {
+				return nil, fmt.Errorf("failed to decode namespace %s: %w", entry.Key, err)
+			}
+			ns.configs[entry.Key] = cfg
+		case wal.EntryTypeDELETE:
+			delete(ns.configs, entry.Key)
+		}
+	}
+
+	for name, cfg := range ns.configs {
+		if _, err := ns.open(name, cfg, false); err != nil {
+			ns.Close()
+			return nil, err
+		}
+	}
+	return ns, nil
+}
+
+// Create adds a namespace with the given settings and returns its cache
+func (ns *Namespaces) Create(name string, cfg NamespaceConfig) (*LRUCache, error) {
+	if !namespaceName.MatchString(name) {
+		return nil, ErrInvalidNamespace
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	if _, ok := ns.configs[name]; ok {
+		return nil, ErrNamespaceExists
+	}
+	// Opening first means a failure leaves nothing in the catalog, and a
+	// crash before it is logged leaves data that is removed on reuse
+	c, err := ns.open(name, cfg, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := ns.logConfig(name, cfg); err != nil {
+		delete(ns.caches, name)
+		c.Close()
+		if ns.dir != "" {
+			os.RemoveAll(ns.dataDir(name))
+		}
+		return nil, err
+	}
+	ns.configs[name] = cfg
+	ns.compactCatalog()
+	return c, nil
+}
+
+// Update changes the settings of a namespace, evicting entries if its
+// capacity shrinks
+func (ns *Namespaces) Update(name string, cfg NamespaceConfig) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	c, ok := ns.caches[name]
+	if !ok {
+		return ErrNoNamespace
+	}
+	if err := ns.logConfig(name, cfg); err != nil {
+		return err
+	}
+	ns.configs[name] = cfg
+	ns.compactCatalog()
+	applyNamespaceConfig(c, cfg)
+	return nil
+}
+
+// Delete closes a namespace and removes its data
+func (ns *Namespaces) Delete(name string) error {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	c, ok := ns.caches[name]
+	if !ok {
+		return ErrNoNamespace
+	}
+	if ns.catalog != nil {
+		if err := ns.catalog.Append(wal.EntryTypeDELETE, name, nil, 0); err != nil {
+			return fmt.Errorf("failed to write to namespace catalog: %w", err)
+		}
+	}
+	delete(ns.configs, name)
+	delete(ns.caches, name)
+	ns.compactCatalog()
+
+	err := c.Close()
+	if ns.dir != "" {
+		// Data left behind by a crash here is removed when the name is reused
+		err = errors.Join(err, os.RemoveAll(ns.dataDir(name)))
+	}
+	return err
+}
+
+// Get returns the cache of a namespace
+func (ns *Namespaces) Get(name string) (*LRUCache, bool) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	c, ok := ns.caches[name]
+	return c, ok
+}
+
+// Config returns the settings of a namespace
+func (ns *Namespaces) Config(name string) (NamespaceConfig, bool) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	cfg, ok := ns.configs[name]
+	return cfg, ok
+}
+
+// Names returns the names of every namespace, sorted
+func (ns *Namespaces) Names() []string {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	names := make([]string, 0, len(ns.configs))
+	for name := range ns.configs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Snapshot writes a snapshot of the catalog and of every namespace
+func (ns *Namespaces) Snapshot() error {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	if ns.catalog == nil {
+		return fmt.Errorf("cannot snapshot: WAL is disabled")
+	}
+
+	var errs []error
+	for name, c := range ns.caches {
+		if err := c.Snapshot(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to snapshot namespace %s: %w", name, err))
+		}
+	}
+	if err := ns.snapshotCatalog(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// snapshotCatalog writes the settings of every namespace to a catalog
+// snapshot. The caller must hold ns.mu.
+func (ns *Namespaces) snapshotCatalog() error {
+	entries := make([]*wal.WAL_Entry, 0, len(ns.configs))
+	for name, cfg := range ns.configs {
+		value, err := encodeNamespaceConfig(cfg)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, &wal.WAL_Entry{Type: wal.EntryTypeSET, Key: name, Value: value})
+	}
+	return ns.catalog.WriteSnapshot(entries)
+}
+
+// compactCatalog snapshots the catalog once it holds more segments than it
+// keeps. A failure leaves them in place and is retried after the next
+// change. The caller must hold ns.mu.
+func (ns *Namespaces) compactCatalog() {
+	if ns.catalog == nil || !ns.catalog.NeedsSnapshot() {
+		return
+	}
+	if err := ns.snapshotCatalog(); err != nil {
+		log.Printf("failed to compact namespace catalog: %v", err)
+	}
+}
+
+// Close closes every namespace and the catalog
+func (ns *Namespaces) Close() error {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	var errs []error
+	for _, c := range ns.caches {
+		errs = append(errs, c.Close())
+	}
+	if ns.catalog != nil {
+		errs = append(errs, ns.catalog.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// open creates the cache of a namespace, removing data left by an earlier
+// namespace of the same name if fresh. The caller must hold ns.mu.
+func (ns *Namespaces) open(name string, cfg NamespaceConfig, fresh bool) (*LRUCache, error) {
+	var dir string
+	if ns.dir != "" {
+		dir = ns.dataDir(name)
+		if fresh {
+			if err := os.RemoveAll(dir); err != nil {
+				return nil, fmt.Errorf("failed to remove stale data of namespace %s: %w", name, err)
+			}
+		}
+	}
+
+	c, err := NewLRUCache(cfg.Capacity, dir, ns.forceSync, ns.maxFileSize, ns.maxSegments, ns.opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open namespace %s: %w", name, err)
+	}
+	applyNamespaceConfig(c, cfg)
+	ns.caches[name] = c
+	return c, nil
+}
+
+// dataDir returns the WAL directory of a namespace. Namespaces live below
+// "data" so their names cannot clash with the catalog's files.
+func (ns *Namespaces) dataDir(name string) string {
+	return filepath.Join(ns.dir, "data", name)
+}
+
+// logConfig writes the settings of a namespace to the catalog.
+// The caller must hold ns.mu.
+func (ns *Namespaces) logConfig(name string, cfg NamespaceConfig) error {
+	if ns.catalog == nil {
+		return nil
+	}
+	value, err := encodeNamespaceConfig(cfg)
+	if err != nil {
+		return err
+	}
+	if err := ns.catalog.Append(wal.EntryTypeSET, name, value, 0); err != nil {
+		return fmt.Errorf("failed to write to namespace catalog: %w", err)
+	}
+	return nil
+}
+
+func encodeNamespaceConfig(cfg NamespaceConfig) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(cfg); err != nil {
+		return nil, fmt.Errorf("failed to encode namespace settings: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// applyNamespaceConfig applies the settings of a namespace to its cache.
+// The policy is set first so that shrinking the capacity honors it.
+func applyNamespaceConfig(c *LRUCache, cfg NamespaceConfig) {
+	c.SetEvictionPolicy(cfg.EvictionPolicy)
+	c.SetCapacity(cfg.Capacity)
+	c.SetDefaultTTL(cfg.DefaultTTL)
+}
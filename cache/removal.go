@@ -0,0 +1,75 @@
+package cache
+
+// RemovalReason tells an OnRemoval callback why a value left the cache
+type RemovalReason int
+
+const (
+	// RemovalEvicted means the entry was evicted to make room for another
+	RemovalEvicted RemovalReason = iota + 1
+	// RemovalExpired means the entry's TTL passed
+	RemovalExpired
+	// RemovalDeleted means the key was deleted, or emptied by removing the
+	// last element of a hash, list or set, in which case the callback
+	// receives a copy holding the elements that were removed last
+	RemovalDeleted
+	// RemovalReplaced means Set or CompareAndSwap stored a new value under
+	// the key; the callback receives the old value
+	RemovalReplaced
+)
+
+// String returns the lowercase name of the reason
+func (r RemovalReason) String() string {
+	switch r {
+	case RemovalEvicted:
+		return "evicted"
+	case RemovalExpired:
+		return "expired"
+	case RemovalDeleted:
+		return "deleted"
+	case RemovalReplaced:
+		return "replaced"
+	default:
+		return "unknown"
+	}
+}
+
+// removal is a value removed while cache.mu was held, reported to the
+// OnRemoval callback once it is released
+type removal struct {
+	key    string
+	value  any
+	reason RemovalReason
+}
+
+// OnRemoval sets a function called with every value that leaves the cache
+// and the reason it left. It runs on the goroutine that removed the value,
+// after the cache lock is released, so it may call back into the cache.
+// Entries dropped while recovering from the WAL are not reported. A nil
+// callback turns reporting off.
+func (cache *LRUCache) OnRemoval(callback func(key string, value any, reason RemovalReason)) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	cache.onRemoval = callback
+}
+
+// removed records value as removed from key for the OnRemoval callback.
+// The caller must hold cache.mu and release it with unlock.
+func (cache *LRUCache) removed(key string, value any, reason RemovalReason) {
+	if cache.onRemoval != nil {
+		cache.removals = append(cache.removals, removal{key: key, value: value, reason: reason})
+	}
+}
+
+// unlock releases cache.mu, then reports the values removed while it was
+// held to the OnRemoval callback, decompressing them outside the lock
+func (cache *LRUCache) unlock() {
+	removals := cache.removals
+	cache.removals = nil
+	callback := cache.onRemoval
+	cache.mu.Unlock()
+
+	for _, r := range removals {
+		callback(r.key, loadValue(r.value), r.reason)
+	}
+}
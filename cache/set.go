@@ -0,0 +1,244 @@
+package cache
+
+import (
+	"encoding/gob"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/nishanth-gowda/kv-store/wal"
+)
+
+// Set is the value stored under a set key: its members mapped to true.
+// Get returns a copy, so modifying it does not change the cache.
+type Set map[string]bool
+
+func init() {
+	// Snapshots store whole sets as SET values
+	gob.Register(Set{})
+}
+
+// SAdd adds members to the set stored under key, creating it if needed, and
+// returns how many were not already members
+func (cache *LRUCache) SAdd(key string, members ...string) (int, error) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	entry, set, err := lookupAs[Set](cache, key)
+	if err != nil {
+		return 0, err
+	}
+
+	// Only new members are logged
+	var added []string
+	for _, member := range members {
+		if !set[member] && !slices.Contains(added, member) {
+			added = append(added, member)
+		}
+	}
+	if len(added) == 0 {
+		return 0, nil
+	}
+
+	exp, err := cache.updateExpiry(entry)
+	if err != nil {
+		return 0, err
+	}
+	if err := cache.appendOp(wal.EntryTypeSADD, key, added, exp.expiresAtUnixNano()); err != nil {
+		return 0, err
+	}
+	cache.applySAdd(key, added, exp)
+	cache.notify(EventSet, key, nil)
+	return len(added), nil
+}
+
+// SRem removes members from the set stored under key and returns how many
+// were members. The key is deleted once its last member is removed.
+func (cache *LRUCache) SRem(key string, members ...string) (int, error) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	entry, set, err := lookupAs[Set](cache, key)
+	if err != nil || entry == nil {
+		return 0, err
+	}
+
+	var removed []string
+	for _, member := range members {
+		if set[member] && !slices.Contains(removed, member) {
+			removed = append(removed, member)
+		}
+	}
+	if len(removed) == 0 {
+		return 0, nil
+	}
+
+	if err := cache.appendOp(wal.EntryTypeSREM, key, removed, entry.expiresAtUnixNano()); err != nil {
+		return 0, err
+	}
+	// Removing the last members deletes the key; the callback gets a copy of them
+	var last Set
+	if len(removed) == len(set) {
+		last = maps.Clone(set)
+	}
+	cache.applySRem(key, removed)
+
+	if _, ok := cache.entries[key]; ok {
+		cache.notify(EventSet, key, nil)
+	} else {
+		cache.removed(key, last, RemovalDeleted)
+		cache.notify(EventDelete, key, nil)
+	}
+	return len(removed), nil
+}
+
+// SIsMember reports whether member belongs to the set stored under key
+func (cache *LRUCache) SIsMember(key, member string) (bool, error) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	entry, set, err := lookupAs[Set](cache, key)
+	if err != nil || entry == nil {
+		return false, err
+	}
+	cache.evictList.MoveToFront(entry.element)
+	return set[member], nil
+}
+
+// SMembers returns the members of the set stored under key, sorted
+func (cache *LRUCache) SMembers(key string) ([]string, error) {
+	return cache.SUnion(key)
+}
+
+// SInter returns the sorted members present in every set stored under keys.
+// A missing key counts as an empty set.
+func (cache *LRUCache) SInter(keys ...string) ([]string, error) {
+	sets, err := cache.lookupSets(keys)
+	if err != nil || len(sets) == 0 {
+		return []string{}, err
+	}
+
+	members := []string{}
+	for member := range sets[0] {
+		inAll := true
+		for _, set := range sets[1:] {
+			if !set[member] {
+				inAll = false
+				break
+			}
+		}
+		if inAll {
+			members = append(members, member)
+		}
+	}
+	slices.Sort(members)
+	return members, nil
+}
+
+// SUnion returns the sorted members present in any set stored under keys
+func (cache *LRUCache) SUnion(keys ...string) ([]string, error) {
+	sets, err := cache.lookupSets(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	union := Set{}
+	for _, set := range sets {
+		for member := range set {
+			union[member] = true
+		}
+	}
+
+	members := make([]string, 0, len(union))
+	for member := range union {
+		members = append(members, member)
+	}
+	slices.Sort(members)
+	return members, nil
+}
+
+// lookupSets returns copies of the sets stored under keys, with missing keys
+// as empty sets, so they can be combined without holding the lock
+func (cache *LRUCache) lookupSets(keys []string) ([]Set, error) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	sets := make([]Set, len(keys))
+	for i, key := range keys {
+		entry, set, err := lookupAs[Set](cache, key)
+		if err != nil {
+			return nil, err
+		}
+		if entry != nil {
+			cache.evictList.MoveToFront(entry.element)
+		}
+		sets[i] = cloneValue(set).(Set)
+	}
+	return sets, nil
+}
+
+// applySAdd adds members to the set under key, creating it with exp if it
+// does not exist. The caller must hold cache.mu.
+func (cache *LRUCache) applySAdd(key string, members []string, exp expiry) {
+	entry, ok := cache.entries[key]
+	var set Set
+	if ok {
+		set, ok = entry.value.(Set)
+	}
+	if !ok {
+		set = Set{}
+		entry = cache.store(key, set, exp, len(key))
+	}
+
+	size := entry.size
+	for _, member := range members {
+		if !set[member] {
+			set[member] = true
+			size += len(member)
+		}
+	}
+	cache.resize(entry, size)
+	cache.evictList.MoveToFront(entry.element)
+}
+
+// applySRem removes members from the set under key, deleting the key when it
+// becomes empty. The caller must hold cache.mu.
+func (cache *LRUCache) applySRem(key string, members []string) {
+	entry, ok := cache.entries[key]
+	if !ok {
+		return
+	}
+	set, ok := entry.value.(Set)
+	if !ok {
+		return
+	}
+
+	size := entry.size
+	for _, member := range members {
+		if set[member] {
+			delete(set, member)
+			size -= len(member)
+		}
+	}
+	cache.resize(entry, size)
+
+	if len(set) == 0 {
+		cache.removeEntry(key, entry)
+	}
+}
+
+// replaySet applies a set update read from the WAL during recovery
+func (cache *LRUCache) replaySet(entry *wal.WAL_Entry, exp expiry) error {
+	var members []string
+	if err := decodeOp(entry.Value, &members); err != nil {
+		return fmt.Errorf("failed to decode set members: %w", err)
+	}
+
+	switch entry.Type {
+	case wal.EntryTypeSADD:
+		cache.applySAdd(entry.Key, members, exp)
+	case wal.EntryTypeSREM:
+		cache.applySRem(entry.Key, members)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package cache
+
+import "math/rand/v2"
+
+const (
+	// skipListMaxLevel bounds the height of a node; 32 levels comfortably
+	// index 2^64 elements with skipListP = 1/4
+	skipListMaxLevel = 32
+	skipListP        = 0.25
+)
+
+// skipList keeps sorted set members ordered by score, then by member. Each
+// forward link records how many nodes it skips so ranks are O(log n).
+type skipList struct {
+	head   *skipListNode
+	length int
+	level  int
+}
+
+type skipListNode struct {
+	member string
+	score  float64
+	levels []skipListLevel
+}
+
+type skipListLevel struct {
+	forward *skipListNode
+	span    int
+}
+
+func newSkipList() *skipList {
+	return &skipList{
+		head:  &skipListNode{levels: make([]skipListLevel, skipListMaxLevel)},
+		level: 1,
+	}
+}
+
+// less reports whether (score, member) sorts before node
+func (node *skipListNode) less(score float64, member string) bool {
+	return node.score < score || (node.score == score && node.member < member)
+}
+
+func randomLevel() int {
+	level := 1
+	for level < skipListMaxLevel && rand.Float64() < skipListP {
+		level++
+	}
+	return level
+}
+
+// insert adds a member that is not already in the list
+func (sl *skipList) insert(member string, score float64) {
+	var update [skipListMaxLevel]*skipListNode
+	var rank [skipListMaxLevel]int
+
+	node := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		if i < sl.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for next := node.levels[i].forward; next != nil && next.less(score, member); next = node.levels[i].forward {
+			rank[i] += node.levels[i].span
+			node = next
+		}
+		update[i] = node
+	}
+
+	level := randomLevel()
+	if level > sl.level {
+		for i := sl.level; i < level; i++ {
+			rank[i] = 0
+			update[i] = sl.head
+			update[i].levels[i].span = sl.length
+		}
+		sl.level = level
+	}
+
+	node = &skipListNode{member: member, score: score, levels: make([]skipListLevel, level)}
+	for i := 0; i < level; i++ {
+		node.levels[i].forward = update[i].levels[i].forward
+		update[i].levels[i].forward = node
+		node.levels[i].span = update[i].levels[i].span - (rank[0] - rank[i])
+		update[i].levels[i].span = rank[0] - rank[i] + 1
+	}
+	for i := level; i < sl.level; i++ {
+		update[i].levels[i].span++
+	}
+	sl.length++
+}
+
+// delete removes the member with the given score and reports whether it was found
+func (sl *skipList) delete(member string, score float64) bool {
+	var update [skipListMaxLevel]*skipListNode
+
+	node := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for next := node.levels[i].forward; next != nil && next.less(score, member); next = node.levels[i].forward {
+			node = next
+		}
+		update[i] = node
+	}
+
+	node = node.levels[0].forward
+	if node == nil || node.score != score || node.member != member {
+		return false
+	}
+
+	for i := 0; i < sl.level; i++ {
+		if update[i].levels[i].forward == node {
+			update[i].levels[i].span += node.levels[i].span - 1
+			update[i].levels[i].forward = node.levels[i].forward
+		} else {
+			update[i].levels[i].span--
+		}
+	}
+	for sl.level > 1 && sl.head.levels[sl.level-1].forward == nil {
+		sl.level--
+	}
+	sl.length--
+	return true
+}
+
+// rank returns the 0-based position of the member with the given score, or -1
+func (sl *skipList) rank(member string, score float64) int {
+	rank := 0
+	node := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for next := node.levels[i].forward; next != nil && (next.less(score, member) || (next.score == score && next.member == member)); next = node.levels[i].forward {
+			rank += node.levels[i].span
+			node = next
+		}
+		if node != sl.head && node.member == member {
+			return rank - 1
+		}
+	}
+	return -1
+}
+
+// byRank returns the node at a 0-based position, or nil if out of range
+func (sl *skipList) byRank(rank int) *skipListNode {
+	if rank < 0 || rank >= sl.length {
+		return nil
+	}
+
+	traversed := 0
+	node := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for node.levels[i].forward != nil && traversed+node.levels[i].span <= rank+1 {
+			traversed += node.levels[i].span
+			node = node.levels[i].forward
+		}
+		if traversed == rank+1 {
+			return node
+		}
+	}
+	return nil
+}
+
+// firstFrom returns the first node with a score of at least minScore, or nil
+func (sl *skipList) firstFrom(minScore float64) *skipListNode {
+	node := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for next := node.levels[i].forward; next != nil && next.score < minScore; next = node.levels[i].forward {
+			node = next
+		}
+	}
+	return node.levels[0].forward
+}
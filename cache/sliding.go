@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultSlidingGranularity is how far reads may move the deadline of a key
+// with sliding expiration before the new deadline is written to the WAL
+const DefaultSlidingGranularity = time.Second
+
+// SetOption configures a single call to Set
+type SetOption func(*setOptions)
+
+type setOptions struct {
+	sliding bool
+}
+
+// WithSlidingExpiration makes the TTL of the key restart whenever it is read
+// with Get or touched, so it expires only once it has gone a full TTL
+// without being accessed. It has no effect on keys stored without a TTL.
+func WithSlidingExpiration() SetOption {
+	return func(opts *setOptions) {
+		opts.sliding = true
+	}
+}
+
+// SlidingGranularity returns how far reads may extend a sliding expiration
+// before the extension is written to the WAL
+func (cache *LRUCache) SlidingGranularity() time.Duration {
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+
+	return cache.slidingGranularity
+}
+
+// SetSlidingGranularity sets how far reads may extend a sliding expiration
+// before the extension is written to the WAL. A key recovered after a crash
+// may expire up to this much earlier than it would have. A granularity of
+// zero logs every extension.
+func (cache *LRUCache) SetSlidingGranularity(granularity time.Duration) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	cache.slidingGranularity = granularity
+}
+
+// slide restarts the TTL of an entry with sliding expiration after a read,
+// logging the new deadline once it is at least the sliding granularity past
+// the one last logged. The caller must hold cache.mu.
+func (cache *LRUCache) slide(key string, entry *CacheItem) {
+	if !entry.sliding {
+		return
+	}
+	entry.deadline = cache.now().Add(entry.ttl)
+	if entry.deadline.Sub(entry.logged) < cache.slidingGranularity {
+		return
+	}
+	if err := cache.logExpiry(key, entry.expiry); err != nil {
+		// The read still succeeds; recovery falls back to the last logged deadline
+		fmt.Printf("Warning: failed to log sliding expiration for key %s: %v\n", key, err)
+		return
+	}
+	entry.logged = entry.deadline
+}
+
+// syncDeadline logs the deadline of the entry under key if reads have
+// extended it since it was last logged. Updates log the deadline of the
+// value they change, which recovery must have seen to keep the value.
+// The caller must hold cache.mu.
+func (cache *LRUCache) syncDeadline(key string) error {
+	entry, ok := cache.entries[key]
+	if !ok || entry.deadline.Equal(entry.logged) {
+		return nil
+	}
+	if err := cache.logExpiry(key, entry.expiry); err != nil {
+		return err
+	}
+	entry.logged = entry.deadline
+	return nil
+}
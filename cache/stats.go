@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/nishanth-gowda/kv-store/wal"
+)
+
+// Stats is a point-in-time view of the cache counters and occupancy
+type Stats struct {
+	Hits        uint64
+	Misses      uint64
+	Evictions   uint64
+	Expirations uint64
+
+	// Size is the number of entries held, including expired entries not yet removed
+	Size     int
+	Capacity int
+	// Bytes is the approximate size of the held keys and serialized values
+	Bytes int64
+	// OldestEntryAge is the time since the least recently written entry was
+	// set or recovered; zero when the cache is empty
+	OldestEntryAge time.Duration
+
+	// WAL is nil when the WAL is disabled
+	WAL *wal.Stats
+}
+
+// statsCounters holds the counters that ResetStats zeroes
+type statsCounters struct {
+	hits, misses, evictions, expirations uint64
+}
+
+// HitRatio returns the fraction of Get calls that found a live key
+func (s Stats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// Stats returns the current counters, occupancy and WAL state
+func (cache *LRUCache) Stats() (Stats, error) {
+	cache.mu.RLock()
+	counters := cache.counters()
+	baseline := cache.statsBaseline
+	stats := Stats{
+		Hits:        counters.hits - baseline.hits,
+		Misses:      counters.misses - baseline.misses,
+		Evictions:   counters.evictions - baseline.evictions,
+		Expirations: counters.expirations - baseline.expirations,
+		Size:        len(cache.entries),
+		Capacity:    cache.capacity,
+		Bytes:       cache.bytes,
+	}
+
+	var oldest time.Time
+	for _, entry := range cache.entries {
+		if oldest.IsZero() || entry.createdAt.Before(oldest) {
+			oldest = entry.createdAt
+		}
+	}
+	cache.mu.RUnlock()
+
+	if !oldest.IsZero() {
+		stats.OldestEntryAge = cache.now().Sub(oldest)
+	}
+
+	if cache.wal != nil {
+		walStats, err := cache.wal.Stats()
+		if err != nil {
+			return Stats{}, err
+		}
+		stats.WAL = &walStats
+	}
+
+	return stats, nil
+}
+
+// ResetStats sets the hit, miss, eviction and expiration counters of Stats
+// back to zero. The Prometheus counters keep counting, since scrapers expect
+// them never to decrease.
+func (cache *LRUCache) ResetStats() {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	cache.statsBaseline = cache.counters()
+}
+
+// counters returns the current values of the counters behind Stats
+func (cache *LRUCache) counters() statsCounters {
+	return statsCounters{
+		hits:        cache.metrics.hits.Value(),
+		misses:      cache.metrics.misses.Value(),
+		evictions:   cache.metrics.evictions.Value(),
+		expirations: cache.metrics.expirations.Value(),
+	}
+}
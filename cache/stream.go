@@ -0,0 +1,701 @@
+package cache
+
+import (
+	"cmp"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nishanth-gowda/kv-store/wal"
+)
+
+var (
+	// ErrInvalidStreamID is returned by ParseStreamID for malformed IDs
+	ErrInvalidStreamID = errors.New("invalid stream ID")
+
+	// ErrStreamIDTooSmall is returned by XAdd when an explicit ID is not
+	// greater than the last ID in the stream
+	ErrStreamIDTooSmall = errors.New("stream ID must be greater than the last ID in the stream")
+
+	// ErrNoGroup is returned when a consumer group or its stream does not exist
+	ErrNoGroup = errors.New("no such stream or consumer group")
+
+	// ErrGroupExists is returned by XGroupCreate when the group already exists
+	ErrGroupExists = errors.New("consumer group already exists")
+)
+
+// StreamID identifies a stream entry by the millisecond it was added and a
+// sequence number for entries added in the same millisecond
+type StreamID struct {
+	Ms  uint64
+	Seq uint64
+}
+
+// LastStreamID stands for the last ID in a stream at the time of the call
+// when passed to XReadBlock or XGroupCreate, like $ in Redis
+var LastStreamID = StreamID{Ms: math.MaxUint64, Seq: math.MaxUint64}
+
+// ParseStreamID parses an ID of the form "ms-seq", or "ms" for sequence 0
+func ParseStreamID(s string) (StreamID, error) {
+	msPart, seqPart, hasSeq := strings.Cut(s, "-")
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return StreamID{}, ErrInvalidStreamID
+	}
+	var seq uint64
+	if hasSeq {
+		if seq, err = strconv.ParseUint(seqPart, 10, 64); err != nil {
+			return StreamID{}, ErrInvalidStreamID
+		}
+	}
+	return StreamID{Ms: ms, Seq: seq}, nil
+}
+
+// String formats the ID as "ms-seq"
+func (id StreamID) String() string {
+	return strconv.FormatUint(id.Ms, 10) + "-" + strconv.FormatUint(id.Seq, 10)
+}
+
+// Compare returns -1, 0 or +1 as id sorts before, equal to or after other
+func (id StreamID) Compare(other StreamID) int {
+	if c := cmp.Compare(id.Ms, other.Ms); c != 0 {
+		return c
+	}
+	return cmp.Compare(id.Seq, other.Seq)
+}
+
+// StreamEntry is one entry of a stream
+type StreamEntry struct {
+	ID     StreamID
+	Fields map[string]string
+}
+
+// PendingEntry is an entry delivered to a consumer and not yet acknowledged
+type PendingEntry struct {
+	ID          StreamID
+	Consumer    string
+	Deliveries  int
+	DeliveredAt time.Time
+}
+
+// ConsumerGroup tracks the last entry delivered to a group and the entries
+// its consumers have not acknowledged
+type ConsumerGroup struct {
+	LastDelivered StreamID
+	Pending       map[StreamID]*PendingEntry
+}
+
+// Stream is the value stored under a stream key: entries in ascending ID
+// order and the consumer groups reading them.
+// Get returns a copy, so modifying it does not change the cache.
+type Stream struct {
+	Entries []StreamEntry
+	LastID  StreamID
+	Groups  map[string]*ConsumerGroup
+}
+
+// streamGroupOp is the WAL payload of an XGROUP record
+type streamGroupOp struct {
+	Group string
+	Start StreamID
+}
+
+// streamDelivery is the WAL payload of an XCLAIM record. Like Redis, reads
+// by a consumer group are logged as claims of the delivered entries.
+type streamDelivery struct {
+	Group    string
+	Consumer string
+	IDs      []StreamID
+	At       time.Time
+}
+
+// streamAck is the WAL payload of an XACK record
+type streamAck struct {
+	Group string
+	IDs   []StreamID
+}
+
+// pendingEntrySize approximates the memory used by one pending entry
+const pendingEntrySize = 48
+
+func init() {
+	// Snapshots store whole streams as SET values
+	gob.Register(&Stream{})
+}
+
+// nextStreamID returns the ID for an entry added at now after last
+func nextStreamID(last StreamID, now time.Time) (StreamID, error) {
+	if ms := uint64(now.UnixMilli()); ms > last.Ms {
+		return StreamID{Ms: ms}, nil
+	}
+	switch {
+	case last.Seq < math.MaxUint64:
+		return StreamID{Ms: last.Ms, Seq: last.Seq + 1}, nil
+	case last.Ms < math.MaxUint64:
+		return StreamID{Ms: last.Ms + 1}, nil
+	}
+	return StreamID{}, ErrStreamIDTooSmall
+}
+
+func streamEntrySize(entry StreamEntry) int {
+	size := 16
+	for field, value := range entry.Fields {
+		size += len(field) + len(value)
+	}
+	return size
+}
+
+// search returns the index of the first entry with an ID of at least id
+func (s *Stream) search(id StreamID) int {
+	return sort.Search(len(s.Entries), func(i int) bool {
+		return s.Entries[i].ID.Compare(id) >= 0
+	})
+}
+
+// after returns copies of up to count entries with IDs greater than id,
+// or every such entry if count is not positive
+func (s *Stream) after(id StreamID, count int) []StreamEntry {
+	i := s.search(id)
+	if i < len(s.Entries) && s.Entries[i].ID == id {
+		i++
+	}
+	end := len(s.Entries)
+	if count > 0 {
+		end = min(end, i+count)
+	}
+	return cloneStreamEntries(s.Entries[i:end])
+}
+
+// find returns the entry with the given ID
+func (s *Stream) find(id StreamID) (StreamEntry, bool) {
+	i := s.search(id)
+	if i < len(s.Entries) && s.Entries[i].ID == id {
+		return s.Entries[i], true
+	}
+	return StreamEntry{}, false
+}
+
+func (s *Stream) clone() *Stream {
+	clone := &Stream{
+		Entries: cloneStreamEntries(s.Entries),
+		LastID:  s.LastID,
+		Groups:  make(map[string]*ConsumerGroup, len(s.Groups)),
+	}
+	for name, group := range s.Groups {
+		pending := make(map[StreamID]*PendingEntry, len(group.Pending))
+		for id, pe := range group.Pending {
+			copied := *pe
+			pending[id] = &copied
+		}
+		clone.Groups[name] = &ConsumerGroup{LastDelivered: group.LastDelivered, Pending: pending}
+	}
+	return clone
+}
+
+func cloneStreamEntries(entries []StreamEntry) []StreamEntry {
+	clones := make([]StreamEntry, len(entries))
+	for i, entry := range entries {
+		clones[i] = StreamEntry{ID: entry.ID, Fields: maps.Clone(entry.Fields)}
+	}
+	return clones
+}
+
+// XAdd appends an entry with the given fields to the stream stored under
+// key, creating it if needed, and returns its ID. A zero id generates one
+// from the current time; an explicit id must be greater than the last ID.
+func (cache *LRUCache) XAdd(key string, id StreamID, fields map[string]string) (StreamID, error) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	entry, stream, err := lookupAs[*Stream](cache, key)
+	if err != nil {
+		return StreamID{}, err
+	}
+	var last StreamID
+	if stream != nil {
+		last = stream.LastID
+	}
+
+	if id == (StreamID{}) {
+		if id, err = nextStreamID(last, cache.now()); err != nil {
+			return StreamID{}, err
+		}
+	} else if id.Compare(last) <= 0 {
+		return StreamID{}, ErrStreamIDTooSmall
+	}
+
+	// The generated ID is logged so replay restores the same one
+	added := StreamEntry{ID: id, Fields: maps.Clone(fields)}
+	exp, err := cache.updateExpiry(entry)
+	if err != nil {
+		return StreamID{}, err
+	}
+	if err := cache.appendOp(wal.EntryTypeXADD, key, added, exp.expiresAtUnixNano()); err != nil {
+		return StreamID{}, err
+	}
+	cache.applyXAdd(key, added, exp)
+	cache.notify(EventSet, key, nil)
+	cache.signalPush()
+	return id, nil
+}
+
+// XLen returns the number of entries in the stream stored under key
+func (cache *LRUCache) XLen(key string) (int, error) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	_, stream, err := lookupAs[*Stream](cache, key)
+	if err != nil || stream == nil {
+		return 0, err
+	}
+	return len(stream.Entries), nil
+}
+
+// XRange returns up to count entries of the stream stored under key with
+// IDs between start and end inclusive, or every such entry if count is not
+// positive
+func (cache *LRUCache) XRange(key string, start, end StreamID, count int) ([]StreamEntry, error) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	entry, stream, err := lookupAs[*Stream](cache, key)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return []StreamEntry{}, nil
+	}
+	cache.evictList.MoveToFront(entry.element)
+
+	i := stream.search(start)
+	j := i
+	for j < len(stream.Entries) && stream.Entries[j].ID.Compare(end) <= 0 && (count <= 0 || j-i < count) {
+		j++
+	}
+	return cloneStreamEntries(stream.Entries[i:j]), nil
+}
+
+// XRead returns up to count entries from each stream in streams with IDs
+// greater than the one given for it. Streams with no such entries are left
+// out of the result.
+func (cache *LRUCache) XRead(streams map[string]StreamID, count int) (map[string][]StreamEntry, error) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	return cache.readStreams(streams, count)
+}
+
+// XReadBlock is XRead waiting up to timeout for entries to be added if there
+// are none yet. A timeout of zero waits indefinitely. LastStreamID reads
+// only entries added after the call. The result is empty if the timeout
+// passed; an error is returned if ctx is done or the cache is closed.
+func (cache *LRUCache) XReadBlock(ctx context.Context, timeout time.Duration, streams map[string]StreamID, count int) (map[string][]StreamEntry, error) {
+	after := make(map[string]StreamID, len(streams))
+	cache.mu.Lock()
+	for key, id := range streams {
+		if id == LastStreamID {
+			_, stream, err := lookupAs[*Stream](cache, key)
+			if err != nil {
+				cache.unlock()
+				return nil, err
+			}
+			id = StreamID{}
+			if stream != nil {
+				id = stream.LastID
+			}
+		}
+		after[key] = id
+	}
+	cache.unlock()
+
+	return cache.waitForStreams(ctx, timeout, func() (map[string][]StreamEntry, error) {
+		return cache.readStreams(after, count)
+	})
+}
+
+// XGroupCreate creates a consumer group on the stream stored under key,
+// creating an empty stream if needed. The group is delivered entries with
+// IDs greater than start; LastStreamID delivers only entries added later.
+func (cache *LRUCache) XGroupCreate(key, group string, start StreamID) error {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	entry, stream, err := lookupAs[*Stream](cache, key)
+	if err != nil {
+		return err
+	}
+	if stream != nil && stream.Groups[group] != nil {
+		return ErrGroupExists
+	}
+	if start == LastStreamID {
+		start = StreamID{}
+		if stream != nil {
+			start = stream.LastID
+		}
+	}
+
+	op := streamGroupOp{Group: group, Start: start}
+	exp, err := cache.updateExpiry(entry)
+	if err != nil {
+		return err
+	}
+	if err := cache.appendOp(wal.EntryTypeXGROUP, key, op, exp.expiresAtUnixNano()); err != nil {
+		return err
+	}
+	cache.applyXGroup(key, op, exp)
+	cache.notify(EventSet, key, nil)
+	return nil
+}
+
+// XReadGroup delivers up to count entries from each stream under keys that
+// group has not delivered yet to consumer, recording them as pending until
+// acknowledged with XAck. Streams with no new entries are left out of the
+// result. ErrNoGroup is returned if any stream lacks the group.
+func (cache *LRUCache) XReadGroup(group, consumer string, count int, keys ...string) (map[string][]StreamEntry, error) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	return cache.readGroup(group, consumer, count, keys)
+}
+
+// XReadGroupBlock is XReadGroup waiting up to timeout for entries to be
+// added if there are none yet, like XReadBlock
+func (cache *LRUCache) XReadGroupBlock(ctx context.Context, timeout time.Duration, group, consumer string, count int, keys ...string) (map[string][]StreamEntry, error) {
+	return cache.waitForStreams(ctx, timeout, func() (map[string][]StreamEntry, error) {
+		return cache.readGroup(group, consumer, count, keys)
+	})
+}
+
+// XAck acknowledges entries delivered to group, removing them from its
+// pending entries, and returns how many were pending
+func (cache *LRUCache) XAck(key, group string, ids ...StreamID) (int, error) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	entry, stream, err := lookupAs[*Stream](cache, key)
+	if err != nil || entry == nil || stream.Groups[group] == nil {
+		return 0, err
+	}
+
+	pending := stream.Groups[group].Pending
+	var acked []StreamID
+	for _, id := range ids {
+		if pending[id] != nil && !slices.Contains(acked, id) {
+			acked = append(acked, id)
+		}
+	}
+	if len(acked) == 0 {
+		return 0, nil
+	}
+
+	ack := streamAck{Group: group, IDs: acked}
+	if err := cache.appendOp(wal.EntryTypeXACK, key, ack, entry.expiresAtUnixNano()); err != nil {
+		return 0, err
+	}
+	cache.applyXAck(key, ack)
+	cache.notify(EventSet, key, nil)
+	return len(acked), nil
+}
+
+// XPending returns the entries delivered to group and not yet acknowledged,
+// in ID order
+func (cache *LRUCache) XPending(key, group string) ([]PendingEntry, error) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	_, stream, err := lookupAs[*Stream](cache, key)
+	if err != nil {
+		return nil, err
+	}
+	if stream == nil || stream.Groups[group] == nil {
+		return nil, ErrNoGroup
+	}
+
+	pending := make([]PendingEntry, 0, len(stream.Groups[group].Pending))
+	for _, pe := range stream.Groups[group].Pending {
+		pending = append(pending, *pe)
+	}
+	slices.SortFunc(pending, func(a, b PendingEntry) int {
+		return a.ID.Compare(b.ID)
+	})
+	return pending, nil
+}
+
+// XClaim transfers the pending entries among ids that have not been
+// delivered for at least minIdle to consumer and returns them, so entries
+// left unacknowledged by a failed consumer can be processed by another
+func (cache *LRUCache) XClaim(key, group, consumer string, minIdle time.Duration, ids ...StreamID) ([]StreamEntry, error) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	entry, stream, err := lookupAs[*Stream](cache, key)
+	if err != nil {
+		return nil, err
+	}
+	if stream == nil || stream.Groups[group] == nil {
+		return nil, ErrNoGroup
+	}
+
+	now := cache.now()
+	pending := stream.Groups[group].Pending
+	claimed := []StreamEntry{}
+	var claimedIDs []StreamID
+	for _, id := range ids {
+		pe := pending[id]
+		if pe == nil || now.Sub(pe.DeliveredAt) < minIdle || slices.Contains(claimedIDs, id) {
+			continue
+		}
+		if found, ok := stream.find(id); ok {
+			claimed = append(claimed, StreamEntry{ID: id, Fields: maps.Clone(found.Fields)})
+			claimedIDs = append(claimedIDs, id)
+		}
+	}
+	if len(claimedIDs) == 0 {
+		return claimed, nil
+	}
+
+	delivery := streamDelivery{Group: group, Consumer: consumer, IDs: claimedIDs, At: now}
+	if err := cache.appendOp(wal.EntryTypeXCLAIM, key, delivery, entry.expiresAtUnixNano()); err != nil {
+		return nil, err
+	}
+	cache.applyXClaim(key, delivery)
+	cache.notify(EventSet, key, nil)
+	return claimed, nil
+}
+
+// readStreams returns the entries after the given ID of each stream.
+// The caller must hold cache.mu.
+func (cache *LRUCache) readStreams(streams map[string]StreamID, count int) (map[string][]StreamEntry, error) {
+	result := make(map[string][]StreamEntry)
+	for key, id := range streams {
+		entry, stream, err := lookupAs[*Stream](cache, key)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			continue
+		}
+		cache.evictList.MoveToFront(entry.element)
+		if entries := stream.after(id, count); len(entries) > 0 {
+			result[key] = entries
+		}
+	}
+	return result, nil
+}
+
+// readGroup logs and delivers the entries group has not delivered yet from
+// each stream under keys. The caller must hold cache.mu.
+func (cache *LRUCache) readGroup(group, consumer string, count int, keys []string) (map[string][]StreamEntry, error) {
+	// Check every stream first so a missing group delivers nothing
+	for _, key := range keys {
+		_, stream, err := lookupAs[*Stream](cache, key)
+		if err != nil {
+			return nil, err
+		}
+		if stream == nil || stream.Groups[group] == nil {
+			return nil, ErrNoGroup
+		}
+	}
+
+	now := cache.now()
+	result := make(map[string][]StreamEntry)
+	for _, key := range keys {
+		entry, stream, _ := lookupAs[*Stream](cache, key)
+		entries := stream.after(stream.Groups[group].LastDelivered, count)
+		if len(entries) == 0 {
+			continue
+		}
+
+		delivery := streamDelivery{Group: group, Consumer: consumer, At: now}
+		for _, e := range entries {
+			delivery.IDs = append(delivery.IDs, e.ID)
+		}
+		if err := cache.appendOp(wal.EntryTypeXCLAIM, key, delivery, entry.expiresAtUnixNano()); err != nil {
+			return nil, err
+		}
+		cache.applyXClaim(key, delivery)
+		cache.notify(EventSet, key, nil)
+		result[key] = entries
+	}
+	return result, nil
+}
+
+// waitForStreams calls read with cache.mu held until it returns entries,
+// waiting for entries to be added between attempts. It returns an empty
+// result once timeout passes, zero meaning no timeout.
+func (cache *LRUCache) waitForStreams(ctx context.Context, timeout time.Duration, read func() (map[string][]StreamEntry, error)) (map[string][]StreamEntry, error) {
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	for {
+		cache.mu.Lock()
+		if cache.closed {
+			cache.unlock()
+			return nil, ErrClosed
+		}
+		result, err := read()
+		if err != nil || len(result) > 0 {
+			cache.unlock()
+			return result, err
+		}
+		pushed := cache.pushSignal()
+		cache.unlock()
+
+		select {
+		case <-pushed:
+		case <-expired:
+			return map[string][]StreamEntry{}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// liveStream returns the stream under key, creating it with exp if it does
+// not exist. The caller must hold cache.mu.
+func (cache *LRUCache) liveStream(key string, exp expiry) (*CacheItem, *Stream) {
+	entry, ok := cache.entries[key]
+	var stream *Stream
+	if ok {
+		stream, ok = entry.value.(*Stream)
+	}
+	if !ok {
+		stream = &Stream{Groups: make(map[string]*ConsumerGroup)}
+		entry = cache.store(key, stream, exp, len(key))
+	}
+	return entry, stream
+}
+
+// existingGroup returns the stream under key and its group, or nil if
+// either does not exist. The caller must hold cache.mu.
+func (cache *LRUCache) existingGroup(key, group string) (*CacheItem, *ConsumerGroup) {
+	entry, ok := cache.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	stream, ok := entry.value.(*Stream)
+	if !ok || stream.Groups[group] == nil {
+		return nil, nil
+	}
+	return entry, stream.Groups[group]
+}
+
+// applyXAdd appends added to the stream under key, creating it with exp if
+// it does not exist. The caller must hold cache.mu.
+func (cache *LRUCache) applyXAdd(key string, added StreamEntry, exp expiry) {
+	entry, stream := cache.liveStream(key, exp)
+	if added.ID.Compare(stream.LastID) <= 0 {
+		return
+	}
+	stream.Entries = append(stream.Entries, added)
+	stream.LastID = added.ID
+	cache.resize(entry, entry.size+streamEntrySize(added))
+	cache.evictList.MoveToFront(entry.element)
+}
+
+// applyXGroup creates a consumer group on the stream under key, creating the
+// stream with exp if it does not exist. The caller must hold cache.mu.
+func (cache *LRUCache) applyXGroup(key string, op streamGroupOp, exp expiry) {
+	entry, stream := cache.liveStream(key, exp)
+	if stream.Groups == nil {
+		// Gob decodes an empty map as nil
+		stream.Groups = make(map[string]*ConsumerGroup)
+	}
+	if stream.Groups[op.Group] != nil {
+		return
+	}
+	stream.Groups[op.Group] = &ConsumerGroup{LastDelivered: op.Start, Pending: make(map[StreamID]*PendingEntry)}
+	cache.resize(entry, entry.size+len(op.Group)+16)
+	cache.evictList.MoveToFront(entry.element)
+}
+
+// applyXClaim records the entries in delivery as pending for its consumer.
+// The caller must hold cache.mu.
+func (cache *LRUCache) applyXClaim(key string, delivery streamDelivery) {
+	entry, group := cache.existingGroup(key, delivery.Group)
+	if group == nil {
+		return
+	}
+	if group.Pending == nil {
+		group.Pending = make(map[StreamID]*PendingEntry)
+	}
+
+	size := entry.size
+	for _, id := range delivery.IDs {
+		pe := group.Pending[id]
+		if pe == nil {
+			pe = &PendingEntry{ID: id}
+			group.Pending[id] = pe
+			size += pendingEntrySize
+		}
+		pe.Consumer = delivery.Consumer
+		pe.Deliveries++
+		pe.DeliveredAt = delivery.At
+		if id.Compare(group.LastDelivered) > 0 {
+			group.LastDelivered = id
+		}
+	}
+	cache.resize(entry, size)
+	cache.evictList.MoveToFront(entry.element)
+}
+
+// applyXAck removes acknowledged entries from a group's pending entries.
+// The caller must hold cache.mu.
+func (cache *LRUCache) applyXAck(key string, ack streamAck) {
+	entry, group := cache.existingGroup(key, ack.Group)
+	if group == nil {
+		return
+	}
+
+	size := entry.size
+	for _, id := range ack.IDs {
+		if group.Pending[id] != nil {
+			delete(group.Pending, id)
+			size -= pendingEntrySize
+		}
+	}
+	cache.resize(entry, size)
+}
+
+// replayStream applies a stream update read from the WAL during recovery
+func (cache *LRUCache) replayStream(entry *wal.WAL_Entry, exp expiry) error {
+	var err error
+	switch entry.Type {
+	case wal.EntryTypeXADD:
+		var added StreamEntry
+		if err = decodeOp(entry.Value, &added); err == nil {
+			cache.applyXAdd(entry.Key, added, exp)
+		}
+	case wal.EntryTypeXGROUP:
+		var op streamGroupOp
+		if err = decodeOp(entry.Value, &op); err == nil {
+			cache.applyXGroup(entry.Key, op, exp)
+		}
+	case wal.EntryTypeXCLAIM:
+		var delivery streamDelivery
+		if err = decodeOp(entry.Value, &delivery); err == nil {
+			cache.applyXClaim(entry.Key, delivery)
+		}
+	case wal.EntryTypeXACK:
+		var ack streamAck
+		if err = decodeOp(entry.Value, &ack); err == nil {
+			cache.applyXAck(entry.Key, ack)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to decode stream update: %w", err)
+	}
+	return nil
+}
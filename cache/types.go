@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/nishanth-gowda/kv-store/wal"
+)
+
+// ErrWrongType is returned when an operation is applied to a key holding a
+// different kind of value, such as a hash operation on a string
+var ErrWrongType = errors.New("operation against a key holding the wrong kind of value")
+
+// dataTypeReplays applies the data type updates read from the WAL during
+// recovery, given the expiry logged with the update
+var dataTypeReplays = map[wal.EntryType]func(*LRUCache, *wal.WAL_Entry, expiry) error{
+	wal.EntryTypeHSET:   (*LRUCache).replayHash,
+	wal.EntryTypeHDEL:   (*LRUCache).replayHash,
+	wal.EntryTypeLPUSH:  (*LRUCache).replayList,
+	wal.EntryTypeRPUSH:  (*LRUCache).replayList,
+	wal.EntryTypeLPOP:   (*LRUCache).replayList,
+	wal.EntryTypeRPOP:   (*LRUCache).replayList,
+	wal.EntryTypeSADD:   (*LRUCache).replaySet,
+	wal.EntryTypeSREM:   (*LRUCache).replaySet,
+	wal.EntryTypeZADD:   (*LRUCache).replaySortedSet,
+	wal.EntryTypePFADD:  (*LRUCache).replayHyperLogLog,
+	wal.EntryTypeBFADD:  (*LRUCache).replayBloomFilter,
+	wal.EntryTypeXADD:   (*LRUCache).replayStream,
+	wal.EntryTypeXGROUP: (*LRUCache).replayStream,
+	wal.EntryTypeXCLAIM: (*LRUCache).replayStream,
+	wal.EntryTypeXACK:   (*LRUCache).replayStream,
+}
+
+// lookupAs returns the live entry for key and its value as a T. The entry is
+// nil if the key does not exist. The caller must hold cache.mu.
+func lookupAs[T any](cache *LRUCache, key string) (*CacheItem, T, error) {
+	var zero T
+	entry, ok := cache.lookup(key)
+	if !ok {
+		return nil, zero, nil
+	}
+	value, ok := entry.value.(T)
+	if !ok {
+		return nil, zero, ErrWrongType
+	}
+	return entry, value, nil
+}
+
+// cloneValue returns a copy of the mutable data types so callers and
+// watchers never share them with the cache
+func cloneValue(value any) any {
+	switch v := value.(type) {
+	case Hash:
+		return maps.Clone(v)
+	case List:
+		return slices.Clone(v)
+	case Set:
+		return maps.Clone(v)
+	case *SortedSet:
+		return v.clone()
+	case HyperLogLog:
+		return slices.Clone(v)
+	case *BloomFilter:
+		return v.clone()
+	case *Stream:
+		return v.clone()
+	}
+	return value
+}
+
+// updateExpiry returns the expiry to log and apply for an update to a data
+// type value. Updates keep the expiry of an existing entry; a value created
+// by the update (entry is nil) gets the default TTL, or ErrFull if the
+// eviction policy leaves no room for a new key.
+func (cache *LRUCache) updateExpiry(entry *CacheItem) (expiry, error) {
+	if entry != nil {
+		return entry.expiry, nil
+	}
+	if cache.full() {
+		return expiry{}, ErrFull
+	}
+	return cache.expiryAfter(cache.defaultTTL), nil
+}
+
+// appendOp writes a data type update to the WAL with its payload gob-encoded.
+// The caller must hold cache.mu.
+func (cache *LRUCache) appendOp(entryType wal.EntryType, key string, payload any, expiresAtUnixNano int64) error {
+	if cache.wal == nil {
+		return nil
+	}
+	if err := cache.syncDeadline(key); err != nil {
+		return err
+	}
+
+	data, err := encodeOp(payload)
+	if err != nil {
+		return err
+	}
+	if err := cache.wal.Append(entryType, key, data, expiresAtUnixNano); err != nil {
+		return fmt.Errorf("failed to write to WAL: %w", err)
+	}
+	return nil
+}
+
+// encodeOp gob-encodes the payload of a WAL record
+func encodeOp(payload any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(payload); err != nil {
+		return nil, fmt.Errorf("failed to encode WAL payload: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeOp decodes the payload of a data type update read from the WAL
+func decodeOp(data []byte, payload any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(payload)
+}
+
+// replace logs value as a whole SET record and stores it under key, keeping
+// the expiration of entry, the live value it replaces, or giving a new value
+// the default TTL when entry is nil. The caller must hold cache.mu.
+func (cache *LRUCache) replace(key string, entry *CacheItem, value any) error {
+	exp, err := cache.updateExpiry(entry)
+	if err != nil {
+		return err
+	}
+
+	valueBytes, err := serializeValue(value)
+	if err != nil {
+		return fmt.Errorf("failed to serialize value: %w", err)
+	}
+	if cache.wal != nil {
+		if err := cache.wal.Append(wal.EntryTypeSET, key, valueBytes, exp.expiresAtUnixNano()); err != nil {
+			return fmt.Errorf("failed to write to WAL: %w", err)
+		}
+	}
+	if err := cache.logSliding(key, exp); err != nil {
+		return err
+	}
+
+	cache.store(key, value, exp, len(key)+len(valueBytes))
+	cache.notify(EventSet, key, nil)
+	return nil
+}
@@ -0,0 +1,223 @@
+package cache
+
+import (
+	"errors"
+	"strings"
+)
+
+const (
+	// watchBufferSize is the number of undelivered events a watcher may queue
+	watchBufferSize = 256
+	// watchHistorySize is the number of recent events kept for resuming watches
+	watchHistorySize = 1024
+)
+
+var (
+	// ErrHistoryCompacted is returned when resuming from a sequence number
+	// older than the retained event history, or newer than the last event,
+	// as one issued before the cache was reopened may be
+	ErrHistoryCompacted = errors.New("watch history does not cover the requested sequence")
+
+	// ErrWatcherOverflow is reported by a watcher closed because it did not
+	// keep up with events
+	ErrWatcherOverflow = errors.New("watcher fell behind and was closed")
+)
+
+// EventType is the kind of change reported to watchers
+type EventType uint8
+
+const (
+	EventSet EventType = iota + 1
+	EventDelete
+	// EventExpire is sent when an expired key is next accessed, not when its
+	// TTL passes, since expired keys are only removed on access
+	EventExpire
+	EventEvict
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventSet:
+		return "set"
+	case EventDelete:
+		return "delete"
+	case EventExpire:
+		return "expire"
+	case EventEvict:
+		return "evict"
+	}
+	return "unknown"
+}
+
+// Event describes a change to a key. Value is the value stored by Set or
+// CompareAndSwap; it is nil for other events, including EventSet events
+// from data type updates such as HSet or RPush, which would otherwise copy
+// the whole structure on every change.
+type Event struct {
+	Type     EventType
+	Key      string
+	Value    any
+	Sequence uint64
+}
+
+// Watcher receives events for the keys it matches on C until it is closed
+type Watcher struct {
+	// C is closed when the watcher is closed or falls behind
+	C <-chan Event
+
+	events chan Event
+	match  func(key string) bool
+	cache  *LRUCache
+	err    error
+}
+
+// Close stops the watcher and closes C
+func (w *Watcher) Close() {
+	w.cache.mu.Lock()
+	defer w.cache.unlock()
+
+	w.cache.removeWatcher(w, nil)
+}
+
+// Err reports why C was closed: ErrWatcherOverflow if the watcher fell
+// behind, nil otherwise. It is only meaningful once C is closed.
+func (w *Watcher) Err() error {
+	w.cache.mu.RLock()
+	defer w.cache.mu.RUnlock()
+
+	return w.err
+}
+
+// WatchOption configures a watch
+type WatchOption func(*watchOptions)
+
+type watchOptions struct {
+	since    uint64
+	hasSince bool
+}
+
+// FromSequence replays the retained events with a sequence number greater
+// than since before delivering new ones
+func FromSequence(since uint64) WatchOption {
+	return func(o *watchOptions) {
+		o.since = since
+		o.hasSince = true
+	}
+}
+
+// WatchEpoch identifies the sequence numbers of this cache's events, which
+// start again from 1 whenever the cache is reopened. It differs between
+// caches opened at different times.
+func (cache *LRUCache) WatchEpoch() string {
+	return cache.epoch
+}
+
+// Watch returns a watcher for changes to key. A key that expires is only
+// reported once something accesses it; see EventExpire.
+func (cache *LRUCache) Watch(key string, opts ...WatchOption) (*Watcher, error) {
+	return cache.watch(func(k string) bool { return k == key }, opts)
+}
+
+// WatchPrefix returns a watcher for changes to every key starting with
+// prefix. Expirations are reported lazily, as for Watch.
+func (cache *LRUCache) WatchPrefix(prefix string, opts ...WatchOption) (*Watcher, error) {
+	return cache.watch(func(k string) bool { return strings.HasPrefix(k, prefix) }, opts)
+}
+
+func (cache *LRUCache) watch(match func(string) bool, opts []WatchOption) (*Watcher, error) {
+	var options watchOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	events := make(chan Event, watchBufferSize)
+	w := &Watcher{C: events, events: events, match: match, cache: cache}
+
+	if options.hasSince {
+		replay, err := cache.eventsSince(options.since)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range replay {
+			if !match(event.Key) {
+				continue
+			}
+			if len(events) == cap(events) {
+				return nil, ErrHistoryCompacted
+			}
+			events <- event
+		}
+	}
+
+	if cache.watchers == nil {
+		cache.watchers = make(map[*Watcher]struct{})
+	}
+	cache.watchers[w] = struct{}{}
+	return w, nil
+}
+
+// eventsSince returns the retained events after since, oldest first.
+// The caller must hold cache.mu.
+func (cache *LRUCache) eventsSince(since uint64) ([]Event, error) {
+	if since > cache.revision {
+		return nil, ErrHistoryCompacted
+	}
+	if since == cache.revision {
+		return nil, nil
+	}
+
+	n := len(cache.history)
+	oldest := cache.revision - uint64(n) + 1
+	if since+1 < oldest {
+		return nil, ErrHistoryCompacted
+	}
+
+	events := make([]Event, 0, cache.revision-since)
+	for seq := since + 1; seq <= cache.revision; seq++ {
+		events = append(events, cache.history[(seq-1)%watchHistorySize])
+	}
+	return events, nil
+}
+
+// notify records an event and delivers it to the matching watchers without
+// blocking. Watchers whose buffer is full are closed with ErrWatcherOverflow.
+// The caller must hold cache.mu.
+func (cache *LRUCache) notify(eventType EventType, key string, value any) {
+	if eventType == EventSet || eventType == EventDelete {
+		cache.invalidateLoad(key)
+	}
+
+	cache.revision++
+	event := Event{Type: eventType, Key: key, Value: value, Sequence: cache.revision}
+
+	if len(cache.history) < watchHistorySize {
+		cache.history = append(cache.history, event)
+	} else {
+		cache.history[(cache.revision-1)%watchHistorySize] = event
+	}
+
+	for w := range cache.watchers {
+		if !w.match(key) {
+			continue
+		}
+		select {
+		case w.events <- event:
+		default:
+			cache.removeWatcher(w, ErrWatcherOverflow)
+		}
+	}
+}
+
+// removeWatcher unregisters w and closes its channel.
+// The caller must hold cache.mu.
+func (cache *LRUCache) removeWatcher(w *Watcher, err error) {
+	if _, ok := cache.watchers[w]; !ok {
+		return
+	}
+	delete(cache.watchers, w)
+	w.err = err
+	close(w.events)
+}
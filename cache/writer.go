@@ -0,0 +1,182 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// writeLockStripes is the number of locks serializing backing store writes;
+// writes to keys sharing a stripe are ordered as they are in the cache
+const writeLockStripes = 64
+
+// Writer propagates writes made through Set, CompareAndSwap and Delete to a
+// backing store
+type Writer interface {
+	Write(ctx context.Context, key string, value any) error
+	Delete(ctx context.Context, key string) error
+}
+
+// WriterOption configures how SetWriter's writer is used
+type WriterOption func(*writerOptions)
+
+type writerOptions struct {
+	behind    bool
+	queueSize int
+	onError   func(key string, err error)
+}
+
+// WithWriteBehind queues writes to the backing store and applies them in
+// order on a background goroutine instead of before the cache is updated.
+// Writes block while queueSize writes are pending. onError, if not nil, is
+// called with every failed write; otherwise failures are logged.
+func WithWriteBehind(queueSize int, onError func(key string, err error)) WriterOption {
+	return func(o *writerOptions) {
+		o.behind = true
+		o.queueSize = queueSize
+		o.onError = onError
+	}
+}
+
+// writeOp is one write to the backing store
+type writeOp struct {
+	key    string
+	value  any
+	delete bool
+}
+
+func (op writeOp) apply(ctx context.Context, writer Writer) error {
+	if op.delete {
+		return writer.Delete(ctx, op.key)
+	}
+	return writer.Write(ctx, op.key, op.value)
+}
+
+// writeBehind applies queued writes to a backing store
+type writeBehind struct {
+	writer  Writer
+	onError func(key string, err error)
+	ops     chan writeOp
+	done    chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+func newWriteBehind(writer Writer, o writerOptions) *writeBehind {
+	wb := &writeBehind{
+		writer:  writer,
+		onError: o.onError,
+		ops:     make(chan writeOp, max(o.queueSize, 1)),
+		done:    make(chan struct{}),
+	}
+	go wb.run()
+	return wb
+}
+
+func (wb *writeBehind) run() {
+	defer close(wb.done)
+	for op := range wb.ops {
+		err := op.apply(context.Background(), wb.writer)
+		switch {
+		case err == nil:
+		case wb.onError != nil:
+			wb.onError(op.key, err)
+		default:
+			fmt.Printf("Warning: failed to write key %s to backing store: %v\n", op.key, err)
+		}
+	}
+}
+
+// enqueue queues op, waiting while the queue is full
+func (wb *writeBehind) enqueue(op writeOp) error {
+	wb.mu.RLock()
+	defer wb.mu.RUnlock()
+
+	if wb.closed {
+		return ErrClosed
+	}
+	wb.ops <- op
+	return nil
+}
+
+// close applies the queued writes and stops the background goroutine
+func (wb *writeBehind) close() {
+	wb.mu.Lock()
+	if !wb.closed {
+		wb.closed = true
+		close(wb.ops)
+	}
+	wb.mu.Unlock()
+	<-wb.done
+}
+
+// SetWriter makes Set, CompareAndSwap and Delete write to a backing store
+// with writer. Writes go to the store before the cache and fail without
+// changing it if the store fails, unless WithWriteBehind is given. A nil
+// writer turns writing off; writes queued for a previous writer are applied
+// first.
+func (cache *LRUCache) SetWriter(writer Writer, opts ...WriterOption) {
+	var o writerOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var behind *writeBehind
+	if writer != nil && o.behind {
+		behind = newWriteBehind(writer, o)
+	}
+
+	cache.mu.Lock()
+	previous := cache.writeBehind
+	cache.writer = writer
+	cache.writeBehind = behind
+	cache.unlock()
+
+	if previous != nil {
+		previous.close()
+	}
+}
+
+// withWriter updates the cache with apply and the backing store with op,
+// if there is a writer
+func (cache *LRUCache) withWriter(op writeOp, apply func() error) error {
+	cache.mu.RLock()
+	writer, behind := cache.writer, cache.writeBehind
+	cache.mu.RUnlock()
+	if writer == nil {
+		return apply()
+	}
+
+	unlock := cache.lockKey(op.key)
+	defer unlock()
+	return writeBack(writer, behind, op, apply)
+}
+
+// writeBack writes op to the backing store, before apply updates the cache
+// for write-through or queued after it for write-behind. The caller must
+// hold the key's write lock.
+func writeBack(writer Writer, behind *writeBehind, op writeOp, apply func() error) error {
+	if behind == nil {
+		if err := op.apply(context.Background(), writer); err != nil {
+			return fmt.Errorf("failed to write to backing store: %w", err)
+		}
+		return apply()
+	}
+
+	if err := apply(); err != nil {
+		return err
+	}
+	if err := behind.enqueue(op); err != nil {
+		return fmt.Errorf("failed to queue write to backing store: %w", err)
+	}
+	return nil
+}
+
+// lockKey locks the write lock stripe of key and returns its unlock function.
+// It must be taken before cache.mu.
+func (cache *LRUCache) lockKey(key string) func() {
+	stripe := &cache.writeLocks[hashString(key)%writeLockStripes]
+	stripe.Lock()
+	return stripe.Unlock
+}
@@ -0,0 +1,287 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/nishanth-gowda/kv-store/wal"
+)
+
+// ErrInvalidScore is returned when a sorted set score is NaN, or an
+// increment would produce NaN
+var ErrInvalidScore = errors.New("score is not a valid number")
+
+// ZMember is a sorted set member with its score
+type ZMember struct {
+	Member string
+	Score  float64
+}
+
+// SortedSet is the value stored under a sorted set key. Members are ordered
+// by score, then by member, in a skip list with a map for score lookups.
+// Get returns a copy, so modifying it does not change the cache.
+type SortedSet struct {
+	scores map[string]float64
+	list   *skipList
+}
+
+func init() {
+	// Snapshots store whole sorted sets as SET values
+	gob.Register(&SortedSet{})
+}
+
+func newSortedSet() *SortedSet {
+	return &SortedSet{scores: make(map[string]float64), list: newSkipList()}
+}
+
+// Len returns the number of members
+func (z *SortedSet) Len() int {
+	return len(z.scores)
+}
+
+// Score returns the score of member
+func (z *SortedSet) Score(member string) (float64, bool) {
+	score, ok := z.scores[member]
+	return score, ok
+}
+
+// Members returns every member in ascending order
+func (z *SortedSet) Members() []ZMember {
+	return z.rangeByRank(0, z.Len()-1)
+}
+
+// GobEncode encodes the members in order; the skip list is rebuilt on decode
+func (z *SortedSet) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(z.Members()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode restores a sorted set encoded by GobEncode
+func (z *SortedSet) GobDecode(data []byte) error {
+	var members []ZMember
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&members); err != nil {
+		return err
+	}
+	*z = *newSortedSet()
+	for _, m := range members {
+		z.add(m.Member, m.Score)
+	}
+	return nil
+}
+
+// add sets the score of member and reports whether it is a new member
+func (z *SortedSet) add(member string, score float64) bool {
+	old, exists := z.scores[member]
+	if exists {
+		if old == score {
+			return false
+		}
+		z.list.delete(member, old)
+	}
+	z.scores[member] = score
+	z.list.insert(member, score)
+	return !exists
+}
+
+func (z *SortedSet) clone() *SortedSet {
+	clone := newSortedSet()
+	for node := z.list.head.levels[0].forward; node != nil; node = node.levels[0].forward {
+		clone.add(node.member, node.score)
+	}
+	return clone
+}
+
+// rangeByRank returns the members between 0-based ranks start and stop inclusive
+func (z *SortedSet) rangeByRank(start, stop int) []ZMember {
+	members := []ZMember{}
+	node := z.list.byRank(start)
+	for rank := start; node != nil && rank <= stop; rank++ {
+		members = append(members, ZMember{Member: node.member, Score: node.score})
+		node = node.levels[0].forward
+	}
+	return members
+}
+
+// ZAdd sets the scores of members in the sorted set stored under key,
+// creating it if needed, and returns how many members were added rather
+// than updated
+func (cache *LRUCache) ZAdd(key string, members map[string]float64) (int, error) {
+	for _, score := range members {
+		if math.IsNaN(score) {
+			return 0, ErrInvalidScore
+		}
+	}
+
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	entry, _, err := lookupAs[*SortedSet](cache, key)
+	if err != nil {
+		return 0, err
+	}
+	if len(members) == 0 {
+		return 0, nil
+	}
+
+	updates := make([]ZMember, 0, len(members))
+	for member, score := range members {
+		updates = append(updates, ZMember{Member: member, Score: score})
+	}
+	return cache.zadd(key, entry, updates)
+}
+
+// ZIncrBy adds delta to the score of member in the sorted set stored under
+// key and returns the new score. A missing member starts at zero.
+func (cache *LRUCache) ZIncrBy(key, member string, delta float64) (float64, error) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	entry, zset, err := lookupAs[*SortedSet](cache, key)
+	if err != nil {
+		return 0, err
+	}
+
+	var score float64
+	if zset != nil {
+		score = zset.scores[member]
+	}
+	score += delta
+	if math.IsNaN(score) {
+		return 0, ErrInvalidScore
+	}
+
+	// The result is logged as a plain score update so replay is idempotent
+	if _, err := cache.zadd(key, entry, []ZMember{{Member: member, Score: score}}); err != nil {
+		return 0, err
+	}
+	return score, nil
+}
+
+// ZRange returns the members of the sorted set stored under key between
+// ranks start and stop inclusive, lowest score first. Negative ranks count
+// from the end, so 0 and -1 return every member.
+func (cache *LRUCache) ZRange(key string, start, stop int) ([]ZMember, error) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	entry, zset, err := lookupAs[*SortedSet](cache, key)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return []ZMember{}, nil
+	}
+	cache.evictList.MoveToFront(entry.element)
+
+	n := zset.Len()
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	start = max(start, 0)
+	stop = min(stop, n-1)
+	if start > stop {
+		return []ZMember{}, nil
+	}
+	return zset.rangeByRank(start, stop), nil
+}
+
+// ZRangeByScore returns the members of the sorted set stored under key with
+// scores between minScore and maxScore inclusive, lowest score first
+func (cache *LRUCache) ZRangeByScore(key string, minScore, maxScore float64) ([]ZMember, error) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	entry, zset, err := lookupAs[*SortedSet](cache, key)
+	if err != nil {
+		return nil, err
+	}
+	members := []ZMember{}
+	if entry == nil {
+		return members, nil
+	}
+	cache.evictList.MoveToFront(entry.element)
+
+	for node := zset.list.firstFrom(minScore); node != nil && node.score <= maxScore; node = node.levels[0].forward {
+		members = append(members, ZMember{Member: node.member, Score: node.score})
+	}
+	return members, nil
+}
+
+// ZRank returns the 0-based rank of member in the sorted set stored under
+// key, lowest score first. The boolean is false if it is not a member.
+func (cache *LRUCache) ZRank(key, member string) (int, bool, error) {
+	cache.mu.Lock()
+	defer cache.unlock()
+
+	entry, zset, err := lookupAs[*SortedSet](cache, key)
+	if err != nil || entry == nil {
+		return 0, false, err
+	}
+	cache.evictList.MoveToFront(entry.element)
+
+	score, ok := zset.scores[member]
+	if !ok {
+		return 0, false, nil
+	}
+	return zset.list.rank(member, score), true, nil
+}
+
+// zadd logs and applies score updates to the sorted set under key. entry is
+// the live sorted set, or nil to create one. The caller must hold cache.mu.
+func (cache *LRUCache) zadd(key string, entry *CacheItem, members []ZMember) (int, error) {
+	exp, err := cache.updateExpiry(entry)
+	if err != nil {
+		return 0, err
+	}
+	if err := cache.appendOp(wal.EntryTypeZADD, key, members, exp.expiresAtUnixNano()); err != nil {
+		return 0, err
+	}
+
+	added := cache.applyZAdd(key, members, exp)
+	cache.notify(EventSet, key, nil)
+	return added, nil
+}
+
+// applyZAdd sets scores in the sorted set under key, creating it with exp if
+// it does not exist. The caller must hold cache.mu.
+func (cache *LRUCache) applyZAdd(key string, members []ZMember, exp expiry) int {
+	entry, ok := cache.entries[key]
+	var zset *SortedSet
+	if ok {
+		zset, ok = entry.value.(*SortedSet)
+	}
+	if !ok {
+		zset = newSortedSet()
+		entry = cache.store(key, zset, exp, len(key))
+	}
+
+	added, size := 0, entry.size
+	for _, m := range members {
+		if zset.add(m.Member, m.Score) {
+			added++
+			size += len(m.Member) + 8
+		}
+	}
+	cache.resize(entry, size)
+	cache.evictList.MoveToFront(entry.element)
+	return added
+}
+
+// replaySortedSet applies a sorted set update read from the WAL during recovery
+func (cache *LRUCache) replaySortedSet(entry *wal.WAL_Entry, exp expiry) error {
+	var members []ZMember
+	if err := decodeOp(entry.Value, &members); err != nil {
+		return fmt.Errorf("failed to decode sorted set members: %w", err)
+	}
+	cache.applyZAdd(entry.Key, members, exp)
+	return nil
+}
@@ -0,0 +1,376 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeout       = 5 * time.Second
+	defaultMaxRetries    = 3
+	defaultRetryBaseWait = 50 * time.Millisecond
+	defaultRetryMaxWait  = 1 * time.Second
+	defaultMaxIdleConns  = 64
+)
+
+var (
+	// ErrNotFound is returned when the requested key does not exist or has expired
+	ErrNotFound = errors.New("kv-store: key not found")
+
+	// ErrConflict is returned by CompareAndSwap when the current value does not match
+	ErrConflict = errors.New("kv-store: value mismatch")
+)
+
+// StatusError is returned for unexpected HTTP responses from the server
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("kv-store: server returned %d: %s", e.StatusCode, e.Message)
+}
+
+// Client is a typed client for the kv-store HTTP API.
+// A Client is safe for concurrent use and reuses connections between calls.
+type Client struct {
+	baseURL       *url.URL
+	httpClient    *http.Client
+	maxRetries    int
+	retryBaseWait time.Duration
+	retryMaxWait  time.Duration
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient replaces the underlying http.Client; nil means http.DefaultClient
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		if httpClient == nil {
+			httpClient = http.DefaultClient
+		}
+		c.httpClient = httpClient
+	}
+}
+
+// WithTimeout sets the per-request timeout. It applies to a copy of the
+// http.Client, so one passed to WithHTTPClient, which may be shared, is left alone.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		httpClient := *c.httpClient
+		httpClient.Timeout = timeout
+		c.httpClient = &httpClient
+	}
+}
+
+// WithRetries sets how many times a request failing with a 5xx status other
+// than 507 or a transport error is retried, and the base and maximum backoff between attempts
+func WithRetries(maxRetries int, baseWait, maxWait time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.retryBaseWait = baseWait
+		c.retryMaxWait = maxWait
+	}
+}
+
+// New creates a client for the server listening at baseURL (e.g. http://localhost:8080)
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid base URL %q: scheme must be http or https", baseURL)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = defaultMaxIdleConns
+	transport.MaxIdleConnsPerHost = defaultMaxIdleConns
+
+	c := &Client{
+		baseURL: u,
+		httpClient: &http.Client{
+			Transport: transport,
+			Timeout:   defaultTimeout,
+		},
+		maxRetries:    defaultMaxRetries,
+		retryBaseWait: defaultRetryBaseWait,
+		retryMaxWait:  defaultRetryMaxWait,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c, nil
+}
+
+// Get returns the value stored under key, or ErrNotFound
+func (c *Client) Get(ctx context.Context, key string) (string, error) {
+	body, err := c.do(ctx, http.MethodGet, "/get", url.Values{"key": {key}}, nil, true)
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+// Set stores value under key. A ttl of zero means the key never expires.
+func (c *Client) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	query := url.Values{"key": {key}, "value": {value}}
+	setTTL(query, ttl)
+	_, err := c.do(ctx, http.MethodPost, "/set", query, nil, true)
+	return err
+}
+
+// Delete removes key. Deleting a missing key is not an error.
+func (c *Client) Delete(ctx context.Context, key string) error {
+	_, err := c.do(ctx, http.MethodDelete, "/delete", url.Values{"key": {key}}, nil, true)
+	return err
+}
+
+// CompareAndSwap replaces the value of key with newValue only if its current
+// value is oldValue. It returns ErrConflict when the values do not match.
+// CompareAndSwap is never retried, since a lost response could have been applied.
+func (c *Client) CompareAndSwap(ctx context.Context, key, oldValue, newValue string, ttl time.Duration) error {
+	query := url.Values{"key": {key}, "old": {oldValue}, "value": {newValue}}
+	setTTL(query, ttl)
+	_, err := c.do(ctx, http.MethodPost, "/cas", query, nil, false)
+	return err
+}
+
+// MGet returns the values of all keys that exist; missing keys are absent from the map
+func (c *Client) MGet(ctx context.Context, keys ...string) (map[string]string, error) {
+	if len(keys) == 0 {
+		return map[string]string{}, nil
+	}
+
+	body, err := c.do(ctx, http.MethodGet, "/mget", url.Values{"key": keys}, nil, true)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(keys))
+	if err := json.Unmarshal(body, &values); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return values, nil
+}
+
+// MSet stores all key/value pairs with the same ttl in a single request
+func (c *Client) MSet(ctx context.Context, values map[string]string, ttl time.Duration) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(values)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	query := url.Values{}
+	setTTL(query, ttl)
+	_, err = c.do(ctx, http.MethodPost, "/mset", query, payload, true)
+	return err
+}
+
+// NoExpiry is returned by TTL for keys without an expiration
+const NoExpiry time.Duration = -1
+
+// TTL returns the remaining time to live of key, NoExpiry if the key never
+// expires, or ErrNotFound
+func (c *Client) TTL(ctx context.Context, key string) (time.Duration, error) {
+	body, err := c.do(ctx, http.MethodGet, "/ttl", url.Values{"key": {key}}, nil, true)
+	if err != nil {
+		return 0, err
+	}
+
+	var resp struct {
+		TTLMillis int64 `json:"ttl_ms"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return 0, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if resp.TTLMillis < 0 {
+		return NoExpiry, nil
+	}
+	return time.Duration(resp.TTLMillis) * time.Millisecond, nil
+}
+
+// Keys returns the sorted keys that start with prefix; an empty prefix matches every key
+func (c *Client) Keys(ctx context.Context, prefix string) ([]string, error) {
+	query := url.Values{}
+	if prefix != "" {
+		query.Set("prefix", prefix)
+	}
+
+	body, err := c.do(ctx, http.MethodGet, "/keys", query, nil, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	if err := json.Unmarshal(body, &keys); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return keys, nil
+}
+
+// Stats returns the server statistics as decoded JSON fields
+func (c *Client) Stats(ctx context.Context) (map[string]any, error) {
+	body, err := c.do(ctx, http.MethodGet, "/stats", nil, nil, true)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := map[string]any{}
+	if err := json.Unmarshal(body, &stats); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return stats, nil
+}
+
+// ResetStats sets the server's hit, miss, eviction and expiration counters back to zero
+func (c *Client) ResetStats(ctx context.Context) error {
+	_, err := c.do(ctx, http.MethodPost, "/stats/reset", nil, nil, true)
+	return err
+}
+
+// Publish sends message to channel and returns the number of subscriptions it
+// reached. It is never retried so subscribers do not see duplicates.
+func (c *Client) Publish(ctx context.Context, channel, message string) (int, error) {
+	body, err := c.do(ctx, http.MethodPost, "/publish", url.Values{"channel": {channel}, "message": {message}}, nil, false)
+	if err != nil {
+		return 0, err
+	}
+
+	var resp struct {
+		Receivers int `json:"receivers"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return 0, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return resp.Receivers, nil
+}
+
+// setTTL adds the ttl query parameter when ttl is positive
+func setTTL(query url.Values, ttl time.Duration) {
+	if ttl > 0 {
+		query.Set("ttl", ttl.String())
+	}
+}
+
+// do sends a request and returns the response body of a 2xx response.
+// Transport errors and 5xx responses other than 507 are retried with exponential backoff when retry is set.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, payload []byte, retry bool) ([]byte, error) {
+	u := *c.baseURL
+	u.Path += path
+	u.RawQuery = query.Encode()
+
+	attempts := 1
+	if retry {
+		attempts += c.maxRetries
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			if err := c.sleep(ctx, attempt); err != nil {
+				return nil, err
+			}
+		}
+
+		body, retryable, err := c.roundTrip(ctx, method, u.String(), payload)
+		if err == nil {
+			return body, nil
+		}
+		if !retryable {
+			return nil, err
+		}
+		lastErr = err
+	}
+
+	return nil, lastErr
+}
+
+// roundTrip performs a single attempt and reports whether a failure may be retried
+func (c *Client) roundTrip(ctx context.Context, method, rawURL string, payload []byte) ([]byte, bool, error) {
+	var reqBody io.Reader
+	if payload != nil {
+		reqBody = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, reqBody)
+	if err != nil {
+		return nil, false, err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, false, ctx.Err()
+		}
+		var netErr net.Error
+		return nil, errors.As(err, &netErr) || errors.Is(err, io.EOF), err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return body, false, nil
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, false, ErrNotFound
+	case resp.StatusCode == http.StatusConflict:
+		return nil, false, ErrConflict
+	default:
+		statusErr := &StatusError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+		return nil, retryableStatus(resp.StatusCode), statusErr
+	}
+}
+
+// retryableStatus reports whether a request failing with status may succeed
+// when retried. 507 means the cache is full, which a retry does not change.
+func retryableStatus(status int) bool {
+	return status >= 500 && status != http.StatusInsufficientStorage
+}
+
+// sleep waits for the backoff of the given attempt or until ctx is done
+func (c *Client) sleep(ctx context.Context, attempt int) error {
+	wait := c.retryBaseWait << (attempt - 1)
+	if wait <= 0 || wait > c.retryMaxWait {
+		wait = c.retryMaxWait
+	}
+	// Full jitter keeps concurrent clients from retrying in lockstep
+	if wait > 0 {
+		wait = time.Duration(rand.Int63n(int64(wait)) + 1)
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nishanth-gowda/kv-store/client"
+)
+
+// command is a kvctl subcommand
+type command struct {
+	name    string
+	args    string
+	help    string
+	minArgs int
+	maxArgs int // -1 means unlimited
+	run     func(ctx context.Context, c *client.Client, args []string) (result, error)
+}
+
+// result is the outcome of a command on one server, rendered as a table
+type result struct {
+	header []string
+	rows   [][]string
+	value  any // JSON representation
+}
+
+var commands = []command{
+	{
+		name: "get", args: "KEY", help: "print the value of a key",
+		minArgs: 1, maxArgs: 1,
+		run: func(ctx context.Context, c *client.Client, args []string) (result, error) {
+			value, err := c.Get(ctx, args[0])
+			if err != nil {
+				return result{}, err
+			}
+			return result{header: []string{"VALUE"}, rows: [][]string{{value}}, value: value}, nil
+		},
+	},
+	{
+		name: "set", args: "KEY VALUE [TTL]", help: "set a key, optionally expiring after TTL (e.g. 5m)",
+		minArgs: 2, maxArgs: 3,
+		run: func(ctx context.Context, c *client.Client, args []string) (result, error) {
+			var ttl time.Duration
+			if len(args) == 3 {
+				var err error
+				if ttl, err = time.ParseDuration(args[2]); err != nil {
+					return result{}, fmt.Errorf("invalid TTL %q: %w", args[2], err)
+				}
+			}
+			if err := c.Set(ctx, args[0], args[1], ttl); err != nil {
+				return result{}, err
+			}
+			return okResult(), nil
+		},
+	},
+	{
+		name: "del", args: "KEY [KEY...]", help: "delete one or more keys",
+		minArgs: 1, maxArgs: -1,
+		run: func(ctx context.Context, c *client.Client, args []string) (result, error) {
+			for _, key := range args {
+				if err := c.Delete(ctx, key); err != nil {
+					return result{}, err
+				}
+			}
+			return okResult(), nil
+		},
+	},
+	{
+		name: "ttl", args: "KEY", help: "print the remaining time to live of a key",
+		minArgs: 1, maxArgs: 1,
+		run: func(ctx context.Context, c *client.Client, args []string) (result, error) {
+			ttl, err := c.TTL(ctx, args[0])
+			if err != nil {
+				return result{}, err
+			}
+			if ttl == client.NoExpiry {
+				return result{header: []string{"TTL"}, rows: [][]string{{"none"}}, value: nil}, nil
+			}
+			ttl = ttl.Round(time.Millisecond)
+			return result{header: []string{"TTL"}, rows: [][]string{{ttl.String()}}, value: ttl.Milliseconds()}, nil
+		},
+	},
+	{
+		name: "keys", args: "[PREFIX]", help: "list keys, optionally only those starting with PREFIX",
+		minArgs: 0, maxArgs: 1,
+		run: func(ctx context.Context, c *client.Client, args []string) (result, error) {
+			var prefix string
+			if len(args) == 1 {
+				prefix = args[0]
+			}
+			keys, err := c.Keys(ctx, prefix)
+			if err != nil {
+				return result{}, err
+			}
+			rows := make([][]string, len(keys))
+			for i, key := range keys {
+				rows[i] = []string{key}
+			}
+			return result{header: []string{"KEY"}, rows: rows, value: keys}, nil
+		},
+	},
+	{
+		name: "stats", args: "[reset]", help: "print server statistics, or reset the counters",
+		minArgs: 0, maxArgs: 1,
+		run: func(ctx context.Context, c *client.Client, args []string) (result, error) {
+			if len(args) == 1 {
+				if args[0] != "reset" {
+					return result{}, fmt.Errorf("unknown stats subcommand %q", args[0])
+				}
+				if err := c.ResetStats(ctx); err != nil {
+					return result{}, err
+				}
+				return okResult(), nil
+			}
+
+			stats, err := c.Stats(ctx)
+			if err != nil {
+				return result{}, err
+			}
+			return result{header: []string{"FIELD", "VALUE"}, rows: flatten("", stats), value: stats}, nil
+		},
+	},
+}
+
+// okResult is returned by commands that only acknowledge success
+func okResult() result {
+	return result{header: []string{"RESULT"}, rows: [][]string{{"OK"}}, value: "OK"}
+}
+
+// flatten turns nested JSON objects into sorted dotted field/value rows
+func flatten(prefix string, fields map[string]any) [][]string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var rows [][]string
+	for _, name := range names {
+		if nested, ok := fields[name].(map[string]any); ok {
+			rows = append(rows, flatten(prefix+name+".", nested)...)
+			continue
+		}
+		rows = append(rows, []string{prefix + name, fmt.Sprint(fields[name])})
+	}
+	return rows
+}
+
+// findCommand returns the command with the given name
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// run executes args as a command against every target concurrently and prints the results
+func run(ctx context.Context, targets []target, p *printer, args []string) error {
+	cmd, ok := findCommand(args[0])
+	if !ok {
+		return fmt.Errorf("unknown command %q (try \"help\")", args[0])
+	}
+
+	args = args[1:]
+	if len(args) < cmd.minArgs || (cmd.maxArgs >= 0 && len(args) > cmd.maxArgs) {
+		return fmt.Errorf("usage: %s %s", cmd.name, cmd.args)
+	}
+
+	results := make([]result, len(targets))
+	errs := make([]error, len(targets))
+
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = cmd.run(ctx, t.client, args)
+		}()
+	}
+	wg.Wait()
+
+	if err := p.print(targets, results, errs); err != nil {
+		return err
+	}
+
+	var failed int
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		if len(targets) == 1 {
+			return errs[0]
+		}
+		return fmt.Errorf("%s failed on %d of %d servers", cmd.name, failed, len(targets))
+	}
+	return nil
+}
+
+// printer renders command results as a table or JSON
+type printer struct {
+	w      io.Writer
+	format string
+}
+
+// jsonResult is the JSON output of a command on one server
+type jsonResult struct {
+	Server string `json:"server"`
+	Result any    `json:"result,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+func (p *printer) print(targets []target, results []result, errs []error) error {
+	if p.format == "json" {
+		out := make([]jsonResult, len(targets))
+		for i, t := range targets {
+			out[i] = jsonResult{Server: t.addr, Result: results[i].value}
+			if errs[i] != nil {
+				out[i] = jsonResult{Server: t.addr, Error: errorMessage(errs[i])}
+			}
+		}
+		encoder := json.NewEncoder(p.w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(out)
+	}
+
+	// A single server prints its result without the SERVER column; errors go to the caller
+	if len(targets) == 1 {
+		if errs[0] != nil {
+			return nil
+		}
+		return p.table(results[0].header, results[0].rows)
+	}
+
+	var header []string
+	var rows [][]string
+	for i, t := range targets {
+		if errs[i] != nil {
+			rows = append(rows, []string{t.addr, "error: " + errorMessage(errs[i])})
+			continue
+		}
+		if header == nil {
+			header = append([]string{"SERVER"}, results[i].header...)
+		}
+		for _, row := range results[i].rows {
+			rows = append(rows, append([]string{t.addr}, row...))
+		}
+	}
+	if header == nil {
+		header = []string{"SERVER", "RESULT"}
+	}
+	return p.table(header, rows)
+}
+
+func (p *printer) table(header []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	if len(header) > 1 {
+		fmt.Fprintln(tw, strings.Join(header, "\t"))
+	}
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// errorMessage shortens the client errors shown to operators
+func errorMessage(err error) string {
+	var statusErr *client.StatusError
+	switch {
+	case errors.Is(err, client.ErrNotFound):
+		return "key not found"
+	case errors.As(err, &statusErr):
+		return fmt.Sprintf("%d %s", statusErr.StatusCode, statusErr.Message)
+	default:
+		return err.Error()
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nishanth-gowda/kv-store/cache"
+	"github.com/nishanth-gowda/kv-store/server"
+)
+
+// newTestTargets starts n servers wrapping the real handlers
+func newTestTargets(t *testing.T, n int) []target {
+	t.Helper()
+	addrs := make([]string, n)
+	for i := range addrs {
+		c, err := cache.NewLRUCache(10, "", false, 0, 0)
+		if err != nil {
+			t.Fatalf("Failed to create cache: %v", err)
+		}
+		t.Cleanup(func() { c.Close() })
+		srv := httptest.NewServer(server.New(c))
+		t.Cleanup(srv.Close)
+		addrs[i] = srv.URL
+	}
+
+	targets, err := newTargets(strings.Join(addrs, ","), time.Second)
+	if err != nil {
+		t.Fatalf("Failed to create targets: %v", err)
+	}
+	return targets
+}
+
+func TestNewTargets(t *testing.T) {
+	targets, err := newTargets(" localhost:8080, ,https://kv.example:8443 ", time.Second)
+	if err != nil {
+		t.Fatalf("newTargets failed: %v", err)
+	}
+	var addrs []string
+	for _, target := range targets {
+		addrs = append(addrs, target.addr)
+	}
+	if want := []string{"http://localhost:8080", "https://kv.example:8443"}; !slices.Equal(addrs, want) {
+		t.Fatalf("newTargets returned %v, want %v", addrs, want)
+	}
+
+	for _, servers := range []string{"", " , ", "ftp://localhost"} {
+		if _, err := newTargets(servers, time.Second); err == nil {
+			t.Errorf("newTargets(%q) succeeded, want an error", servers)
+		}
+	}
+}
+
+func TestRunRejectsInvalidCommands(t *testing.T) {
+	// Never contacted, since the arguments are checked first
+	targets, err := newTargets("localhost:1", time.Second)
+	if err != nil {
+		t.Fatalf("newTargets failed: %v", err)
+	}
+	p := &printer{w: &bytes.Buffer{}, format: "table"}
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"frobnicate"}, `unknown command "frobnicate"`},
+		{[]string{"get"}, "usage: get KEY"},
+		{[]string{"get", "a", "b"}, "usage: get KEY"},
+		{[]string{"set", "a"}, "usage: set KEY VALUE [TTL]"},
+		{[]string{"set", "a", "b", "1m", "extra"}, "usage: set KEY VALUE [TTL]"},
+		{[]string{"del"}, "usage: del KEY [KEY...]"},
+		{[]string{"keys", "a", "b"}, "usage: keys [PREFIX]"},
+	}
+	for _, tt := range tests {
+		err := run(context.Background(), targets, p, tt.args)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("run(%q) returned %v, want %q", tt.args, err, tt.want)
+		}
+	}
+}
+
+func TestRunCommands(t *testing.T) {
+	targets := newTestTargets(t, 1)
+	var out bytes.Buffer
+	p := &printer{w: &out, format: "table"}
+	ctx := context.Background()
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"set", "greeting", "hello"}, "OK\n"},
+		{[]string{"set", "temp", "x", "1m"}, "OK\n"},
+		{[]string{"get", "greeting"}, "hello\n"},
+		{[]string{"keys", "gr"}, "greeting\n"},
+		{[]string{"del", "greeting", "temp"}, "OK\n"},
+		{[]string{"keys"}, ""},
+	}
+	for _, tt := range tests {
+		out.Reset()
+		if err := run(ctx, targets, p, tt.args); err != nil {
+			t.Fatalf("run(%q) failed: %v", tt.args, err)
+		}
+		if out.String() != tt.want {
+			t.Fatalf("run(%q) printed %q, want %q", tt.args, out.String(), tt.want)
+		}
+	}
+
+	if err := run(ctx, targets, p, []string{"set", "a", "b", "soon"}); err == nil || !strings.Contains(err.Error(), `invalid TTL "soon"`) {
+		t.Fatalf("set with an invalid TTL returned %v", err)
+	}
+	if err := run(ctx, targets, p, []string{"get", "greeting"}); err == nil || errorMessage(err) != "key not found" {
+		t.Fatalf("get of a deleted key returned %v, want key not found", err)
+	}
+}
+
+func TestRunOnSeveralServers(t *testing.T) {
+	targets := newTestTargets(t, 2)
+	var out bytes.Buffer
+	ctx := context.Background()
+
+	if err := run(ctx, targets[:1], &printer{w: &out, format: "table"}, []string{"set", "k", "v"}); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+
+	// The key exists on the first server only
+	out.Reset()
+	err := run(ctx, targets, &printer{w: &out, format: "json"}, []string{"get", "k"})
+	if err == nil || !strings.Contains(err.Error(), "get failed on 1 of 2 servers") {
+		t.Fatalf("get returned %v, want a failure on 1 of 2 servers", err)
+	}
+	var results []jsonResult
+	if err := json.Unmarshal(out.Bytes(), &results); err != nil {
+		t.Fatalf("Failed to decode output %q: %v", out.String(), err)
+	}
+	want := []jsonResult{
+		{Server: targets[0].addr, Result: "v"},
+		{Server: targets[1].addr, Error: "key not found"},
+	}
+	if !slices.Equal(results, want) {
+		t.Fatalf("get printed %+v, want %+v", results, want)
+	}
+}
+
+func TestCompleteCommand(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"", []string{"del", "exit", "get", "help", "keys", "quit", "set", "stats", "ttl"}},
+		{"s", []string{"set", "stats"}},
+		{"ex", []string{"exit"}},
+		{"x", nil},
+		{"get ", nil},
+	}
+	for _, tt := range tests {
+		if got := completeCommand(tt.line); !slices.Equal(got, tt.want) {
+			t.Errorf("completeCommand(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
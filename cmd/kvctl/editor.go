@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Control keys recognised by the line editor
+const (
+	keyCtrlA     = 1
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyBackspace = 8
+	keyTab       = 9
+	keyLF        = 10
+	keyCtrlU     = 21
+	keyCR        = 13
+	keyEscape    = 27
+	keyDelete    = 127
+)
+
+// rawMode switches the terminal the editor reads from into raw mode and back
+type rawMode interface {
+	makeRaw() error
+	restore() error
+}
+
+// editor is a minimal line editor with history navigation and tab completion
+type editor struct {
+	term rawMode
+	// in is kept across lines, so input read ahead of a line, e.g. pasted
+	// lines or fast typing, is not lost
+	in       *bufio.Reader
+	out      io.Writer
+	history  *history
+	complete func(line string) []string
+}
+
+// newEditor returns an editor reading key presses from in and echoing to out
+func newEditor(term rawMode, in io.Reader, out io.Writer, history *history, complete func(line string) []string) *editor {
+	return &editor{term: term, in: bufio.NewReader(in), out: out, history: history, complete: complete}
+}
+
+func (e *editor) readLine(prompt string) (string, error) {
+	if err := e.term.makeRaw(); err != nil {
+		return "", err
+	}
+	defer e.term.restore()
+
+	in := e.in
+	out := e.out
+
+	var buf []rune
+	cursor := 0
+	// histIndex == len(history) is the line being edited
+	histIndex := len(e.history.lines)
+	var draft []rune
+
+	redraw := func() {
+		fmt.Fprintf(out, "\r\x1b[K%s%s", prompt, string(buf))
+		if back := len(buf) - cursor; back > 0 {
+			fmt.Fprintf(out, "\x1b[%dD", back)
+		}
+	}
+	recall := func(index int) {
+		if index < 0 || index > len(e.history.lines) {
+			return
+		}
+		if histIndex == len(e.history.lines) {
+			draft = buf
+		}
+		histIndex = index
+		if index == len(e.history.lines) {
+			buf = draft
+		} else {
+			buf = []rune(e.history.lines[index])
+		}
+		cursor = len(buf)
+		redraw()
+	}
+
+	redraw()
+	for {
+		r, _, err := in.ReadRune()
+		if err != nil {
+			if err == io.EOF && len(buf) > 0 {
+				fmt.Fprint(out, "\n")
+				return string(buf), nil
+			}
+			return "", err
+		}
+
+		switch r {
+		case keyCR, keyLF:
+			fmt.Fprint(out, "\n")
+			return string(buf), nil
+		case keyCtrlC:
+			fmt.Fprint(out, "^C\n")
+			return "", errInterrupted
+		case keyCtrlD:
+			if len(buf) == 0 {
+				fmt.Fprint(out, "\n")
+				return "", io.EOF
+			}
+		case keyCtrlA:
+			cursor = 0
+			redraw()
+		case keyCtrlE:
+			cursor = len(buf)
+			redraw()
+		case keyCtrlU:
+			buf = append([]rune{}, buf[cursor:]...)
+			cursor = 0
+			redraw()
+		case keyBackspace, keyDelete:
+			if cursor > 0 {
+				buf = append(buf[:cursor-1], buf[cursor:]...)
+				cursor--
+				redraw()
+			}
+		case keyTab:
+			e.completeLine(&buf, &cursor, prompt, redraw)
+		case keyEscape:
+			// Arrow keys arrive as ESC [ A..D
+			if next, _, err := in.ReadRune(); err != nil || next != '[' {
+				continue
+			}
+			code, _, err := in.ReadRune()
+			if err != nil {
+				continue
+			}
+			switch code {
+			case 'A':
+				recall(histIndex - 1)
+			case 'B':
+				recall(histIndex + 1)
+			case 'C':
+				if cursor < len(buf) {
+					cursor++
+					redraw()
+				}
+			case 'D':
+				if cursor > 0 {
+					cursor--
+					redraw()
+				}
+			}
+		default:
+			if r < 32 {
+				continue
+			}
+			buf = append(buf[:cursor], append([]rune{r}, buf[cursor:]...)...)
+			cursor++
+			redraw()
+		}
+	}
+}
+
+// completeLine completes the word before the cursor, or lists the candidates if ambiguous
+func (e *editor) completeLine(buf *[]rune, cursor *int, prompt string, redraw func()) {
+	if *cursor != len(*buf) {
+		return
+	}
+
+	line := string(*buf)
+	matches := e.complete(line)
+	switch len(matches) {
+	case 0:
+		return
+	case 1:
+		*buf = []rune(matches[0] + " ")
+	default:
+		common := matches[0]
+		for _, match := range matches[1:] {
+			for !strings.HasPrefix(match, common) {
+				common = common[:len(common)-1]
+			}
+		}
+		if len(common) > len(line) {
+			*buf = []rune(common)
+		} else {
+			fmt.Fprintf(e.out, "\n%s\n", strings.Join(matches, "  "))
+		}
+	}
+	*cursor = len(*buf)
+	redraw()
+}
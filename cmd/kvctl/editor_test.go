@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// fakeTerminal counts the switches to and from raw mode
+type fakeTerminal struct {
+	raw, restored int
+}
+
+func (t *fakeTerminal) makeRaw() error {
+	t.raw++
+	return nil
+}
+
+func (t *fakeTerminal) restore() error {
+	t.restored++
+	return nil
+}
+
+// newTestEditor returns an editor reading the given key presses
+func newTestEditor(keys string, lines ...string) (*editor, *fakeTerminal) {
+	term := &fakeTerminal{}
+	return newEditor(term, strings.NewReader(keys), io.Discard, &history{lines: lines}, completeCommand), term
+}
+
+func TestEditorEditing(t *testing.T) {
+	tests := []struct {
+		name string
+		keys string
+		want string
+	}{
+		{"plain", "get a\r", "get a"},
+		{"line feed", "get a\n", "get a"},
+		{"backspace", "gex\x7ft\r", "get"},
+		{"ctrl-h", "gex\bt\r", "get"},
+		{"ctrl-a inserts at the start", "et\x01g\r", "get"},
+		{"ctrl-e moves to the end", "et\x01g\x05 a\r", "get a"},
+		{"ctrl-u clears before the cursor", "junk\x15get\r", "get"},
+		{"left arrow", "gt\x1b[De\r", "get"},
+		{"right arrow", "gt\x1b[D\x1b[C a\r", "gt a"},
+		{"control characters are ignored", "ge\x02t\r", "get"},
+		{"tab completes a unique command", "st\t\r", "stats "},
+		{"tab on an ambiguous prefix keeps the line", "s\tet\r", "set"},
+		{"tab leaves arguments alone", "get k\t\r", "get k"},
+		{"unicode", "set k é\r", "set k é"},
+		{"end of input ends the line", "get a", "get a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _ := newTestEditor(tt.keys)
+			line, err := e.readLine(prompt)
+			if err != nil {
+				t.Fatalf("readLine failed: %v", err)
+			}
+			if line != tt.want {
+				t.Fatalf("readLine returned %q, want %q", line, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditorKeepsInputReadAhead(t *testing.T) {
+	// Pasted lines arrive in one read
+	e, term := newTestEditor("set a 1\rget a\rdel a\r")
+	for _, want := range []string{"set a 1", "get a", "del a"} {
+		line, err := e.readLine(prompt)
+		if err != nil {
+			t.Fatalf("readLine failed before %q: %v", want, err)
+		}
+		if line != want {
+			t.Fatalf("readLine returned %q, want %q", line, want)
+		}
+	}
+	if _, err := e.readLine(prompt); err != io.EOF {
+		t.Fatalf("readLine at end of input returned %v, want io.EOF", err)
+	}
+	if term.raw != 4 || term.restored != 4 {
+		t.Fatalf("terminal made raw %d times and restored %d times, want 4 each", term.raw, term.restored)
+	}
+}
+
+func TestEditorHistory(t *testing.T) {
+	tests := []struct {
+		name string
+		keys string
+		want string
+	}{
+		{"up recalls the last line", "\x1b[A\r", "set b 1"},
+		{"up twice recalls the one before", "\x1b[A\x1b[A\r", "get a"},
+		{"up stops at the oldest line", "\x1b[A\x1b[A\x1b[A\r", "get a"},
+		{"down returns to the newer line", "\x1b[A\x1b[A\x1b[B\r", "set b 1"},
+		{"down restores the draft", "ke\x1b[A\x1b[By\r", "key"},
+		{"recalled lines can be edited", "\x1b[A\x7f2\r", "set b 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _ := newTestEditor(tt.keys, "get a", "set b 1")
+			line, err := e.readLine(prompt)
+			if err != nil {
+				t.Fatalf("readLine failed: %v", err)
+			}
+			if line != tt.want {
+				t.Fatalf("readLine returned %q, want %q", line, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditorControlKeys(t *testing.T) {
+	e, _ := newTestEditor("get\x03\x04")
+	if _, err := e.readLine(prompt); !errors.Is(err, errInterrupted) {
+		t.Fatalf("Ctrl-C returned %v, want errInterrupted", err)
+	}
+	if _, err := e.readLine(prompt); err != io.EOF {
+		t.Fatalf("Ctrl-D on an empty line returned %v, want io.EOF", err)
+	}
+
+	// Ctrl-D is ignored on a line with text
+	e, _ = newTestEditor("get\x04 a\r")
+	if line, err := e.readLine(prompt); err != nil || line != "get a" {
+		t.Fatalf("readLine returned %q, %v, want \"get a\"", line, err)
+	}
+}
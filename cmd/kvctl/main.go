@@ -0,0 +1,106 @@
+// kvctl is a command-line client for the kv-store HTTP API.
+//
+// Usage:
+//
+//	kvctl [flags] <command> [args...]
+//	kvctl [flags]                        # interactive mode
+//
+// Commands are run against every server given with -servers.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nishanth-gowda/kv-store/client"
+)
+
+const defaultServer = "http://localhost:8080"
+
+// target is a server addressed by kvctl
+type target struct {
+	addr   string
+	client *client.Client
+}
+
+func main() {
+	servers := flag.String("servers", envOr("KVCTL_SERVERS", defaultServer), "comma-separated list of server URLs")
+	output := flag.String("o", "table", "output format: table or json")
+	timeout := flag.Duration("timeout", 5*time.Second, "per-request timeout")
+	flag.Usage = usage
+	flag.Parse()
+
+	if *output != "table" && *output != "json" {
+		fmt.Fprintf(os.Stderr, "kvctl: unknown output format %q\n", *output)
+		os.Exit(2)
+	}
+
+	targets, err := newTargets(*servers, *timeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kvctl: %v\n", err)
+		os.Exit(2)
+	}
+
+	p := &printer{w: os.Stdout, format: *output}
+
+	if flag.NArg() == 0 {
+		if err := runREPL(targets, p); err != nil {
+			fmt.Fprintf(os.Stderr, "kvctl: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := run(context.Background(), targets, p, flag.Args()); err != nil {
+		fmt.Fprintf(os.Stderr, "kvctl: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: kvctl [flags] <command> [args...]\n\n")
+	fmt.Fprintf(os.Stderr, "Run without a command to start an interactive session.\n\n")
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-28s %s\n", cmd.name+" "+cmd.args, cmd.help)
+	}
+	fmt.Fprintf(os.Stderr, "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+// newTargets creates a client for every server in the comma-separated list
+func newTargets(servers string, timeout time.Duration) ([]target, error) {
+	var targets []target
+	for _, addr := range strings.Split(servers, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+		if !strings.Contains(addr, "://") {
+			addr = "http://" + addr
+		}
+
+		c, err := client.New(addr, client.WithTimeout(timeout))
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, target{addr: addr, client: c})
+	}
+
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no servers given")
+	}
+	return targets, nil
+}
+
+// envOr returns the environment variable or fallback if it is unset
+func envOr(name, fallback string) string {
+	if value, ok := os.LookupEnv(name); ok {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	prompt         = "kvctl> "
+	historyFile    = ".kvctl_history"
+	maxHistorySize = 1000
+)
+
+// errInterrupted is returned by readLine when the user presses Ctrl-C
+var errInterrupted = errors.New("interrupted")
+
+// runREPL reads commands from stdin until "exit" or end of input.
+// On a terminal it supports history (up/down) and tab completion of commands.
+func runREPL(targets []target, p *printer) error {
+	history := loadHistory()
+	defer saveHistory(history)
+
+	var lines lineReader
+	if term, err := newTerminal(os.Stdin, os.Stdout); err == nil {
+		fmt.Printf("Connected to %s. Type \"help\" for commands.\n", targetNames(targets))
+		lines = newEditor(term, term.in, term.out, history, completeCommand)
+	} else {
+		lines = &plainReader{scanner: bufio.NewScanner(os.Stdin)}
+	}
+
+	for {
+		line, err := lines.readLine(prompt)
+		if errors.Is(err, errInterrupted) {
+			continue
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		args := strings.Fields(line)
+		if len(args) == 0 {
+			continue
+		}
+		history.add(line)
+
+		switch args[0] {
+		case "exit", "quit":
+			return nil
+		case "help":
+			printHelp()
+			continue
+		}
+
+		// Ctrl-C cancels the running command instead of exiting
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		if err := run(ctx, targets, p, args); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", errorMessage(err))
+		}
+		stop()
+	}
+}
+
+func printHelp() {
+	for _, cmd := range commands {
+		fmt.Printf("  %-28s %s\n", cmd.name+" "+cmd.args, cmd.help)
+	}
+	fmt.Printf("  %-28s %s\n", "help", "show this help")
+	fmt.Printf("  %-28s %s\n", "exit", "leave the session")
+}
+
+func targetNames(targets []target) string {
+	names := make([]string, len(targets))
+	for i, t := range targets {
+		names[i] = t.addr
+	}
+	return strings.Join(names, ", ")
+}
+
+// completeCommand returns the command names starting with the word being typed.
+// Only the first word of a line is completed.
+func completeCommand(line string) []string {
+	if strings.ContainsRune(line, ' ') {
+		return nil
+	}
+
+	names := []string{"exit", "help", "quit"}
+	for _, cmd := range commands {
+		names = append(names, cmd.name)
+	}
+	sort.Strings(names)
+
+	var matches []string
+	for _, name := range names {
+		if strings.HasPrefix(name, line) {
+			matches = append(matches, name)
+		}
+	}
+	return matches
+}
+
+// lineReader reads one line of input after printing a prompt
+type lineReader interface {
+	readLine(prompt string) (string, error)
+}
+
+// plainReader reads lines when stdin is not a terminal
+type plainReader struct {
+	scanner *bufio.Scanner
+}
+
+func (r *plainReader) readLine(string) (string, error) {
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return r.scanner.Text(), nil
+}
+
+// history holds previously entered lines, oldest first
+type history struct {
+	lines []string
+}
+
+func (h *history) add(line string) {
+	if n := len(h.lines); n > 0 && h.lines[n-1] == line {
+		return
+	}
+	h.lines = append(h.lines, line)
+	if len(h.lines) > maxHistorySize {
+		h.lines = h.lines[len(h.lines)-maxHistorySize:]
+	}
+}
+
+func historyPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, historyFile)
+}
+
+// loadHistory reads the history file; a missing file yields an empty history
+func loadHistory() *history {
+	h := &history{}
+	path := historyPath()
+	if path == "" {
+		return h
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return h
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			h.add(line)
+		}
+	}
+	return h
+}
+
+// saveHistory writes the history file, ignoring errors since history is best effort
+func saveHistory(h *history) {
+	path := historyPath()
+	if path == "" {
+		return
+	}
+
+	data := strings.Join(h.lines, "\n")
+	if data != "" {
+		data += "\n"
+	}
+	_ = os.WriteFile(path, []byte(data), 0600)
+}
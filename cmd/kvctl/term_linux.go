@@ -0,0 +1,43 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// terminal switches a tty between cooked and raw mode for the line editor
+type terminal struct {
+	in       *os.File
+	out      *os.File
+	fd       int
+	original unix.Termios
+}
+
+// newTerminal returns an error if in is not a terminal
+func newTerminal(in, out *os.File) (*terminal, error) {
+	fd := int(in.Fd())
+	original, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return nil, err
+	}
+	return &terminal{in: in, out: out, fd: fd, original: *original}, nil
+}
+
+// makeRaw disables echo, line buffering and signal keys so the editor sees every key press.
+// Output processing stays enabled so "\n" still moves to the start of the next line.
+func (t *terminal) makeRaw() error {
+	raw := t.original
+	raw.Iflag &^= unix.ICRNL | unix.IXON
+	raw.Lflag &^= unix.ECHO | unix.ICANON | unix.ISIG | unix.IEXTEN
+	raw.Cc[unix.VMIN] = 1
+	raw.Cc[unix.VTIME] = 0
+	return unix.IoctlSetTermios(t.fd, unix.TCSETS, &raw)
+}
+
+// restore puts the terminal back in the mode it was in before makeRaw
+func (t *terminal) restore() error {
+	return unix.IoctlSetTermios(t.fd, unix.TCSETS, &t.original)
+}
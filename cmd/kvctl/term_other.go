@@ -0,0 +1,26 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// terminal is only supported on Linux; elsewhere kvctl reads plain lines
+type terminal struct {
+	in  *os.File
+	out *os.File
+}
+
+func newTerminal(in, out *os.File) (*terminal, error) {
+	return nil, errors.New("line editing is not supported on this platform")
+}
+
+func (t *terminal) makeRaw() error {
+	return errors.New("line editing is not supported on this platform")
+}
+
+func (t *terminal) restore() error {
+	return nil
+}
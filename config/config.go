@@ -0,0 +1,448 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix is the prefix of every environment variable read by Load
+const EnvPrefix = "KV_"
+
+// Config holds the server configuration
+type Config struct {
+	Cache  CacheConfig
+	WAL    WALConfig
+	Server ServerConfig
+	Log    LogConfig
+
+	// ConfigFile is the file the configuration was read from, if any
+	ConfigFile string
+	// PrintConfig asks the server to print the effective configuration and exit
+	PrintConfig bool
+}
+
+// CacheConfig holds the in-memory cache settings
+type CacheConfig struct {
+	Capacity int
+	// DefaultTTL applies to keys set without a TTL; zero means they never expire
+	DefaultTTL time.Duration
+	// EvictionPolicy is lru, random or noeviction
+	EvictionPolicy string
+	// SlidingGranularity is how far reads extend a sliding expiration before
+	// the extension is written to the WAL
+	SlidingGranularity time.Duration
+	// CompressionThreshold is the size from which string and []byte values
+	// are kept compressed in memory; zero disables compression
+	CompressionThreshold int
+}
+
+// WALConfig holds the write-ahead log settings
+type WALConfig struct {
+	// Directory of the WAL segments; empty disables the WAL
+	Directory   string
+	ForceSync   bool
+	MaxFileSize int
+	MaxSegments int
+	// SnapshotOnShutdown writes a snapshot and drops the replayed segments on clean shutdown
+	SnapshotOnShutdown bool
+	// EncryptionKeyFile and EncryptionKeys give the keys that encrypt WAL
+	// records, as a file or inline, in the format of wal.ParseKeyRing;
+	// neither set leaves records in plaintext
+	EncryptionKeyFile string
+	EncryptionKeys    string
+	// CompressionThreshold is the value size from which records are
+	// compressed; zero disables compression
+	CompressionThreshold int
+}
+
+// ServerConfig holds the listener settings
+type ServerConfig struct {
+	Addr string
+	// ShutdownTimeout bounds how long in-flight requests are drained on shutdown
+	ShutdownTimeout time.Duration
+}
+
+// LogConfig holds the logging settings
+type LogConfig struct {
+	Level slog.Level
+}
+
+// Default returns the configuration used when nothing is overridden
+func Default() *Config {
+	return &Config{
+		Cache: CacheConfig{
+			Capacity:           10000,
+			EvictionPolicy:     "lru",
+			SlidingGranularity: time.Second,
+		},
+		WAL: WALConfig{
+			Directory:   "./wal",
+			ForceSync:   false,
+			MaxFileSize: 10 * 1024 * 1024,
+			MaxSegments: 10,
+		},
+		Server: ServerConfig{
+			Addr:            ":8080",
+			ShutdownTimeout: 10 * time.Second,
+		},
+		Log: LogConfig{
+			Level: slog.LevelInfo,
+		},
+	}
+}
+
+// setting describes one configuration value and how each source addresses it.
+// The file key is "section.name", the flag is "section-name" with underscores
+// as dashes and the environment variable is KV_SECTION_NAME.
+// Reloadable settings can be applied to a running server on SIGHUP, and
+// secret ones are redacted when the configuration is printed.
+type setting struct {
+	section    string
+	name       string
+	usage      string
+	isBool     bool
+	reloadable bool
+	secret     bool
+	get        func(c *Config) any
+	set        func(c *Config, value string) error
+}
+
+func (s setting) key() string {
+	return s.section + "." + s.name
+}
+
+func (s setting) flagName() string {
+	return strings.ReplaceAll(s.section+"-"+s.name, "_", "-")
+}
+
+func (s setting) envName() string {
+	return EnvPrefix + strings.ToUpper(s.section+"_"+s.name)
+}
+
+var settings = []setting{
+	{
+		section: "cache", name: "capacity", usage: "maximum number of cache entries", reloadable: true,
+		get: func(c *Config) any { return c.Cache.Capacity },
+		set: func(c *Config, v string) error { return parseInt(v, &c.Cache.Capacity) },
+	},
+	{
+		section: "cache", name: "default_ttl", usage: "TTL of keys set without one, e.g. 1h (0 never expires)", reloadable: true,
+		get: func(c *Config) any { return c.Cache.DefaultTTL.String() },
+		set: func(c *Config, v string) error { return parseDuration(v, &c.Cache.DefaultTTL) },
+	},
+	{
+		section: "cache", name: "eviction_policy", usage: "entry evicted for a new key when full: lru, random or noeviction", reloadable: true,
+		get: func(c *Config) any { return c.Cache.EvictionPolicy },
+		set: func(c *Config, v string) error { c.Cache.EvictionPolicy = strings.TrimSpace(v); return nil },
+	},
+	{
+		section: "cache", name: "sliding_granularity", usage: "how far reads extend a sliding TTL before it is logged, e.g. 1s (0 logs every read)", reloadable: true,
+		get: func(c *Config) any { return c.Cache.SlidingGranularity.String() },
+		set: func(c *Config, v string) error { return parseDuration(v, &c.Cache.SlidingGranularity) },
+	},
+	{
+		section: "cache", name: "compression_threshold", usage: "size from which string values are kept compressed in memory, e.g. 4KB (0 disables)", reloadable: true,
+		get: func(c *Config) any { return c.Cache.CompressionThreshold },
+		set: func(c *Config, v string) error { return parseSize(v, &c.Cache.CompressionThreshold) },
+	},
+	{
+		section: "wal", name: "dir", usage: "WAL directory (empty disables the WAL)",
+		get: func(c *Config) any { return c.WAL.Directory },
+		set: func(c *Config, v string) error { c.WAL.Directory = v; return nil },
+	},
+	{
+		section: "wal", name: "force_sync", usage: "fsync the WAL on every write", isBool: true,
+		get: func(c *Config) any { return c.WAL.ForceSync },
+		set: func(c *Config, v string) error { return parseBool(v, &c.WAL.ForceSync) },
+	},
+	{
+		section: "wal", name: "max_file_size", usage: "WAL segment size before rotation, in bytes or with a KB/MB/GB suffix",
+		get: func(c *Config) any { return c.WAL.MaxFileSize },
+		set: func(c *Config, v string) error { return parseSize(v, &c.WAL.MaxFileSize) },
+	},
+	{
+		section: "wal", name: "max_segments", usage: "number of WAL segments after which they are compacted into a snapshot",
+		get: func(c *Config) any { return c.WAL.MaxSegments },
+		set: func(c *Config, v string) error { return parseInt(v, &c.WAL.MaxSegments) },
+	},
+	{
+		section: "wal", name: "snapshot_on_shutdown", usage: "write a snapshot on clean shutdown", isBool: true,
+		get: func(c *Config) any { return c.WAL.SnapshotOnShutdown },
+		set: func(c *Config, v string) error { return parseBool(v, &c.WAL.SnapshotOnShutdown) },
+	},
+	{
+		section: "wal", name: "encryption_key_file", usage: "file of id:base64-key lines encrypting WAL records with the last key (empty disables encryption)",
+		get: func(c *Config) any { return c.WAL.EncryptionKeyFile },
+		set: func(c *Config, v string) error { c.WAL.EncryptionKeyFile = strings.TrimSpace(v); return nil },
+	},
+	{
+		section: "wal", name: "encryption_keys", usage: "comma-separated id:base64-key list used instead of a key file", secret: true,
+		get: func(c *Config) any { return c.WAL.EncryptionKeys },
+		set: func(c *Config, v string) error { c.WAL.EncryptionKeys = strings.TrimSpace(v); return nil },
+	},
+	{
+		section: "wal", name: "compression_threshold", usage: "value size from which WAL records are compressed, e.g. 1KB (0 disables)",
+		get: func(c *Config) any { return c.WAL.CompressionThreshold },
+		set: func(c *Config, v string) error { return parseSize(v, &c.WAL.CompressionThreshold) },
+	},
+	{
+		section: "server", name: "addr", usage: "HTTP listen address",
+		get: func(c *Config) any { return c.Server.Addr },
+		set: func(c *Config, v string) error { c.Server.Addr = v; return nil },
+	},
+	{
+		section: "server", name: "shutdown_timeout", usage: "time allowed to drain in-flight requests on shutdown",
+		get: func(c *Config) any { return c.Server.ShutdownTimeout.String() },
+		set: func(c *Config, v string) error { return parseDuration(v, &c.Server.ShutdownTimeout) },
+	},
+	{
+		section: "log", name: "level", usage: "log level: debug, info, warn or error", reloadable: true,
+		get: func(c *Config) any { return strings.ToLower(c.Log.Level.String()) },
+		set: func(c *Config, v string) error { return c.Log.Level.UnmarshalText([]byte(strings.TrimSpace(v))) },
+	},
+}
+
+// findSetting returns the setting addressed by a "section.name" file key
+func findSetting(key string) (setting, bool) {
+	for _, s := range settings {
+		if s.key() == key {
+			return s, true
+		}
+	}
+	return setting{}, false
+}
+
+// Load builds the configuration from, in increasing order of precedence,
+// the defaults, the config file, KV_* environment variables and command-line flags.
+// The config file is given by -config or KV_CONFIG.
+func Load(args []string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("kv-store", flag.ContinueOnError)
+	fs.StringVar(&cfg.ConfigFile, "config", "", "path to a JSON, YAML or TOML config file (env "+EnvPrefix+"CONFIG)")
+	fs.BoolVar(&cfg.PrintConfig, "print-config", false, "print the effective configuration and exit")
+
+	// Flags are collected first and applied last so they win over every other source
+	flagValues := make(map[string]string)
+	for _, s := range settings {
+		usage := fmt.Sprintf("%s (env %s, default %v)", s.usage, s.envName(), s.get(cfg))
+		collect := func(v string) error {
+			flagValues[s.key()] = v
+			return nil
+		}
+		if s.isBool {
+			fs.BoolFunc(s.flagName(), usage, collect)
+		} else {
+			fs.Func(s.flagName(), usage, collect)
+		}
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	if cfg.ConfigFile == "" {
+		cfg.ConfigFile, _ = lookupEnv(EnvPrefix + "CONFIG")
+	}
+	if cfg.ConfigFile != "" {
+		fileValues, err := readFile(cfg.ConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range fileValues {
+			s, ok := findSetting(key)
+			if !ok {
+				return nil, fmt.Errorf("%s: unknown setting %q", cfg.ConfigFile, key)
+			}
+			if err := s.set(cfg, value); err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", cfg.ConfigFile, key, err)
+			}
+		}
+	}
+
+	for _, s := range settings {
+		if value, ok := lookupEnv(s.envName()); ok {
+			if err := s.set(cfg, value); err != nil {
+				return nil, fmt.Errorf("%s: %w", s.envName(), err)
+			}
+		}
+	}
+
+	for _, s := range settings {
+		if value, ok := flagValues[s.key()]; ok {
+			if err := s.set(cfg, value); err != nil {
+				return nil, fmt.Errorf("-%s: %w", s.flagName(), err)
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate reports every invalid setting at once
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Cache.Capacity <= 0 {
+		errs = append(errs, fmt.Errorf("cache.capacity must be positive, got %d", c.Cache.Capacity))
+	}
+	if c.WAL.Directory != "" {
+		if c.WAL.MaxFileSize <= 0 {
+			errs = append(errs, fmt.Errorf("wal.max_file_size must be positive, got %d", c.WAL.MaxFileSize))
+		}
+		if c.WAL.MaxSegments <= 0 {
+			errs = append(errs, fmt.Errorf("wal.max_segments must be positive, got %d", c.WAL.MaxSegments))
+		}
+	}
+	if c.Cache.DefaultTTL < 0 {
+		errs = append(errs, fmt.Errorf("cache.default_ttl must not be negative, got %s", c.Cache.DefaultTTL))
+	}
+	switch c.Cache.EvictionPolicy {
+	case "lru", "random", "noeviction":
+	default:
+		errs = append(errs, fmt.Errorf("cache.eviction_policy must be lru, random or noeviction, got %q", c.Cache.EvictionPolicy))
+	}
+	if c.Cache.SlidingGranularity < 0 {
+		errs = append(errs, fmt.Errorf("cache.sliding_granularity must not be negative, got %s", c.Cache.SlidingGranularity))
+	}
+	if c.Cache.CompressionThreshold < 0 {
+		errs = append(errs, fmt.Errorf("cache.compression_threshold must not be negative, got %d", c.Cache.CompressionThreshold))
+	}
+	if c.WAL.CompressionThreshold < 0 {
+		errs = append(errs, fmt.Errorf("wal.compression_threshold must not be negative, got %d", c.WAL.CompressionThreshold))
+	}
+	if c.WAL.EncryptionKeyFile != "" && c.WAL.EncryptionKeys != "" {
+		errs = append(errs, errors.New("set only one of wal.encryption_key_file and wal.encryption_keys"))
+	}
+	if c.Server.Addr == "" {
+		errs = append(errs, errors.New("server.addr is required"))
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("server.shutdown_timeout must be positive, got %s", c.Server.ShutdownTimeout))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// Print writes the effective configuration as JSON grouped by section
+func (c *Config) Print(w io.Writer) error {
+	sections := make(map[string]map[string]any)
+	for _, s := range settings {
+		if sections[s.section] == nil {
+			sections[s.section] = make(map[string]any)
+		}
+		value := s.get(c)
+		if s.secret && value != "" {
+			value = "redacted"
+		}
+		sections[s.section][s.name] = value
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sections)
+}
+
+// RestartRequired returns the keys of settings that differ in next but
+// cannot be applied without restarting the server
+func (c *Config) RestartRequired(next *Config) []string {
+	var keys []string
+	for _, s := range settings {
+		if !s.reloadable && s.get(c) != s.get(next) {
+			keys = append(keys, s.key())
+		}
+	}
+	return keys
+}
+
+func parseInt(v string, dst *int) error {
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		return fmt.Errorf("invalid integer %q", v)
+	}
+	*dst = n
+	return nil
+}
+
+func parseBool(v string, dst *bool) error {
+	b, err := strconv.ParseBool(strings.TrimSpace(v))
+	if err != nil {
+		return fmt.Errorf("invalid boolean %q", v)
+	}
+	*dst = b
+	return nil
+}
+
+func parseDuration(v string, dst *time.Duration) error {
+	d, err := time.ParseDuration(strings.TrimSpace(v))
+	if err != nil {
+		return fmt.Errorf("invalid duration %q", v)
+	}
+	*dst = d
+	return nil
+}
+
+// parseSize accepts a byte count with an optional KB, MB or GB suffix (powers of 1024)
+func parseSize(v string, dst *int) error {
+	s := strings.ToUpper(strings.TrimSpace(v))
+	multiplier := 1
+	for _, unit := range []struct {
+		suffix     string
+		multiplier int
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(s, unit.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("invalid size %q", v)
+	}
+	*dst = n * multiplier
+	return nil
+}
+
+// readFile reads a config file into "section.name" keys based on its extension
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var values map[string]string
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		values, err = parseJSON(data)
+	case ".yaml", ".yml":
+		values, err = parseYAML(data)
+	case ".toml":
+		values, err = parseTOML(data)
+	default:
+		return nil, fmt.Errorf("%s: unsupported config file extension %q", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return values, nil
+}
@@ -0,0 +1,160 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The config files only need two levels (section and setting), so YAML and
+// TOML are parsed as that subset rather than pulling in full parsers.
+
+// parseJSON flattens a JSON object of sections into "section.name" keys
+func parseJSON(data []byte) (map[string]string, error) {
+	var sections map[string]map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&sections); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	values := make(map[string]string)
+	for section, fields := range sections {
+		for name, value := range fields {
+			switch value.(type) {
+			case string, bool, json.Number:
+				values[section+"."+name] = fmt.Sprint(value)
+			default:
+				return nil, fmt.Errorf("%s.%s: expected a string, number or boolean", section, name)
+			}
+		}
+	}
+	return values, nil
+}
+
+// parseYAML reads top-level section mappings holding indented scalar settings:
+//
+//	wal:
+//	  dir: ./wal
+//	  force_sync: true
+func parseYAML(data []byte) (map[string]string, error) {
+	values := make(map[string]string)
+	var section string
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		raw := stripComment(scanner.Text())
+		if strings.TrimSpace(raw) == "" || strings.TrimSpace(raw) == "---" {
+			continue
+		}
+
+		indented := raw[0] == ' ' || raw[0] == '\t'
+		name, value, ok := strings.Cut(strings.TrimSpace(raw), ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", lineNo)
+		}
+		name = strings.TrimSpace(name)
+		value = strings.TrimSpace(value)
+
+		if !indented {
+			if value != "" {
+				return nil, fmt.Errorf("line %d: settings must be nested under a section", lineNo)
+			}
+			section = name
+			continue
+		}
+		if section == "" {
+			return nil, fmt.Errorf("line %d: indented setting outside of a section", lineNo)
+		}
+
+		unquoted, err := unquote(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		values[section+"."+name] = unquoted
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// parseTOML reads [section] tables holding "name = value" settings
+func parseTOML(data []byte) (map[string]string, error) {
+	values := make(map[string]string)
+	var section string
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: unterminated table header", lineNo)
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key = value\"", lineNo)
+		}
+		if section == "" {
+			return nil, fmt.Errorf("line %d: settings must be inside a [section]", lineNo)
+		}
+
+		unquoted, err := unquote(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		values[section+"."+strings.TrimSpace(name)] = unquoted
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// stripComment removes a trailing # comment that is not inside quotes
+func stripComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote == 0 && (r == '"' || r == '\''):
+			quote = r
+		case quote == 0 && r == '#':
+			return strings.TrimRight(line[:i], " \t")
+		}
+	}
+	return strings.TrimRight(line, " \t")
+}
+
+// unquote strips matching single or double quotes from a scalar value
+func unquote(value string) (string, error) {
+	if len(value) < 2 {
+		return value, nil
+	}
+	switch value[0] {
+	case '"':
+		s, err := strconv.Unquote(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid quoted string %s", value)
+		}
+		return s, nil
+	case '\'':
+		if value[len(value)-1] != '\'' {
+			return "", fmt.Errorf("invalid quoted string %s", value)
+		}
+		return value[1 : len(value)-1], nil
+	}
+	return value, nil
+}
@@ -2,7 +2,10 @@ module github.com/nishanth-gowda/kv-store
 
 go 1.24.5
 
-require github.com/labstack/echo/v4 v4.13.4
+require (
+	github.com/labstack/echo/v4 v4.13.4
+	golang.org/x/sys v0.33.0
+)
 
 require (
 	github.com/labstack/gommon v0.4.2 // indirect
@@ -12,6 +15,5 @@ require (
 	github.com/valyala/fasttemplate v1.2.2 // indirect
 	golang.org/x/crypto v0.38.0 // indirect
 	golang.org/x/net v0.40.0 // indirect
-	golang.org/x/sys v0.33.0 // indirect
 	golang.org/x/text v0.25.0 // indirect
 )
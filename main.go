@@ -1,88 +1,188 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"log"
+	"log/slog"
 	"net/http"
-	"time"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
 
 	"github.com/labstack/echo/v4"
 	"github.com/nishanth-gowda/kv-store/cache"
+	"github.com/nishanth-gowda/kv-store/config"
+	"github.com/nishanth-gowda/kv-store/server"
+	"github.com/nishanth-gowda/kv-store/wal"
 )
 
 func main() {
 
-	c, err := cache.NewLRUCache(3, "./wal", false, 10*1024*1024, 10)
+	cfg, err := config.Load(os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Error loading configuration: %v", err)
+	}
+
+	if cfg.PrintConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatalf("Error printing configuration: %v", err)
+		}
+		return
+	}
+
+	// The level is a LevelVar so SIGHUP can change it at runtime
+	logLevel := new(slog.LevelVar)
+	logLevel.Set(cfg.Log.Level)
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel})))
+
+	var opts []cache.Option
+	keys, err := loadKeyRing(cfg.WAL)
+	if err != nil {
+		log.Fatalf("Error loading WAL encryption keys: %v", err)
+	}
+	if keys != nil {
+		opts = append(opts, cache.WithEncryption(keys))
+		slog.Info("WAL encryption enabled", "active_key_id", keys.ActiveKeyID())
+	}
+	if cfg.WAL.CompressionThreshold > 0 {
+		opts = append(opts, cache.WithWALCompression(cfg.WAL.CompressionThreshold))
+	}
+
+	c, err := cache.NewLRUCache(cfg.Cache.Capacity, cfg.WAL.Directory, cfg.WAL.ForceSync, cfg.WAL.MaxFileSize, cfg.WAL.MaxSegments, opts...)
 	if err != nil {
 		log.Fatalf("Error creating cache: %v", err)
 	}
-	defer c.Close()
+	c.SetDefaultTTL(cfg.Cache.DefaultTTL)
+	c.SetEvictionPolicy(cache.EvictionPolicy(cfg.Cache.EvictionPolicy))
+	c.SetSlidingGranularity(cfg.Cache.SlidingGranularity)
+	c.SetCompressionThreshold(cfg.Cache.CompressionThreshold)
+
+	// Namespaces keep their catalog and WALs next to the default cache's WAL
+	var namespaceDir string
+	if cfg.WAL.Directory != "" {
+		namespaceDir = filepath.Join(cfg.WAL.Directory, "namespaces")
+	}
+	namespaces, err := cache.NewNamespaces(namespaceDir, cfg.WAL.ForceSync, cfg.WAL.MaxFileSize, cfg.WAL.MaxSegments, opts...)
+	if err != nil {
+		log.Fatalf("Error opening namespaces: %v", err)
+	}
 
-	// Create Echo instance
-	e := echo.New()
+	// Create Echo instance with all routes registered
+	e := server.New(c, server.WithNamespaces(namespaces))
 
-	e.POST("/set", SetHandler(c))
-	e.GET("/get", GetHandler(c))
-	e.DELETE("/delete", DeleteHandler(c))
-	e.Start(":8080")
-}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-// SetHandler returns a handler function for POST /set
-func SetHandler(cache *cache.LRUCache) echo.HandlerFunc {
-	return func(c echo.Context) error {
-		key := c.QueryParam("key")
-		value := c.QueryParam("value")
-		ttl := c.QueryParam("ttl")
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
 
-		if key == "" || value == "" {
-			return c.String(http.StatusBadRequest, "key and value are required")
-		}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- e.Start(cfg.Server.Addr)
+	}()
 
-		var ttlDuration time.Duration
-		var err error
-		if ttl != "" {
-			ttlDuration, err = time.ParseDuration(ttl)
-			if err != nil {
-				return c.String(http.StatusBadRequest, "Invalid TTL format")
+	for running := true; running; {
+		select {
+		case <-ctx.Done():
+			slog.Info("shutting down", "timeout", cfg.Server.ShutdownTimeout)
+			running = false
+		case err := <-serveErr:
+			if !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("server stopped", "error", err)
 			}
+			running = false
+		case <-reload:
+			cfg = reloadConfig(cfg, c, logLevel)
 		}
+	}
 
-		if err := cache.Set(key, value, ttlDuration); err != nil {
-			return c.String(http.StatusInternalServerError, err.Error())
-		}
-
-		return c.String(http.StatusOK, "OK")
+	if err := shutdown(e, c, namespaces, cfg); err != nil {
+		slog.Error("shutdown failed", "error", err)
+		os.Exit(1)
 	}
+	slog.Info("shutdown complete")
 }
 
-// GetHandler returns a handler function for GET /get
-func GetHandler(cache *cache.LRUCache) echo.HandlerFunc {
-	return func(c echo.Context) error {
-		key := c.QueryParam("key")
-		if key == "" {
-			return c.String(http.StatusBadRequest, "key is required")
-		}
+// reloadConfig re-reads the configuration and applies the reloadable settings.
+// It returns the configuration now in effect; on error the current one is kept.
+func reloadConfig(current *config.Config, c *cache.LRUCache, logLevel *slog.LevelVar) *config.Config {
+	next, err := config.Load(os.Args[1:], os.LookupEnv)
+	if err != nil {
+		slog.Error("reload failed, keeping current configuration", "error", err)
+		return current
+	}
 
-		value, ok := cache.Get(key)
-		if !ok {
-			return c.String(http.StatusNotFound, "Key not found")
-		}
+	c.SetCapacity(next.Cache.Capacity)
+	c.SetDefaultTTL(next.Cache.DefaultTTL)
+	c.SetEvictionPolicy(cache.EvictionPolicy(next.Cache.EvictionPolicy))
+	c.SetSlidingGranularity(next.Cache.SlidingGranularity)
+	c.SetCompressionThreshold(next.Cache.CompressionThreshold)
+	logLevel.Set(next.Log.Level)
 
-		return c.String(http.StatusOK, value.(string))
+	if keys := current.RestartRequired(next); len(keys) > 0 {
+		slog.Warn("changed settings require a restart and were not applied", "settings", keys)
 	}
+
+	slog.Info("configuration reloaded",
+		"capacity", next.Cache.Capacity,
+		"default_ttl", next.Cache.DefaultTTL,
+		"eviction_policy", next.Cache.EvictionPolicy,
+		"sliding_granularity", next.Cache.SlidingGranularity,
+		"compression_threshold", next.Cache.CompressionThreshold,
+		"log_level", next.Log.Level)
+
+	// Settings that were not applied keep their running values
+	applied := *current
+	applied.Cache = next.Cache
+	applied.Log = next.Log
+	return &applied
 }
 
-// DeleteHandler returns a handler function for DELETE /delete
-func DeleteHandler(cache *cache.LRUCache) echo.HandlerFunc {
-	return func(c echo.Context) error {
-		key := c.QueryParam("key")
-		if key == "" {
-			return c.String(http.StatusBadRequest, "key is required")
-		}
+// shutdown drains in-flight requests, then flushes and closes the WALs of
+// the cache and namespaces, writing snapshots first if configured
+func shutdown(e *echo.Echo, c *cache.LRUCache, namespaces *cache.Namespaces, cfg *config.Config) error {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
 
-		if err := cache.Delete(key); err != nil {
-			return c.String(http.StatusInternalServerError, err.Error())
+	var errs []error
+	if err := e.Shutdown(ctx); err != nil {
+		errs = append(errs, err)
+	}
+
+	if cfg.WAL.SnapshotOnShutdown && cfg.WAL.Directory != "" {
+		if err := c.Snapshot(); err != nil {
+			errs = append(errs, err)
+		}
+		if err := namespaces.Snapshot(); err != nil {
+			errs = append(errs, err)
 		}
+	}
+
+	// Close flushes the buffered writer and fsyncs the current segment
+	if err := c.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := namespaces.Close(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
 
-		return c.String(http.StatusOK, "OK")
+// loadKeyRing returns the WAL encryption keys given by the configuration,
+// or nil if encryption is disabled
+func loadKeyRing(cfg config.WALConfig) (*wal.KeyRing, error) {
+	switch {
+	case cfg.EncryptionKeyFile != "":
+		return wal.LoadKeyRing(cfg.EncryptionKeyFile)
+	case cfg.EncryptionKeys != "":
+		return wal.ParseKeyRing(cfg.EncryptionKeys)
 	}
+	return nil, nil
 }
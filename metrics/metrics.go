@@ -0,0 +1,200 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// Counter is a monotonically increasing value
+type Counter struct {
+	value atomic.Uint64
+}
+
+// Inc increments the counter by one
+func (c *Counter) Inc() {
+	c.value.Add(1)
+}
+
+// Add increments the counter by n
+func (c *Counter) Add(n uint64) {
+	c.value.Add(n)
+}
+
+// Value returns the current count
+func (c *Counter) Value() uint64 {
+	return c.value.Load()
+}
+
+// Histogram counts observations into cumulative buckets
+type Histogram struct {
+	mu      sync.Mutex
+	bounds  []float64
+	buckets []uint64
+	count   uint64
+	sum     float64
+}
+
+// NewHistogram returns a histogram with the given ascending upper bounds.
+// Observations above the last bound are only counted in the implicit +Inf bucket.
+func NewHistogram(bounds []float64) *Histogram {
+	return &Histogram{
+		bounds:  bounds,
+		buckets: make([]uint64, len(bounds)),
+	}
+}
+
+// ExponentialBuckets returns count bounds starting at start, each factor times the previous
+func ExponentialBuckets(start, factor float64, count int) []float64 {
+	bounds := make([]float64, count)
+	for i := range bounds {
+		bounds[i] = start
+		start *= factor
+	}
+	return bounds
+}
+
+// Observe records a single value
+func (h *Histogram) Observe(value float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, bound := range h.bounds {
+		if value <= bound {
+			h.buckets[i]++
+		}
+	}
+	h.count++
+	h.sum += value
+}
+
+// HistogramSnapshot is a point-in-time copy of a histogram
+type HistogramSnapshot struct {
+	Bounds []float64
+	// Buckets holds the cumulative count of observations <= the matching bound
+	Buckets []uint64
+	Count   uint64
+	Sum     float64
+}
+
+// Snapshot returns a consistent copy of the histogram
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return HistogramSnapshot{
+		Bounds:  h.bounds,
+		Buckets: append([]uint64(nil), h.buckets...),
+		Count:   h.count,
+		Sum:     h.sum,
+	}
+}
+
+// metricType is the Prometheus TYPE of a metric family
+type metricType string
+
+const (
+	typeCounter   metricType = "counter"
+	typeGauge     metricType = "gauge"
+	typeHistogram metricType = "histogram"
+)
+
+// family is a registered metric and how to read it
+type family struct {
+	name      string
+	help      string
+	typ       metricType
+	value     func() float64
+	histogram *Histogram
+}
+
+// Registry holds metrics and writes them in the Prometheus text exposition format
+type Registry struct {
+	mu       sync.Mutex
+	families []*family
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(f *family) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.families {
+		if existing.name == f.name {
+			panic(fmt.Sprintf("metrics: %s registered twice", f.name))
+		}
+	}
+	r.families = append(r.families, f)
+}
+
+// NewCounter registers and returns a counter. Counter names should end in _total.
+func (r *Registry) NewCounter(name, help string) *Counter {
+	c := &Counter{}
+	r.register(&family{name: name, help: help, typ: typeCounter, value: func() float64 { return float64(c.Value()) }})
+	return c
+}
+
+// NewGaugeFunc registers a gauge whose value is read from fn at scrape time
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(&family{name: name, help: help, typ: typeGauge, value: fn})
+}
+
+// NewHistogram registers and returns a histogram with the given bucket bounds
+func (r *Registry) NewHistogram(name, help string, bounds []float64) *Histogram {
+	h := NewHistogram(bounds)
+	r.register(&family{name: name, help: help, typ: typeHistogram, histogram: h})
+	return h
+}
+
+// WriteText writes every metric, sorted by name, in the Prometheus text format
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	families := append([]*family(nil), r.families...)
+	r.mu.Unlock()
+
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].name < families[j].name
+	})
+
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		fmt.Fprintf(bw, "# HELP %s %s\n", f.name, f.help)
+		fmt.Fprintf(bw, "# TYPE %s %s\n", f.name, f.typ)
+
+		if f.histogram == nil {
+			fmt.Fprintf(bw, "%s %s\n", f.name, formatFloat(f.value()))
+			continue
+		}
+
+		snapshot := f.histogram.Snapshot()
+		for i, bound := range snapshot.Bounds {
+			fmt.Fprintf(bw, "%s_bucket{le=\"%s\"} %d\n", f.name, formatFloat(bound), snapshot.Buckets[i])
+		}
+		fmt.Fprintf(bw, "%s_bucket{le=\"+Inf\"} %d\n", f.name, snapshot.Count)
+		fmt.Fprintf(bw, "%s_sum %s\n", f.name, formatFloat(snapshot.Sum))
+		fmt.Fprintf(bw, "%s_count %d\n", f.name, snapshot.Count)
+	}
+	return bw.Flush()
+}
+
+// formatFloat renders a sample value the way Prometheus expects
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
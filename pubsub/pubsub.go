@@ -0,0 +1,226 @@
+package pubsub
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sync"
+)
+
+// DefaultBufferSize is the number of undelivered messages a subscription may queue
+const DefaultBufferSize = 256
+
+var (
+	// ErrSlowConsumer is reported by a subscription that was disconnected
+	// because its buffer was full when a message was published
+	ErrSlowConsumer = errors.New("subscriber fell behind and was disconnected")
+
+	// ErrClosed is returned when adding channels to a closed subscription
+	ErrClosed = errors.New("subscription is closed")
+)
+
+// Message is a payload published to a channel. Pattern is set when the
+// message was delivered because of a pattern subscription.
+type Message struct {
+	Channel string
+	Pattern string
+	Payload string
+}
+
+// Broker fans out published messages to channel and pattern subscribers.
+// Messages are not stored: only current subscribers receive them.
+type Broker struct {
+	mu         sync.Mutex
+	bufferSize int
+	channels   map[string]map[*Subscription]struct{}
+	patterns   map[string]map[*Subscription]struct{}
+}
+
+// NewBroker returns a broker whose subscriptions buffer up to bufferSize messages.
+// A non-positive bufferSize uses DefaultBufferSize.
+func NewBroker(bufferSize int) *Broker {
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+	return &Broker{
+		bufferSize: bufferSize,
+		channels:   make(map[string]map[*Subscription]struct{}),
+		patterns:   make(map[string]map[*Subscription]struct{}),
+	}
+}
+
+// Subscription receives the messages of its channels and patterns on C
+// until it is closed
+type Subscription struct {
+	// C is closed when the subscription is closed or disconnected
+	C <-chan Message
+
+	messages chan Message
+	broker   *Broker
+	channels map[string]struct{}
+	patterns map[string]struct{}
+	closed   bool
+	err      error
+}
+
+// Subscribe returns a subscription to the given channels
+func (b *Broker) Subscribe(channels ...string) *Subscription {
+	sub := b.newSubscription()
+	// A new subscription cannot be closed yet
+	_ = sub.Subscribe(channels...)
+	return sub
+}
+
+// PSubscribe returns a subscription to every channel matching the glob patterns.
+// Patterns use path.Match syntax: *, ? and [...] classes, where * does not match '/'.
+func (b *Broker) PSubscribe(patterns ...string) (*Subscription, error) {
+	sub := b.newSubscription()
+	if err := sub.PSubscribe(patterns...); err != nil {
+		sub.Close()
+		return nil, err
+	}
+	return sub, nil
+}
+
+func (b *Broker) newSubscription() *Subscription {
+	messages := make(chan Message, b.bufferSize)
+	return &Subscription{
+		C:        messages,
+		messages: messages,
+		broker:   b,
+		channels: make(map[string]struct{}),
+		patterns: make(map[string]struct{}),
+	}
+}
+
+// Publish sends payload to every subscriber of channel and returns how many
+// subscriptions it was delivered to. Subscribers whose buffer is full are
+// disconnected with ErrSlowConsumer instead of blocking the publisher.
+func (b *Broker) Publish(channel, payload string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delivered := 0
+	for sub := range b.channels[channel] {
+		if b.deliver(sub, Message{Channel: channel, Payload: payload}) {
+			delivered++
+		}
+	}
+	for pattern, subs := range b.patterns {
+		if matched, _ := path.Match(pattern, channel); !matched {
+			continue
+		}
+		for sub := range subs {
+			if b.deliver(sub, Message{Channel: channel, Pattern: pattern, Payload: payload}) {
+				delivered++
+			}
+		}
+	}
+	return delivered
+}
+
+// NumSubscribers returns the number of subscriptions to channel, not counting patterns
+func (b *Broker) NumSubscribers(channel string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.channels[channel])
+}
+
+// deliver queues msg without blocking. The caller must hold b.mu.
+func (b *Broker) deliver(sub *Subscription, msg Message) bool {
+	if sub.closed {
+		return false
+	}
+	select {
+	case sub.messages <- msg:
+		return true
+	default:
+		b.close(sub, ErrSlowConsumer)
+		return false
+	}
+}
+
+// close removes sub from every index and closes its channel. The caller must hold b.mu.
+func (b *Broker) close(sub *Subscription, err error) {
+	if sub.closed {
+		return
+	}
+	for channel := range sub.channels {
+		removeFrom(b.channels, channel, sub)
+	}
+	for pattern := range sub.patterns {
+		removeFrom(b.patterns, pattern, sub)
+	}
+	sub.closed = true
+	sub.err = err
+	close(sub.messages)
+}
+
+func removeFrom(index map[string]map[*Subscription]struct{}, name string, sub *Subscription) {
+	delete(index[name], sub)
+	if len(index[name]) == 0 {
+		delete(index, name)
+	}
+}
+
+// Subscribe adds channels to the subscription
+func (s *Subscription) Subscribe(channels ...string) error {
+	b := s.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+	for _, channel := range channels {
+		s.channels[channel] = struct{}{}
+		if b.channels[channel] == nil {
+			b.channels[channel] = make(map[*Subscription]struct{})
+		}
+		b.channels[channel][s] = struct{}{}
+	}
+	return nil
+}
+
+// PSubscribe adds glob patterns to the subscription
+func (s *Subscription) PSubscribe(patterns ...string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+
+	b := s.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+	for _, pattern := range patterns {
+		s.patterns[pattern] = struct{}{}
+		if b.patterns[pattern] == nil {
+			b.patterns[pattern] = make(map[*Subscription]struct{})
+		}
+		b.patterns[pattern][s] = struct{}{}
+	}
+	return nil
+}
+
+// Close unsubscribes from everything and closes C
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+
+	s.broker.close(s, nil)
+}
+
+// Err reports why C was closed: ErrSlowConsumer if the subscriber fell
+// behind, nil otherwise. It is only meaningful once C is closed.
+func (s *Subscription) Err() error {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+
+	return s.err
+}
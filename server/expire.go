@@ -0,0 +1,87 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nishanth-gowda/kv-store/cache"
+)
+
+// ExpireHandler returns a handler function for POST /expire
+// The key expires ttl (a Go duration) from now; a ttl of 0 or less deletes it
+func ExpireHandler(store *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.QueryParam("key")
+		if key == "" || c.QueryParam("ttl") == "" {
+			return c.String(http.StatusBadRequest, "key and ttl are required")
+		}
+		ttl, err := time.ParseDuration(c.QueryParam("ttl"))
+		if err != nil {
+			return c.String(http.StatusBadRequest, "Invalid TTL format")
+		}
+
+		ok, err := store.Expire(key, ttl)
+		return expiryResponse(c, ok, err)
+	}
+}
+
+// ExpireAtHandler returns a handler function for POST /expireat
+// The key expires at the RFC 3339 time at; a time in the past deletes it
+func ExpireAtHandler(store *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.QueryParam("key")
+		if key == "" || c.QueryParam("at") == "" {
+			return c.String(http.StatusBadRequest, "key and at are required")
+		}
+		at, err := time.Parse(time.RFC3339Nano, c.QueryParam("at"))
+		if err != nil {
+			return c.String(http.StatusBadRequest, "at must be an RFC 3339 time")
+		}
+
+		ok, err := store.ExpireAt(key, at)
+		return expiryResponse(c, ok, err)
+	}
+}
+
+// PersistHandler returns a handler function for POST /persist
+// The response reports whether an expiration was removed
+func PersistHandler(store *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.QueryParam("key")
+		if key == "" {
+			return c.String(http.StatusBadRequest, "key is required")
+		}
+
+		persisted, err := store.Persist(key)
+		if err != nil {
+			return dataTypeError(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]bool{"persisted": persisted})
+	}
+}
+
+// TouchHandler returns a handler function for POST /touch
+// It marks the key as recently used and restarts its TTL
+func TouchHandler(store *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.QueryParam("key")
+		if key == "" {
+			return c.String(http.StatusBadRequest, "key is required")
+		}
+
+		ok, err := store.Touch(key)
+		return expiryResponse(c, ok, err)
+	}
+}
+
+// expiryResponse responds to an expiration update that found the key if ok
+func expiryResponse(c echo.Context, ok bool, err error) error {
+	if err != nil {
+		return dataTypeError(c, err)
+	}
+	if !ok {
+		return c.String(http.StatusNotFound, "Key not found")
+	}
+	return c.String(http.StatusOK, "OK")
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nishanth-gowda/kv-store/cache"
+)
+
+// HashSetHandler returns a handler function for POST /hset
+// The request body is a JSON object of field/value pairs; the response
+// reports how many fields were added rather than updated.
+func HashSetHandler(store *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.QueryParam("key")
+		if key == "" {
+			return c.String(http.StatusBadRequest, "key is required")
+		}
+
+		var fields map[string]string
+		if err := json.NewDecoder(c.Request().Body).Decode(&fields); err != nil {
+			return c.String(http.StatusBadRequest, "body must be a JSON object of field/value pairs")
+		}
+		if len(fields) == 0 {
+			return c.String(http.StatusBadRequest, "at least one field is required")
+		}
+
+		added, err := store.HSet(key, fields)
+		if err != nil {
+			return dataTypeError(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]int{"added": added})
+	}
+}
+
+// HashGetHandler returns a handler function for GET /hget
+func HashGetHandler(store *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.QueryParam("key")
+		field := c.QueryParam("field")
+		if key == "" || field == "" {
+			return c.String(http.StatusBadRequest, "key and field are required")
+		}
+
+		value, ok, err := store.HGet(key, field)
+		if err != nil {
+			return dataTypeError(c, err)
+		}
+		if !ok {
+			return c.String(http.StatusNotFound, "Field not found")
+		}
+		return c.String(http.StatusOK, value)
+	}
+}
+
+// HashGetAllHandler returns a handler function for GET /hgetall
+// A missing key returns an empty JSON object
+func HashGetAllHandler(store *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.QueryParam("key")
+		if key == "" {
+			return c.String(http.StatusBadRequest, "key is required")
+		}
+
+		hash, err := store.HGetAll(key)
+		if err != nil {
+			return dataTypeError(c, err)
+		}
+		return c.JSON(http.StatusOK, hash)
+	}
+}
+
+// HashDeleteHandler returns a handler function for DELETE /hdel
+// The field parameter may be repeated; the response reports how many existed
+func HashDeleteHandler(store *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.QueryParam("key")
+		fields := c.QueryParams()["field"]
+		if key == "" || len(fields) == 0 {
+			return c.String(http.StatusBadRequest, "key and at least one field are required")
+		}
+
+		removed, err := store.HDel(key, fields...)
+		if err != nil {
+			return dataTypeError(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]int{"removed": removed})
+	}
+}
+
+// HashIncrByHandler returns a handler function for POST /hincrby
+// by defaults to 1; the response is the new value of the field
+func HashIncrByHandler(store *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.QueryParam("key")
+		field := c.QueryParam("field")
+		if key == "" || field == "" {
+			return c.String(http.StatusBadRequest, "key and field are required")
+		}
+
+		delta := int64(1)
+		if by := c.QueryParam("by"); by != "" {
+			var err error
+			if delta, err = strconv.ParseInt(by, 10, 64); err != nil {
+				return c.String(http.StatusBadRequest, "by must be an integer")
+			}
+		}
+
+		value, err := store.HIncrBy(key, field, delta)
+		if err != nil {
+			return dataTypeError(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]int64{"value": value})
+	}
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nishanth-gowda/kv-store/cache"
+)
+
+// BlockingPopResponse is the JSON body returned by POST /blpop
+type BlockingPopResponse struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// ListPushHandler returns a handler function for POST /lpush and POST /rpush
+// using push, which is LRUCache.LPush or LRUCache.RPush. The value parameter
+// may be repeated; the response is the new length of the list.
+func ListPushHandler(push func(key string, values ...string) (int, error)) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.QueryParam("key")
+		values := c.QueryParams()["value"]
+		if key == "" || len(values) == 0 {
+			return c.String(http.StatusBadRequest, "key and at least one value are required")
+		}
+
+		length, err := push(key, values...)
+		if err != nil {
+			return dataTypeError(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]int{"length": length})
+	}
+}
+
+// ListPopHandler returns a handler function for POST /lpop and POST /rpop
+// using pop, which is LRUCache.LPop or LRUCache.RPop
+func ListPopHandler(pop func(key string) (string, bool, error)) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.QueryParam("key")
+		if key == "" {
+			return c.String(http.StatusBadRequest, "key is required")
+		}
+
+		value, ok, err := pop(key)
+		if err != nil {
+			return dataTypeError(c, err)
+		}
+		if !ok {
+			return c.String(http.StatusNotFound, "List is empty")
+		}
+		return c.String(http.StatusOK, value)
+	}
+}
+
+// BlockingPopHandler returns a handler function for POST /blpop
+// It pops from the first non-empty list among the repeated key parameters,
+// waiting up to timeout (e.g. 5s; 0 or omitted waits until the client goes
+// away) and responding 404 if nothing arrives. Waiting stops when done is closed.
+func BlockingPopHandler(store *cache.LRUCache, done <-chan struct{}) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		keys := c.QueryParams()["key"]
+		if len(keys) == 0 {
+			return c.String(http.StatusBadRequest, "at least one key is required")
+		}
+
+		var timeout time.Duration
+		if param := c.QueryParam("timeout"); param != "" {
+			var err error
+			if timeout, err = time.ParseDuration(param); err != nil || timeout < 0 {
+				return c.String(http.StatusBadRequest, "Invalid timeout format")
+			}
+		}
+
+		ctx, cancel := requestContext(c, done)
+		defer cancel()
+
+		key, value, ok, err := store.BLPop(ctx, timeout, keys...)
+		switch {
+		case ok:
+			return c.JSON(http.StatusOK, BlockingPopResponse{Key: key, Value: value})
+		case ctx.Err() != nil:
+			return c.String(http.StatusServiceUnavailable, "Server is shutting down")
+		case err != nil:
+			return dataTypeError(c, err)
+		}
+		return c.String(http.StatusNotFound, "Timed out waiting for an element")
+	}
+}
+
+// ListRangeHandler returns a handler function for GET /lrange
+// start and stop default to 0 and -1, returning the whole list as a JSON array
+func ListRangeHandler(store *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.QueryParam("key")
+		if key == "" {
+			return c.String(http.StatusBadRequest, "key is required")
+		}
+
+		start, stop, err := parseRange(c)
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+
+		values, err := store.LRange(key, start, stop)
+		if err != nil {
+			return dataTypeError(c, err)
+		}
+		return c.JSON(http.StatusOK, values)
+	}
+}
+
+// requestContext returns a context for a blocking request that is canceled
+// when the client goes away or done is closed
+func requestContext(c echo.Context, done <-chan struct{}) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(c.Request().Context())
+	go func() {
+		select {
+		case <-done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// parseRange parses the optional start and stop index query parameters,
+// which default to 0 and -1 (everything)
+func parseRange(c echo.Context) (int, int, error) {
+	start, stop := 0, -1
+	var err error
+	if param := c.QueryParam("start"); param != "" {
+		if start, err = strconv.Atoi(param); err != nil {
+			return 0, 0, errors.New("start must be an integer")
+		}
+	}
+	if param := c.QueryParam("stop"); param != "" {
+		if stop, err = strconv.Atoi(param); err != nil {
+			return 0, 0, errors.New("stop must be an integer")
+		}
+	}
+	return start, stop, nil
+}
+
+// ListLengthHandler returns a handler function for GET /llen
+func ListLengthHandler(store *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.QueryParam("key")
+		if key == "" {
+			return c.String(http.StatusBadRequest, "key is required")
+		}
+
+		length, err := store.LLen(key)
+		if err != nil {
+			return dataTypeError(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]int{"length": length})
+	}
+}
@@ -0,0 +1,198 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nishanth-gowda/kv-store/cache"
+)
+
+// NamespaceResponse describes a namespace in the JSON returned by GET /ns
+// and GET /ns/{name}
+type NamespaceResponse struct {
+	Name           string `json:"name"`
+	Capacity       int    `json:"capacity"`
+	EvictionPolicy string `json:"eviction_policy"`
+	DefaultTTL     string `json:"default_ttl"`
+	Keys           int    `json:"keys"`
+}
+
+// namespaceRouters serves requests for a namespace with routes bound to its
+// cache, built on first use
+type namespaceRouters struct {
+	mu      sync.Mutex
+	done    <-chan struct{}
+	routers map[*cache.LRUCache]*echo.Echo
+}
+
+func (r *namespaceRouters) get(c *cache.LRUCache) *echo.Echo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	router, ok := r.routers[c]
+	if !ok {
+		router = echo.New()
+		registerRoutes(router, c, r.done)
+		r.routers[c] = router
+	}
+	return router
+}
+
+func (r *namespaceRouters) forget(c *cache.LRUCache) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.routers, c)
+}
+
+func registerNamespaceRoutes(e *echo.Echo, ns *cache.Namespaces, done <-chan struct{}) {
+	routers := &namespaceRouters{done: done, routers: make(map[*cache.LRUCache]*echo.Echo)}
+
+	e.GET("/ns", NamespaceListHandler(ns))
+	e.GET("/ns/:ns", NamespaceGetHandler(ns))
+	e.PUT("/ns/:ns", NamespacePutHandler(ns))
+	e.DELETE("/ns/:ns", NamespaceDeleteHandler(ns, routers.forget))
+	e.Any("/ns/:ns/*", func(c echo.Context) error {
+		store, ok := ns.Get(c.Param("ns"))
+		if !ok {
+			return c.String(http.StatusNotFound, cache.ErrNoNamespace.Error())
+		}
+
+		// Serve the rest of the path as if it were sent to the default cache
+		req := new(http.Request)
+		*req = *c.Request()
+		url := *req.URL
+		url.Path = "/" + c.Param("*")
+		url.RawPath = ""
+		req.URL = &url
+		routers.get(store).ServeHTTP(c.Response(), req)
+		return nil
+	})
+}
+
+// namespaceResponse describes the namespace name
+func namespaceResponse(ns *cache.Namespaces, name string) (NamespaceResponse, bool) {
+	cfg, ok := ns.Config(name)
+	store, found := ns.Get(name)
+	if !ok || !found {
+		return NamespaceResponse{}, false
+	}
+	return NamespaceResponse{
+		Name:           name,
+		Capacity:       cfg.Capacity,
+		EvictionPolicy: string(cfg.EvictionPolicy),
+		DefaultTTL:     cfg.DefaultTTL.String(),
+		Keys:           store.Len(),
+	}, true
+}
+
+// NamespaceListHandler returns a handler function for GET /ns
+func NamespaceListHandler(ns *cache.Namespaces) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		resp := []NamespaceResponse{}
+		for _, name := range ns.Names() {
+			if info, ok := namespaceResponse(ns, name); ok {
+				resp = append(resp, info)
+			}
+		}
+		return c.JSON(http.StatusOK, resp)
+	}
+}
+
+// NamespaceGetHandler returns a handler function for GET /ns/{name}
+func NamespaceGetHandler(ns *cache.Namespaces) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		info, ok := namespaceResponse(ns, c.Param("ns"))
+		if !ok {
+			return c.String(http.StatusNotFound, cache.ErrNoNamespace.Error())
+		}
+		return c.JSON(http.StatusOK, info)
+	}
+}
+
+// NamespacePutHandler returns a handler function for PUT /ns/{name}
+// It creates the namespace, responding 201 Created, or updates the settings
+// given as capacity, eviction_policy and default_ttl query parameters.
+// capacity is required on creation; the policy defaults to lru and the
+// default TTL to 0 (never expire).
+func NamespacePutHandler(ns *cache.Namespaces) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		name := c.Param("ns")
+		cfg, exists := ns.Config(name)
+		if !exists {
+			cfg = cache.NamespaceConfig{EvictionPolicy: cache.EvictLRU}
+		}
+
+		if param := c.QueryParam("capacity"); param != "" {
+			capacity, err := strconv.Atoi(param)
+			if err != nil || capacity <= 0 {
+				return c.String(http.StatusBadRequest, "capacity must be a positive integer")
+			}
+			cfg.Capacity = capacity
+		} else if !exists {
+			return c.String(http.StatusBadRequest, "capacity is required")
+		}
+		if param := c.QueryParam("eviction_policy"); param != "" {
+			policy, err := cache.ParseEvictionPolicy(param)
+			if err != nil {
+				return c.String(http.StatusBadRequest, err.Error())
+			}
+			cfg.EvictionPolicy = policy
+		}
+		if param := c.QueryParam("default_ttl"); param != "" {
+			ttl, err := time.ParseDuration(param)
+			if err != nil || ttl < 0 {
+				return c.String(http.StatusBadRequest, "Invalid default_ttl format")
+			}
+			cfg.DefaultTTL = ttl
+		}
+
+		status := http.StatusOK
+		var err error
+		if exists {
+			err = ns.Update(name, cfg)
+		} else {
+			_, err = ns.Create(name, cfg)
+			status = http.StatusCreated
+		}
+		switch {
+		case errors.Is(err, cache.ErrInvalidNamespace):
+			return c.String(http.StatusBadRequest, err.Error())
+		case errors.Is(err, cache.ErrNamespaceExists):
+			return c.String(http.StatusConflict, err.Error())
+		case errors.Is(err, cache.ErrNoNamespace):
+			return c.String(http.StatusNotFound, err.Error())
+		case err != nil:
+			return c.String(http.StatusInternalServerError, err.Error())
+		}
+
+		info, _ := namespaceResponse(ns, name)
+		return c.JSON(status, info)
+	}
+}
+
+// NamespaceDeleteHandler returns a handler function for DELETE /ns/{name}
+// It removes the namespace and its keys, then calls forget with its cache
+func NamespaceDeleteHandler(ns *cache.Namespaces, forget func(*cache.LRUCache)) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		name := c.Param("ns")
+		store, ok := ns.Get(name)
+		if !ok {
+			return c.String(http.StatusNotFound, cache.ErrNoNamespace.Error())
+		}
+
+		err := ns.Delete(name)
+		if errors.Is(err, cache.ErrNoNamespace) {
+			return c.String(http.StatusNotFound, err.Error())
+		}
+		forget(store)
+		if err != nil {
+			return c.String(http.StatusInternalServerError, err.Error())
+		}
+		return c.String(http.StatusOK, "OK")
+	}
+}
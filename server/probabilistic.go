@@ -0,0 +1,124 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nishanth-gowda/kv-store/cache"
+)
+
+// PFAddHandler returns a handler function for POST /pfadd
+// The element parameter may be repeated; the response reports whether the
+// estimate may have changed
+func PFAddHandler(store *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.QueryParam("key")
+		if key == "" {
+			return c.String(http.StatusBadRequest, "key is required")
+		}
+
+		changed, err := store.PFAdd(key, c.QueryParams()["element"]...)
+		if err != nil {
+			return dataTypeError(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]bool{"changed": changed})
+	}
+}
+
+// PFCountHandler returns a handler function for GET /pfcount
+// With a repeated key parameter it estimates the size of the union
+func PFCountHandler(store *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		keys := c.QueryParams()["key"]
+		if len(keys) == 0 {
+			return c.String(http.StatusBadRequest, "at least one key is required")
+		}
+
+		count, err := store.PFCount(keys...)
+		if err != nil {
+			return dataTypeError(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]uint64{"count": count})
+	}
+}
+
+// PFMergeHandler returns a handler function for POST /pfmerge
+// It merges every repeated key parameter into dest
+func PFMergeHandler(store *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		dest := c.QueryParam("dest")
+		if dest == "" {
+			return c.String(http.StatusBadRequest, "dest is required")
+		}
+
+		if err := store.PFMerge(dest, c.QueryParams()["key"]...); err != nil {
+			return dataTypeError(c, err)
+		}
+		return c.String(http.StatusOK, "OK")
+	}
+}
+
+// BloomReserveHandler returns a handler function for POST /bfreserve
+// error_rate and capacity default to the values used by /bfadd on a missing key
+func BloomReserveHandler(store *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.QueryParam("key")
+		if key == "" {
+			return c.String(http.StatusBadRequest, "key is required")
+		}
+
+		errorRate, capacity := cache.DefaultBloomErrorRate, cache.DefaultBloomCapacity
+		var err error
+		if param := c.QueryParam("error_rate"); param != "" {
+			if errorRate, err = strconv.ParseFloat(param, 64); err != nil {
+				return c.String(http.StatusBadRequest, "error_rate must be a number")
+			}
+		}
+		if param := c.QueryParam("capacity"); param != "" {
+			if capacity, err = strconv.Atoi(param); err != nil {
+				return c.String(http.StatusBadRequest, "capacity must be an integer")
+			}
+		}
+
+		if err := store.BFReserve(key, errorRate, capacity); err != nil {
+			return dataTypeError(c, err)
+		}
+		return c.String(http.StatusOK, "OK")
+	}
+}
+
+// BloomAddHandler returns a handler function for POST /bfadd
+// The response is false if the element may already have been added
+func BloomAddHandler(store *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.QueryParam("key")
+		element := c.QueryParam("element")
+		if key == "" || element == "" {
+			return c.String(http.StatusBadRequest, "key and element are required")
+		}
+
+		added, err := store.BFAdd(key, element)
+		if err != nil {
+			return dataTypeError(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]bool{"added": added})
+	}
+}
+
+// BloomExistsHandler returns a handler function for GET /bfexists
+func BloomExistsHandler(store *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.QueryParam("key")
+		element := c.QueryParam("element")
+		if key == "" || element == "" {
+			return c.String(http.StatusBadRequest, "key and element are required")
+		}
+
+		exists, err := store.BFExists(key, element)
+		if err != nil {
+			return dataTypeError(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]bool{"exists": exists})
+	}
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nishanth-gowda/kv-store/pubsub"
+)
+
+// PubSubMessage is the JSON data of a Server-Sent Event sent by GET /subscribe
+type PubSubMessage struct {
+	Channel string `json:"channel"`
+	Pattern string `json:"pattern,omitempty"`
+	Message string `json:"message"`
+}
+
+// PublishResponse is the JSON body returned by POST /publish
+type PublishResponse struct {
+	Receivers int `json:"receivers"`
+}
+
+// PublishHandler returns a handler function for POST /publish
+// It responds with the number of subscriptions the message was delivered to.
+func PublishHandler(broker *pubsub.Broker) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		channel := c.QueryParam("channel")
+		if channel == "" {
+			return c.String(http.StatusBadRequest, "channel is required")
+		}
+
+		receivers := broker.Publish(channel, c.QueryParam("message"))
+		return c.JSON(http.StatusOK, PublishResponse{Receivers: receivers})
+	}
+}
+
+// SubscribeHandler returns a handler function for GET /subscribe
+// It streams the messages published to every ?channel= and to channels matching
+// every ?pattern= as Server-Sent Events. A subscriber that falls behind receives
+// an error event and is disconnected. The stream ends when done is closed.
+func SubscribeHandler(broker *pubsub.Broker, done <-chan struct{}) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		channels := c.QueryParams()["channel"]
+		patterns := c.QueryParams()["pattern"]
+		if len(channels) == 0 && len(patterns) == 0 {
+			return c.String(http.StatusBadRequest, "at least one channel or pattern is required")
+		}
+
+		sub := broker.Subscribe(channels...)
+		defer sub.Close()
+		if err := sub.PSubscribe(patterns...); err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+
+		resp := c.Response()
+		resp.Header().Set(echo.HeaderContentType, "text/event-stream")
+		resp.Header().Set(echo.HeaderCacheControl, "no-cache")
+		resp.Header().Set(echo.HeaderConnection, "keep-alive")
+		resp.WriteHeader(http.StatusOK)
+		resp.Flush()
+
+		ctx := c.Request().Context()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-done:
+				return nil
+			case msg, ok := <-sub.C:
+				if !ok {
+					if err := sub.Err(); err != nil {
+						fmt.Fprintf(resp, "event: error\ndata: %s\n\n", err)
+						resp.Flush()
+					}
+					return nil
+				}
+
+				data, err := json.Marshal(PubSubMessage{Channel: msg.Channel, Pattern: msg.Pattern, Message: msg.Payload})
+				if err != nil {
+					return err
+				}
+				if _, err := fmt.Fprintf(resp, "event: message\ndata: %s\n\n", data); err != nil {
+					return nil
+				}
+				resp.Flush()
+			}
+		}
+	}
+}
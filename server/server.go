@@ -0,0 +1,430 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nishanth-gowda/kv-store/cache"
+	"github.com/nishanth-gowda/kv-store/pubsub"
+)
+
+// Option configures the server returned by New
+type Option func(*options)
+
+type options struct {
+	namespaces *cache.Namespaces
+}
+
+// WithNamespaces serves the namespaces in ns under /ns/{name}/, each with the
+// same routes as the default cache
+func WithNamespaces(ns *cache.Namespaces) Option {
+	return func(o *options) {
+		o.namespaces = ns
+	}
+}
+
+// New returns an Echo instance with all kv-store routes registered
+func New(c *cache.LRUCache, opts ...Option) *echo.Echo {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	e := echo.New()
+	e.HideBanner = true
+
+	// Streaming handlers stop when the server shuts down so draining does not wait on them
+	done := make(chan struct{})
+	e.Server.RegisterOnShutdown(func() { close(done) })
+
+	registerRoutes(e, c, done)
+
+	broker := pubsub.NewBroker(pubsub.DefaultBufferSize)
+	e.POST("/publish", PublishHandler(broker))
+	e.GET("/subscribe", SubscribeHandler(broker, done))
+
+	if o.namespaces != nil {
+		registerNamespaceRoutes(e, o.namespaces, done)
+	}
+
+	return e
+}
+
+// registerRoutes registers the routes operating on one cache, which are
+// served for the default cache and for every namespace
+func registerRoutes(e *echo.Echo, c *cache.LRUCache, done <-chan struct{}) {
+	e.POST("/set", SetHandler(c))
+	e.GET("/get", GetHandler(c))
+	e.DELETE("/delete", DeleteHandler(c))
+	e.POST("/cas", CompareAndSwapHandler(c))
+	e.GET("/mget", MultiGetHandler(c))
+	e.POST("/mset", MultiSetHandler(c))
+	e.GET("/ttl", TTLHandler(c))
+	e.POST("/expire", ExpireHandler(c))
+	e.POST("/expireat", ExpireAtHandler(c))
+	e.POST("/persist", PersistHandler(c))
+	e.POST("/touch", TouchHandler(c))
+	e.GET("/keys", KeysHandler(c))
+	e.GET("/stats", StatsHandler(c))
+	e.POST("/stats/reset", ResetStatsHandler(c))
+	e.GET("/metrics", MetricsHandler(c))
+	e.GET("/watch", WatchHandler(c, done))
+
+	e.POST("/hset", HashSetHandler(c))
+	e.GET("/hget", HashGetHandler(c))
+	e.GET("/hgetall", HashGetAllHandler(c))
+	e.DELETE("/hdel", HashDeleteHandler(c))
+	e.POST("/hincrby", HashIncrByHandler(c))
+
+	e.POST("/lpush", ListPushHandler(c.LPush))
+	e.POST("/rpush", ListPushHandler(c.RPush))
+	e.POST("/lpop", ListPopHandler(c.LPop))
+	e.POST("/rpop", ListPopHandler(c.RPop))
+	e.POST("/blpop", BlockingPopHandler(c, done))
+	e.GET("/lrange", ListRangeHandler(c))
+	e.GET("/llen", ListLengthHandler(c))
+
+	e.POST("/sadd", SetAddHandler(c))
+	e.DELETE("/srem", SetRemoveHandler(c))
+	e.GET("/sismember", SetIsMemberHandler(c))
+	e.GET("/smembers", SetMembersHandler(c.SUnion))
+	e.GET("/sinter", SetMembersHandler(c.SInter))
+	e.GET("/sunion", SetMembersHandler(c.SUnion))
+
+	e.POST("/zadd", SortedSetAddHandler(c))
+	e.POST("/zincrby", SortedSetIncrByHandler(c))
+	e.GET("/zrange", SortedSetRangeHandler(c))
+	e.GET("/zrangebyscore", SortedSetRangeByScoreHandler(c))
+	e.GET("/zrank", SortedSetRankHandler(c))
+
+	e.POST("/pfadd", PFAddHandler(c))
+	e.GET("/pfcount", PFCountHandler(c))
+	e.POST("/pfmerge", PFMergeHandler(c))
+	e.POST("/bfreserve", BloomReserveHandler(c))
+	e.POST("/bfadd", BloomAddHandler(c))
+	e.GET("/bfexists", BloomExistsHandler(c))
+
+	e.POST("/xadd", StreamAddHandler(c))
+	e.GET("/xlen", StreamLengthHandler(c))
+	e.GET("/xrange", StreamRangeHandler(c))
+	e.GET("/xread", StreamReadHandler(c, done))
+	e.POST("/xgroup", StreamGroupCreateHandler(c))
+	e.POST("/xreadgroup", StreamReadGroupHandler(c, done))
+	e.POST("/xack", StreamAckHandler(c))
+	e.GET("/xpending", StreamPendingHandler(c))
+	e.POST("/xclaim", StreamClaimHandler(c))
+}
+
+// parseTTL parses the optional ttl query parameter
+func parseTTL(c echo.Context) (time.Duration, error) {
+	ttl := c.QueryParam("ttl")
+	if ttl == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(ttl)
+}
+
+// parseSetOptions reads the options of /set from the query, currently
+// sliding=true for a sliding expiration
+func parseSetOptions(c echo.Context) ([]cache.SetOption, error) {
+	param := c.QueryParam("sliding")
+	if param == "" {
+		return nil, nil
+	}
+	sliding, err := strconv.ParseBool(param)
+	if err != nil || !sliding {
+		return nil, err
+	}
+	return []cache.SetOption{cache.WithSlidingExpiration()}, nil
+}
+
+// dataTypeError responds to an error from a write or data type operation:
+// 400 Bad Request when the key holds another type or the operation is
+// invalid for its value, 409 Conflict when the key must not exist, 507
+// Insufficient Storage when a noeviction cache is full, 500 otherwise
+func dataTypeError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, cache.ErrWrongType),
+		errors.Is(err, cache.ErrNotInteger),
+		errors.Is(err, cache.ErrInvalidScore),
+		errors.Is(err, cache.ErrInvalidBloomFilter),
+		errors.Is(err, cache.ErrStreamIDTooSmall):
+		return c.String(http.StatusBadRequest, err.Error())
+	case errors.Is(err, cache.ErrNoGroup):
+		return c.String(http.StatusNotFound, err.Error())
+	case errors.Is(err, cache.ErrExists), errors.Is(err, cache.ErrGroupExists):
+		return c.String(http.StatusConflict, err.Error())
+	case errors.Is(err, cache.ErrFull):
+		return c.String(http.StatusInsufficientStorage, err.Error())
+	}
+	return c.String(http.StatusInternalServerError, err.Error())
+}
+
+// SetHandler returns a handler function for POST /set
+func SetHandler(cache *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.QueryParam("key")
+		value := c.QueryParam("value")
+
+		if key == "" || value == "" {
+			return c.String(http.StatusBadRequest, "key and value are required")
+		}
+
+		ttlDuration, err := parseTTL(c)
+		if err != nil {
+			return c.String(http.StatusBadRequest, "Invalid TTL format")
+		}
+
+		opts, err := parseSetOptions(c)
+		if err != nil {
+			return c.String(http.StatusBadRequest, "sliding must be true or false")
+		}
+
+		if err := cache.Set(key, value, ttlDuration, opts...); err != nil {
+			return dataTypeError(c, err)
+		}
+
+		return c.String(http.StatusOK, "OK")
+	}
+}
+
+// GetHandler returns a handler function for GET /get
+func GetHandler(cache *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.QueryParam("key")
+		if key == "" {
+			return c.String(http.StatusBadRequest, "key is required")
+		}
+
+		value, ok := cache.Get(key)
+		if !ok {
+			return c.String(http.StatusNotFound, "Key not found")
+		}
+
+		str, ok := value.(string)
+		if !ok {
+			return c.String(http.StatusBadRequest, "key does not hold a string value")
+		}
+		return c.String(http.StatusOK, str)
+	}
+}
+
+// DeleteHandler returns a handler function for DELETE /delete
+func DeleteHandler(cache *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.QueryParam("key")
+		if key == "" {
+			return c.String(http.StatusBadRequest, "key is required")
+		}
+
+		if err := cache.Delete(key); err != nil {
+			return c.String(http.StatusInternalServerError, err.Error())
+		}
+
+		return c.String(http.StatusOK, "OK")
+	}
+}
+
+// CompareAndSwapHandler returns a handler function for POST /cas
+// It responds with 409 Conflict when the current value does not match old
+func CompareAndSwapHandler(cache *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.QueryParam("key")
+		oldValue := c.QueryParam("old")
+		value := c.QueryParam("value")
+
+		if key == "" || value == "" {
+			return c.String(http.StatusBadRequest, "key and value are required")
+		}
+
+		ttlDuration, err := parseTTL(c)
+		if err != nil {
+			return c.String(http.StatusBadRequest, "Invalid TTL format")
+		}
+
+		swapped, err := cache.CompareAndSwap(key, oldValue, value, ttlDuration)
+		if err != nil {
+			return dataTypeError(c, err)
+		}
+		if !swapped {
+			return c.String(http.StatusConflict, "Value mismatch")
+		}
+
+		return c.String(http.StatusOK, "OK")
+	}
+}
+
+// MultiGetHandler returns a handler function for GET /mget
+// Missing keys are omitted from the JSON response
+func MultiGetHandler(cache *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		keys := c.QueryParams()["key"]
+		if len(keys) == 0 {
+			return c.String(http.StatusBadRequest, "at least one key is required")
+		}
+
+		values := make(map[string]string, len(keys))
+		for _, key := range keys {
+			// Keys holding other data types are omitted like missing keys
+			if value, ok := cache.Get(key); ok {
+				if str, ok := value.(string); ok {
+					values[key] = str
+				}
+			}
+		}
+
+		return c.JSON(http.StatusOK, values)
+	}
+}
+
+// MultiSetHandler returns a handler function for POST /mset
+// The request body is a JSON object of key/value pairs sharing one optional ttl
+func MultiSetHandler(cache *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var values map[string]string
+		if err := json.NewDecoder(c.Request().Body).Decode(&values); err != nil {
+			return c.String(http.StatusBadRequest, "body must be a JSON object of key/value pairs")
+		}
+		if len(values) == 0 {
+			return c.String(http.StatusBadRequest, "at least one key is required")
+		}
+
+		ttlDuration, err := parseTTL(c)
+		if err != nil {
+			return c.String(http.StatusBadRequest, "Invalid TTL format")
+		}
+
+		for key, value := range values {
+			if key == "" || value == "" {
+				return c.String(http.StatusBadRequest, "key and value are required")
+			}
+		}
+
+		for key, value := range values {
+			if err := cache.Set(key, value, ttlDuration); err != nil {
+				return dataTypeError(c, err)
+			}
+		}
+
+		return c.String(http.StatusOK, "OK")
+	}
+}
+
+// MetricsHandler returns a handler function for GET /metrics
+// It serves the cache and WAL metrics in the Prometheus text exposition format
+func MetricsHandler(cache *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var buf bytes.Buffer
+		if err := cache.WriteMetrics(&buf); err != nil {
+			return c.String(http.StatusInternalServerError, err.Error())
+		}
+		return c.Blob(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", buf.Bytes())
+	}
+}
+
+// TTLResponse is the JSON body returned by GET /ttl
+type TTLResponse struct {
+	Key string `json:"key"`
+	// TTLMillis is the remaining time to live in milliseconds, or -1 if the key never expires
+	TTLMillis int64 `json:"ttl_ms"`
+}
+
+// TTLHandler returns a handler function for GET /ttl
+func TTLHandler(cache *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.QueryParam("key")
+		if key == "" {
+			return c.String(http.StatusBadRequest, "key is required")
+		}
+
+		ttl, ok := cache.TTL(key)
+		if !ok {
+			return c.String(http.StatusNotFound, "Key not found")
+		}
+
+		resp := TTLResponse{Key: key, TTLMillis: -1}
+		if ttl > 0 {
+			resp.TTLMillis = ttl.Milliseconds()
+		}
+		return c.JSON(http.StatusOK, resp)
+	}
+}
+
+// KeysHandler returns a handler function for GET /keys
+// The optional prefix query parameter filters the keys, which are returned sorted
+func KeysHandler(cache *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		keys := cache.Keys(c.QueryParam("prefix"))
+		sort.Strings(keys)
+		return c.JSON(http.StatusOK, keys)
+	}
+}
+
+// StatsResponse is the JSON body returned by GET /stats
+type StatsResponse struct {
+	Hits             uint64            `json:"hits"`
+	Misses           uint64            `json:"misses"`
+	HitRatio         float64           `json:"hit_ratio"`
+	Evictions        uint64            `json:"evictions"`
+	Expirations      uint64            `json:"expirations"`
+	Size             int               `json:"size"`
+	Capacity         int               `json:"capacity"`
+	Bytes            int64             `json:"bytes"`
+	OldestEntryAgeMs int64             `json:"oldest_entry_age_ms"`
+	WAL              *WALStatsResponse `json:"wal,omitempty"`
+}
+
+// WALStatsResponse is the WAL section of StatsResponse
+type WALStatsResponse struct {
+	Directory          string `json:"directory"`
+	CurrentSegment     string `json:"current_segment"`
+	LastSequenceNumber uint64 `json:"last_sequence_number"`
+	Segments           int    `json:"segments"`
+	DiskBytes          int64  `json:"disk_bytes"`
+}
+
+// StatsHandler returns a handler function for GET /stats
+func StatsHandler(cache *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		stats, err := cache.Stats()
+		if err != nil {
+			return c.String(http.StatusInternalServerError, err.Error())
+		}
+
+		resp := StatsResponse{
+			Hits:             stats.Hits,
+			Misses:           stats.Misses,
+			HitRatio:         stats.HitRatio(),
+			Evictions:        stats.Evictions,
+			Expirations:      stats.Expirations,
+			Size:             stats.Size,
+			Capacity:         stats.Capacity,
+			Bytes:            stats.Bytes,
+			OldestEntryAgeMs: stats.OldestEntryAge.Milliseconds(),
+		}
+		if stats.WAL != nil {
+			resp.WAL = &WALStatsResponse{
+				Directory:          stats.WAL.Directory,
+				CurrentSegment:     stats.WAL.CurrentSegment,
+				LastSequenceNumber: stats.WAL.LastSequenceNumber,
+				Segments:           stats.WAL.Segments,
+				DiskBytes:          stats.WAL.DiskBytes,
+			}
+		}
+		return c.JSON(http.StatusOK, resp)
+	}
+}
+
+// ResetStatsHandler returns a handler function for POST /stats/reset
+func ResetStatsHandler(cache *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		cache.ResetStats()
+		return c.String(http.StatusOK, "OK")
+	}
+}
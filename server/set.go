@@ -0,0 +1,79 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nishanth-gowda/kv-store/cache"
+)
+
+// SetAddHandler returns a handler function for POST /sadd
+// The member parameter may be repeated; the response reports how many were new
+func SetAddHandler(store *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.QueryParam("key")
+		members := c.QueryParams()["member"]
+		if key == "" || len(members) == 0 {
+			return c.String(http.StatusBadRequest, "key and at least one member are required")
+		}
+
+		added, err := store.SAdd(key, members...)
+		if err != nil {
+			return dataTypeError(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]int{"added": added})
+	}
+}
+
+// SetRemoveHandler returns a handler function for DELETE /srem
+// The member parameter may be repeated; the response reports how many existed
+func SetRemoveHandler(store *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.QueryParam("key")
+		members := c.QueryParams()["member"]
+		if key == "" || len(members) == 0 {
+			return c.String(http.StatusBadRequest, "key and at least one member are required")
+		}
+
+		removed, err := store.SRem(key, members...)
+		if err != nil {
+			return dataTypeError(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]int{"removed": removed})
+	}
+}
+
+// SetIsMemberHandler returns a handler function for GET /sismember
+func SetIsMemberHandler(store *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.QueryParam("key")
+		member := c.QueryParam("member")
+		if key == "" || member == "" {
+			return c.String(http.StatusBadRequest, "key and member are required")
+		}
+
+		isMember, err := store.SIsMember(key, member)
+		if err != nil {
+			return dataTypeError(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]bool{"member": isMember})
+	}
+}
+
+// SetMembersHandler returns a handler function for GET /smembers, /sinter and
+// /sunion using members, which is LRUCache.SUnion or LRUCache.SInter. The key
+// parameter may be repeated; the response is a sorted JSON array.
+func SetMembersHandler(members func(keys ...string) ([]string, error)) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		keys := c.QueryParams()["key"]
+		if len(keys) == 0 {
+			return c.String(http.StatusBadRequest, "at least one key is required")
+		}
+
+		result, err := members(keys...)
+		if err != nil {
+			return dataTypeError(c, err)
+		}
+		return c.JSON(http.StatusOK, result)
+	}
+}
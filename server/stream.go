@@ -0,0 +1,387 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nishanth-gowda/kv-store/cache"
+)
+
+// StreamEntryResponse is a stream entry in the JSON returned by the stream
+// endpoints
+type StreamEntryResponse struct {
+	ID     string            `json:"id"`
+	Fields map[string]string `json:"fields"`
+}
+
+// PendingEntryResponse is an unacknowledged entry in the JSON array returned
+// by GET /xpending
+type PendingEntryResponse struct {
+	ID         string `json:"id"`
+	Consumer   string `json:"consumer"`
+	Deliveries int    `json:"deliveries"`
+	IdleMillis int64  `json:"idle_ms"`
+}
+
+// streamEntries converts stream entries to their JSON representation
+func streamEntries(entries []cache.StreamEntry) []StreamEntryResponse {
+	resp := make([]StreamEntryResponse, len(entries))
+	for i, entry := range entries {
+		fields := entry.Fields
+		if fields == nil {
+			fields = map[string]string{}
+		}
+		resp[i] = StreamEntryResponse{ID: entry.ID.String(), Fields: fields}
+	}
+	return resp
+}
+
+// streamResults converts the entries read from several streams, keyed by
+// stream, to their JSON representation
+func streamResults(results map[string][]cache.StreamEntry) map[string][]StreamEntryResponse {
+	resp := make(map[string][]StreamEntryResponse, len(results))
+	for key, entries := range results {
+		resp[key] = streamEntries(entries)
+	}
+	return resp
+}
+
+// parseStreamID parses a stream ID query value. "-" is the smallest ID,
+// "+" the largest and "$" the last ID in the stream.
+func parseStreamID(s string) (cache.StreamID, error) {
+	switch s {
+	case "-":
+		return cache.StreamID{}, nil
+	case "+", "$":
+		return cache.LastStreamID, nil
+	}
+	return cache.ParseStreamID(s)
+}
+
+// parseStreamIDs parses the repeated id query parameter
+func parseStreamIDs(c echo.Context) ([]cache.StreamID, error) {
+	var ids []cache.StreamID
+	for _, param := range c.QueryParams()["id"] {
+		id, err := cache.ParseStreamID(param)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// parseCount parses the optional count query parameter; 0 means no limit
+func parseCount(c echo.Context) (int, error) {
+	param := c.QueryParam("count")
+	if param == "" {
+		return 0, nil
+	}
+	count, err := strconv.Atoi(param)
+	if err != nil || count < 0 {
+		return 0, errors.New("count must be a non-negative integer")
+	}
+	return count, nil
+}
+
+// parseBlock parses the optional block query parameter, reporting whether
+// the request should wait for entries
+func parseBlock(c echo.Context) (time.Duration, bool, error) {
+	param := c.QueryParam("block")
+	if param == "" {
+		return 0, false, nil
+	}
+	timeout, err := time.ParseDuration(param)
+	if err != nil || timeout < 0 {
+		return 0, false, errors.New("Invalid block timeout format")
+	}
+	return timeout, true, nil
+}
+
+// StreamAddHandler returns a handler function for POST /xadd
+// The request body is a JSON object of field/value pairs. id defaults to *,
+// generating an ID from the current time; the response is the entry's ID.
+func StreamAddHandler(store *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.QueryParam("key")
+		if key == "" {
+			return c.String(http.StatusBadRequest, "key is required")
+		}
+
+		var id cache.StreamID
+		if param := c.QueryParam("id"); param != "" && param != "*" {
+			var err error
+			if id, err = cache.ParseStreamID(param); err != nil || id == (cache.StreamID{}) {
+				return c.String(http.StatusBadRequest, "id must be * or an ID greater than 0-0")
+			}
+		}
+
+		var fields map[string]string
+		if err := json.NewDecoder(c.Request().Body).Decode(&fields); err != nil {
+			return c.String(http.StatusBadRequest, "body must be a JSON object of field/value pairs")
+		}
+		if len(fields) == 0 {
+			return c.String(http.StatusBadRequest, "at least one field is required")
+		}
+
+		added, err := store.XAdd(key, id, fields)
+		if err != nil {
+			return dataTypeError(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]string{"id": added.String()})
+	}
+}
+
+// StreamLengthHandler returns a handler function for GET /xlen
+func StreamLengthHandler(store *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.QueryParam("key")
+		if key == "" {
+			return c.String(http.StatusBadRequest, "key is required")
+		}
+
+		length, err := store.XLen(key)
+		if err != nil {
+			return dataTypeError(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]int{"length": length})
+	}
+}
+
+// StreamRangeHandler returns a handler function for GET /xrange
+// start and end default to - and +, returning every entry as a JSON array
+func StreamRangeHandler(store *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.QueryParam("key")
+		if key == "" {
+			return c.String(http.StatusBadRequest, "key is required")
+		}
+
+		start, end := cache.StreamID{}, cache.LastStreamID
+		var err error
+		if param := c.QueryParam("start"); param != "" {
+			if start, err = parseStreamID(param); err != nil {
+				return c.String(http.StatusBadRequest, "Invalid start ID")
+			}
+		}
+		if param := c.QueryParam("end"); param != "" {
+			if end, err = parseStreamID(param); err != nil {
+				return c.String(http.StatusBadRequest, "Invalid end ID")
+			}
+		}
+		count, err := parseCount(c)
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+
+		entries, err := store.XRange(key, start, end, count)
+		if err != nil {
+			return dataTypeError(c, err)
+		}
+		return c.JSON(http.StatusOK, streamEntries(entries))
+	}
+}
+
+// StreamReadHandler returns a handler function for GET /xread
+// Each repeated key parameter is paired with an id parameter, and entries
+// after that ID are returned as a JSON object keyed by stream. With block
+// (e.g. 5s; 0 waits until the client goes away) the request waits for
+// entries, and $ reads only entries added after it. Waiting stops when done
+// is closed.
+func StreamReadHandler(store *cache.LRUCache, done <-chan struct{}) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		keys := c.QueryParams()["key"]
+		ids := c.QueryParams()["id"]
+		if len(keys) == 0 || len(keys) != len(ids) {
+			return c.String(http.StatusBadRequest, "each key requires an id")
+		}
+
+		streams := make(map[string]cache.StreamID, len(keys))
+		for i, key := range keys {
+			id, err := parseStreamID(ids[i])
+			if err != nil {
+				return c.String(http.StatusBadRequest, "Invalid stream ID")
+			}
+			streams[key] = id
+		}
+		count, err := parseCount(c)
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+		timeout, block, err := parseBlock(c)
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+
+		if !block {
+			results, err := store.XRead(streams, count)
+			if err != nil {
+				return dataTypeError(c, err)
+			}
+			return c.JSON(http.StatusOK, streamResults(results))
+		}
+
+		ctx, cancel := requestContext(c, done)
+		defer cancel()
+		results, err := store.XReadBlock(ctx, timeout, streams, count)
+		return blockingStreamResponse(c, ctx.Err(), results, err)
+	}
+}
+
+// StreamGroupCreateHandler returns a handler function for POST /xgroup
+// The group is delivered entries after id, which defaults to $ so that
+// only new entries are delivered. The stream is created if needed.
+func StreamGroupCreateHandler(store *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.QueryParam("key")
+		group := c.QueryParam("group")
+		if key == "" || group == "" {
+			return c.String(http.StatusBadRequest, "key and group are required")
+		}
+
+		start := cache.LastStreamID
+		if param := c.QueryParam("id"); param != "" {
+			var err error
+			if start, err = parseStreamID(param); err != nil {
+				return c.String(http.StatusBadRequest, "Invalid stream ID")
+			}
+		}
+
+		if err := store.XGroupCreate(key, group, start); err != nil {
+			return dataTypeError(c, err)
+		}
+		return c.String(http.StatusOK, "OK")
+	}
+}
+
+// StreamReadGroupHandler returns a handler function for POST /xreadgroup
+// It delivers new entries from the repeated key parameters to consumer,
+// returning them as a JSON object keyed by stream; they stay pending until
+// acknowledged with POST /xack. block waits for entries like GET /xread.
+func StreamReadGroupHandler(store *cache.LRUCache, done <-chan struct{}) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		group := c.QueryParam("group")
+		consumer := c.QueryParam("consumer")
+		keys := c.QueryParams()["key"]
+		if group == "" || consumer == "" || len(keys) == 0 {
+			return c.String(http.StatusBadRequest, "group, consumer and at least one key are required")
+		}
+
+		count, err := parseCount(c)
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+		timeout, block, err := parseBlock(c)
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+
+		if !block {
+			results, err := store.XReadGroup(group, consumer, count, keys...)
+			if err != nil {
+				return dataTypeError(c, err)
+			}
+			return c.JSON(http.StatusOK, streamResults(results))
+		}
+
+		ctx, cancel := requestContext(c, done)
+		defer cancel()
+		results, err := store.XReadGroupBlock(ctx, timeout, group, consumer, count, keys...)
+		return blockingStreamResponse(c, ctx.Err(), results, err)
+	}
+}
+
+// blockingStreamResponse writes the result of a blocking stream read. A
+// timeout returns an empty JSON object.
+func blockingStreamResponse(c echo.Context, ctxErr error, results map[string][]cache.StreamEntry, err error) error {
+	switch {
+	case err == nil:
+		return c.JSON(http.StatusOK, streamResults(results))
+	case ctxErr != nil, errors.Is(err, cache.ErrClosed):
+		return c.String(http.StatusServiceUnavailable, "Server is shutting down")
+	}
+	return dataTypeError(c, err)
+}
+
+// StreamAckHandler returns a handler function for POST /xack
+// The id parameter may be repeated; the response reports how many entries
+// were pending
+func StreamAckHandler(store *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.QueryParam("key")
+		group := c.QueryParam("group")
+		if key == "" || group == "" {
+			return c.String(http.StatusBadRequest, "key, group and at least one id are required")
+		}
+		ids, err := parseStreamIDs(c)
+		if err != nil || len(ids) == 0 {
+			return c.String(http.StatusBadRequest, "key, group and at least one id are required")
+		}
+
+		acked, err := store.XAck(key, group, ids...)
+		if err != nil {
+			return dataTypeError(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]int{"acknowledged": acked})
+	}
+}
+
+// StreamPendingHandler returns a handler function for GET /xpending
+func StreamPendingHandler(store *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.QueryParam("key")
+		group := c.QueryParam("group")
+		if key == "" || group == "" {
+			return c.String(http.StatusBadRequest, "key and group are required")
+		}
+
+		pending, err := store.XPending(key, group)
+		if err != nil {
+			return dataTypeError(c, err)
+		}
+
+		now := time.Now()
+		resp := make([]PendingEntryResponse, len(pending))
+		for i, pe := range pending {
+			resp[i] = PendingEntryResponse{
+				ID:         pe.ID.String(),
+				Consumer:   pe.Consumer,
+				Deliveries: pe.Deliveries,
+				IdleMillis: now.Sub(pe.DeliveredAt).Milliseconds(),
+			}
+		}
+		return c.JSON(http.StatusOK, resp)
+	}
+}
+
+// StreamClaimHandler returns a handler function for POST /xclaim
+// Pending entries among the repeated id parameters idle for at least
+// min_idle (e.g. 1m, default 0) are transferred to consumer and returned.
+func StreamClaimHandler(store *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.QueryParam("key")
+		group := c.QueryParam("group")
+		consumer := c.QueryParam("consumer")
+		ids, err := parseStreamIDs(c)
+		if key == "" || group == "" || consumer == "" || err != nil || len(ids) == 0 {
+			return c.String(http.StatusBadRequest, "key, group, consumer and at least one id are required")
+		}
+
+		var minIdle time.Duration
+		if param := c.QueryParam("min_idle"); param != "" {
+			if minIdle, err = time.ParseDuration(param); err != nil || minIdle < 0 {
+				return c.String(http.StatusBadRequest, "Invalid min_idle format")
+			}
+		}
+
+		claimed, err := store.XClaim(key, group, consumer, minIdle, ids...)
+		if err != nil {
+			return dataTypeError(c, err)
+		}
+		return c.JSON(http.StatusOK, streamEntries(claimed))
+	}
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nishanth-gowda/kv-store/cache"
+)
+
+// WatchEvent is the JSON data of a Server-Sent Event sent by GET /watch
+type WatchEvent struct {
+	Type     string `json:"type"`
+	Key      string `json:"key"`
+	Value    any    `json:"value,omitempty"`
+	Sequence uint64 `json:"sequence"`
+}
+
+// WatchHandler returns a handler function for GET /watch
+// It streams changes to key, or to every key under prefix, as Server-Sent Events.
+// Each event id is EPOCH-N, where N is its sequence number and EPOCH changes
+// whenever the cache is reopened; reconnecting with ?since= or a Last-Event-ID
+// header set to such an id, or to a plain N, resumes after N. An id from
+// another epoch gets 410 Gone. The stream ends when done is closed.
+func WatchHandler(store *cache.LRUCache, done <-chan struct{}) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.QueryParam("key")
+		prefix := c.QueryParam("prefix")
+		if (key == "") == (prefix == "") {
+			return c.String(http.StatusBadRequest, "exactly one of key or prefix is required")
+		}
+
+		since := c.QueryParam("since")
+		if lastEventID := c.Request().Header.Get("Last-Event-ID"); lastEventID != "" {
+			since = lastEventID
+		}
+
+		var opts []cache.WatchOption
+		if since != "" {
+			seq, err := parseEventID(since, store.WatchEpoch())
+			if errors.Is(err, cache.ErrHistoryCompacted) {
+				return c.String(http.StatusGone, err.Error())
+			}
+			if err != nil {
+				return c.String(http.StatusBadRequest, "Invalid sequence number")
+			}
+			opts = append(opts, cache.FromSequence(seq))
+		}
+
+		var watcher *cache.Watcher
+		var err error
+		if key != "" {
+			watcher, err = store.Watch(key, opts...)
+		} else {
+			watcher, err = store.WatchPrefix(prefix, opts...)
+		}
+		if errors.Is(err, cache.ErrHistoryCompacted) {
+			return c.String(http.StatusGone, err.Error())
+		}
+		if err != nil {
+			return c.String(http.StatusInternalServerError, err.Error())
+		}
+		defer watcher.Close()
+
+		resp := c.Response()
+		resp.Header().Set(echo.HeaderContentType, "text/event-stream")
+		resp.Header().Set(echo.HeaderCacheControl, "no-cache")
+		resp.Header().Set(echo.HeaderConnection, "keep-alive")
+		resp.WriteHeader(http.StatusOK)
+		resp.Flush()
+
+		epoch := store.WatchEpoch()
+		ctx := c.Request().Context()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-done:
+				return nil
+			case event, ok := <-watcher.C:
+				if !ok {
+					if err := watcher.Err(); err != nil {
+						fmt.Fprintf(resp, "event: error\ndata: %s\n\n", err)
+						resp.Flush()
+					}
+					return nil
+				}
+
+				data, err := json.Marshal(WatchEvent{
+					Type:     event.Type.String(),
+					Key:      event.Key,
+					Value:    event.Value,
+					Sequence: event.Sequence,
+				})
+				if err != nil {
+					return err
+				}
+				if _, err := fmt.Fprintf(resp, "id: %s-%d\nevent: %s\ndata: %s\n\n", epoch, event.Sequence, event.Type, data); err != nil {
+					return nil
+				}
+				resp.Flush()
+			}
+		}
+	}
+}
+
+// parseEventID returns the sequence number in an event id, which is either
+// EPOCH-N or a plain N of the current epoch
+func parseEventID(id, epoch string) (uint64, error) {
+	if idEpoch, seq, ok := strings.Cut(id, "-"); ok {
+		if idEpoch != epoch {
+			return 0, fmt.Errorf("%w: event %s is from before the cache was reopened", cache.ErrHistoryCompacted, id)
+		}
+		id = seq
+	}
+	return strconv.ParseUint(id, 10, 64)
+}
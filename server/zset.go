@@ -0,0 +1,160 @@
+package server
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nishanth-gowda/kv-store/cache"
+)
+
+// ZMemberResponse is a sorted set member in the JSON arrays returned by
+// GET /zrange and GET /zrangebyscore
+type ZMemberResponse struct {
+	Member string  `json:"member"`
+	Score  float64 `json:"score"`
+}
+
+// finite reports whether a score can be represented in JSON
+func finite(score float64) bool {
+	return !math.IsNaN(score) && !math.IsInf(score, 0)
+}
+
+// zmembers converts sorted set members to their JSON representation
+func zmembers(members []cache.ZMember) []ZMemberResponse {
+	resp := make([]ZMemberResponse, len(members))
+	for i, m := range members {
+		resp[i] = ZMemberResponse{Member: m.Member, Score: m.Score}
+	}
+	return resp
+}
+
+// SortedSetAddHandler returns a handler function for POST /zadd
+// The request body is a JSON object of member/score pairs; the response
+// reports how many members were added rather than updated.
+func SortedSetAddHandler(store *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.QueryParam("key")
+		if key == "" {
+			return c.String(http.StatusBadRequest, "key is required")
+		}
+
+		var members map[string]float64
+		if err := json.NewDecoder(c.Request().Body).Decode(&members); err != nil {
+			return c.String(http.StatusBadRequest, "body must be a JSON object of member/score pairs")
+		}
+		if len(members) == 0 {
+			return c.String(http.StatusBadRequest, "at least one member is required")
+		}
+
+		added, err := store.ZAdd(key, members)
+		if err != nil {
+			return dataTypeError(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]int{"added": added})
+	}
+}
+
+// SortedSetIncrByHandler returns a handler function for POST /zincrby
+// by defaults to 1; the response is the new score of the member
+func SortedSetIncrByHandler(store *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.QueryParam("key")
+		member := c.QueryParam("member")
+		if key == "" || member == "" {
+			return c.String(http.StatusBadRequest, "key and member are required")
+		}
+
+		delta := 1.0
+		if by := c.QueryParam("by"); by != "" {
+			var err error
+			if delta, err = strconv.ParseFloat(by, 64); err != nil || !finite(delta) {
+				return c.String(http.StatusBadRequest, "by must be a finite number")
+			}
+		}
+
+		score, err := store.ZIncrBy(key, member, delta)
+		if err != nil {
+			return dataTypeError(c, err)
+		}
+		if !finite(score) {
+			return c.String(http.StatusBadRequest, "score overflowed")
+		}
+		return c.JSON(http.StatusOK, map[string]float64{"score": score})
+	}
+}
+
+// SortedSetRangeHandler returns a handler function for GET /zrange
+// start and stop are ranks and default to 0 and -1, returning every member
+func SortedSetRangeHandler(store *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.QueryParam("key")
+		if key == "" {
+			return c.String(http.StatusBadRequest, "key is required")
+		}
+
+		start, stop, err := parseRange(c)
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+
+		members, err := store.ZRange(key, start, stop)
+		if err != nil {
+			return dataTypeError(c, err)
+		}
+		return c.JSON(http.StatusOK, zmembers(members))
+	}
+}
+
+// SortedSetRangeByScoreHandler returns a handler function for GET /zrangebyscore
+// min and max are inclusive and default to -inf and +inf
+func SortedSetRangeByScoreHandler(store *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.QueryParam("key")
+		if key == "" {
+			return c.String(http.StatusBadRequest, "key is required")
+		}
+
+		minScore, maxScore := math.Inf(-1), math.Inf(1)
+		var err error
+		if param := c.QueryParam("min"); param != "" {
+			if minScore, err = strconv.ParseFloat(param, 64); err != nil {
+				return c.String(http.StatusBadRequest, "min must be a number")
+			}
+		}
+		if param := c.QueryParam("max"); param != "" {
+			if maxScore, err = strconv.ParseFloat(param, 64); err != nil {
+				return c.String(http.StatusBadRequest, "max must be a number")
+			}
+		}
+
+		members, err := store.ZRangeByScore(key, minScore, maxScore)
+		if err != nil {
+			return dataTypeError(c, err)
+		}
+		return c.JSON(http.StatusOK, zmembers(members))
+	}
+}
+
+// SortedSetRankHandler returns a handler function for GET /zrank
+// It responds 404 if the member is not in the sorted set
+func SortedSetRankHandler(store *cache.LRUCache) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.QueryParam("key")
+		member := c.QueryParam("member")
+		if key == "" || member == "" {
+			return c.String(http.StatusBadRequest, "key and member are required")
+		}
+
+		rank, ok, err := store.ZRank(key, member)
+		if err != nil {
+			return dataTypeError(c, err)
+		}
+		if !ok {
+			return c.String(http.StatusNotFound, "Member not found")
+		}
+		return c.JSON(http.StatusOK, map[string]int{"rank": rank})
+	}
+}
@@ -0,0 +1,213 @@
+package main_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nishanth-gowda/kv-store/cache"
+	"github.com/nishanth-gowda/kv-store/client"
+	"github.com/nishanth-gowda/kv-store/server"
+)
+
+// newTestClient starts an httptest server wrapping the real handlers
+func newTestClient(t *testing.T, capacity int, opts ...client.Option) *client.Client {
+	t.Helper()
+
+	c, err := cache.NewLRUCache(capacity, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	srv := httptest.NewServer(server.New(c))
+	t.Cleanup(srv.Close)
+
+	kv, err := client.New(srv.URL, opts...)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	return kv
+}
+
+func TestClientSetGetDelete(t *testing.T) {
+	kv := newTestClient(t, 10)
+	ctx := context.Background()
+
+	if err := kv.Set(ctx, "key1", "value1", 0); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	value, err := kv.Get(ctx, "key1")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if value != "value1" {
+		t.Fatalf("Get returned %q, want %q", value, "value1")
+	}
+
+	if err := kv.Delete(ctx, "key1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	if _, err := kv.Get(ctx, "key1"); !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("Get after Delete returned %v, want ErrNotFound", err)
+	}
+}
+
+func TestClientTTL(t *testing.T) {
+	kv := newTestClient(t, 10)
+	ctx := context.Background()
+
+	if err := kv.Set(ctx, "short", "value", 50*time.Millisecond); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if _, err := kv.Get(ctx, "short"); err != nil {
+		t.Fatalf("Get before expiry failed: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if _, err := kv.Get(ctx, "short"); !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("Get after expiry returned %v, want ErrNotFound", err)
+	}
+}
+
+func TestClientCompareAndSwap(t *testing.T) {
+	kv := newTestClient(t, 10)
+	ctx := context.Background()
+
+	if err := kv.CompareAndSwap(ctx, "counter", "1", "2", 0); !errors.Is(err, client.ErrConflict) {
+		t.Fatalf("CAS on missing key returned %v, want ErrConflict", err)
+	}
+
+	if err := kv.Set(ctx, "counter", "1", 0); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := kv.CompareAndSwap(ctx, "counter", "1", "2", 0); err != nil {
+		t.Fatalf("CAS failed: %v", err)
+	}
+	if err := kv.CompareAndSwap(ctx, "counter", "1", "3", 0); !errors.Is(err, client.ErrConflict) {
+		t.Fatalf("stale CAS returned %v, want ErrConflict", err)
+	}
+
+	value, err := kv.Get(ctx, "counter")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if value != "2" {
+		t.Fatalf("Get returned %q, want %q", value, "2")
+	}
+}
+
+func TestClientBatch(t *testing.T) {
+	kv := newTestClient(t, 10)
+	ctx := context.Background()
+
+	if err := kv.MSet(ctx, map[string]string{"a": "1", "b": "2"}, time.Minute); err != nil {
+		t.Fatalf("MSet failed: %v", err)
+	}
+
+	values, err := kv.MGet(ctx, "a", "b", "missing")
+	if err != nil {
+		t.Fatalf("MGet failed: %v", err)
+	}
+	if len(values) != 2 || values["a"] != "1" || values["b"] != "2" {
+		t.Fatalf("MGet returned %v", values)
+	}
+}
+
+func TestClientRetriesServerErrors(t *testing.T) {
+	c, err := cache.NewLRUCache(10, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	// Fail the first two requests before handing over to the real handlers
+	var calls atomic.Int32
+	handler := server.New(c)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 2 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	kv, err := client.New(srv.URL, client.WithRetries(3, time.Millisecond, 5*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	if err := kv.Set(context.Background(), "key", "value", 0); err != nil {
+		t.Fatalf("Set failed after retries: %v", err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Fatalf("server saw %d calls, want 3", got)
+	}
+
+	// Retries exhausted surfaces the last status
+	calls.Store(-10)
+	var statusErr *client.StatusError
+	if err := kv.Delete(context.Background(), "key"); !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Delete returned %v, want 503 StatusError", err)
+	}
+}
+
+func TestClientDoesNotRetryFull(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, cache.ErrFull.Error(), http.StatusInsufficientStorage)
+	}))
+	defer srv.Close()
+
+	kv, err := client.New(srv.URL, client.WithRetries(3, time.Millisecond, 5*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	var statusErr *client.StatusError
+	if err := kv.Set(context.Background(), "key", "value", 0); !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusInsufficientStorage {
+		t.Fatalf("Set returned %v, want 507 StatusError", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("server saw %d calls, want 1", got)
+	}
+}
+
+func TestClientTimeoutLeavesHTTPClientAlone(t *testing.T) {
+	shared := &http.Client{Timeout: time.Minute}
+	if _, err := client.New("http://localhost:8080", client.WithHTTPClient(shared), client.WithTimeout(time.Second)); err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if shared.Timeout != time.Minute {
+		t.Fatalf("WithTimeout changed the shared http.Client's timeout to %v", shared.Timeout)
+	}
+
+	// A nil http.Client means http.DefaultClient, which is left alone too
+	kv := newTestClient(t, 10, client.WithHTTPClient(nil), client.WithTimeout(time.Second))
+	if http.DefaultClient.Timeout != 0 {
+		t.Fatalf("WithTimeout changed http.DefaultClient's timeout to %v", http.DefaultClient.Timeout)
+	}
+	if err := kv.Set(context.Background(), "key", "value", 0); err != nil {
+		t.Fatalf("Set with http.DefaultClient failed: %v", err)
+	}
+}
+
+func TestClientContextCancel(t *testing.T) {
+	kv := newTestClient(t, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := kv.Get(ctx, "key"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Get with cancelled context returned %v, want context.Canceled", err)
+	}
+}
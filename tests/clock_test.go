@@ -0,0 +1,141 @@
+package main_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nishanth-gowda/kv-store/cache"
+)
+
+// fakeClock is a cache.Clock that only moves when told to
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// expiryState describes the value and remaining TTL of each key
+func expiryState(c *cache.LRUCache, keys []string) string {
+	var state string
+	for _, key := range keys {
+		ttl, _ := c.TTL(key)
+		value, _ := c.Get(key)
+		state += fmt.Sprintf("%s=%v:%v ", key, value, ttl)
+	}
+	return state
+}
+
+func TestFakeClockExpiry(t *testing.T) {
+	clock := newFakeClock()
+	c, err := cache.NewLRUCache(10, "", false, 0, 0, cache.WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	c.Set("k", "v", 10*time.Second)
+	clock.Advance(10*time.Second - time.Nanosecond)
+	if ttl, ok := c.TTL("k"); !ok || ttl != time.Nanosecond {
+		t.Fatalf("TTL a nanosecond before the deadline = %v, %v", ttl, ok)
+	}
+	// A key expires exactly at its deadline
+	clock.Advance(time.Nanosecond)
+	if _, ok := c.Get("k"); ok {
+		t.Fatalf("Key was live at its deadline")
+	}
+
+	// Real time passing does not expire keys
+	c.Set("k", "v", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := c.Get("k"); !ok {
+		t.Fatalf("Key expired while the fake clock stood still")
+	}
+}
+
+func TestExpiryAcrossRecovery(t *testing.T) {
+	keys := []string{"plain", "ttl", "expireat", "persisted", "touched", "hash", "list", "recreated"}
+
+	// Offsets from the writes at which the live and recovered caches are
+	// compared, including each deadline and the instant before it
+	offsets := []time.Duration{
+		0,
+		5*time.Second - time.Nanosecond,
+		5 * time.Second,
+		10*time.Second - time.Nanosecond,
+		10 * time.Second,
+		12 * time.Second,
+		15 * time.Second,
+		time.Hour,
+	}
+
+	for _, snapshot := range []bool{false, true} {
+		for _, offset := range offsets {
+			t.Run(fmt.Sprintf("snapshot=%v/%v", snapshot, offset), func(t *testing.T) {
+				walDir := t.TempDir()
+				clock := newFakeClock()
+				c, err := cache.NewLRUCache(10, walDir, false, 10*1024*1024, 10, cache.WithClock(clock))
+				if err != nil {
+					t.Fatalf("Failed to create cache: %v", err)
+				}
+
+				c.Set("plain", "v", 0)
+				c.Set("ttl", "v", 10*time.Second)
+				c.Set("expireat", "v", 0)
+				c.ExpireAt("expireat", clock.Now().Add(5*time.Second))
+				c.Set("persisted", "v", time.Second)
+				c.Persist("persisted")
+				c.Set("touched", "v", 10*time.Second)
+				c.SetDefaultTTL(time.Second)
+				c.SAdd("recreated", "old")
+				c.SetDefaultTTL(15 * time.Second)
+				c.HSet("hash", map[string]string{"f": "v"})
+				c.RPush("list", "a")
+				clock.Advance(2 * time.Second)
+				c.Touch("touched")
+				c.HSet("hash", map[string]string{"g": "v"})
+				// The old set has expired, so this starts a new one
+				c.SAdd("recreated", "new")
+				clock.Advance(-2 * time.Second)
+
+				if snapshot {
+					if err := c.Snapshot(); err != nil {
+						t.Fatalf("Snapshot failed: %v", err)
+					}
+				}
+
+				clock.Advance(offset)
+				live := expiryState(c, keys)
+				if err := c.Close(); err != nil {
+					t.Fatalf("Close failed: %v", err)
+				}
+
+				recovered, err := cache.NewLRUCache(10, walDir, false, 10*1024*1024, 10, cache.WithClock(clock))
+				if err != nil {
+					t.Fatalf("Failed to recover cache: %v", err)
+				}
+				defer recovered.Close()
+
+				if got := expiryState(recovered, keys); got != live {
+					t.Fatalf("Recovered expiry differs\nlive:      %s\nrecovered: %s", live, got)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package main_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/nishanth-gowda/kv-store/cache"
+)
+
+// jsonDocument returns a compressible JSON value of roughly n bytes
+func jsonDocument(n int) string {
+	var b strings.Builder
+	b.WriteString("[")
+	for i := 0; b.Len() < n; i++ {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, `{"id":%d,"status":"active","region":"us-east-1","tags":["customer","priority"]}`, i)
+	}
+	b.WriteString("]")
+	return b.String()
+}
+
+func TestWALCompression(t *testing.T) {
+	doc := jsonDocument(8 * 1024)
+	write := func(t *testing.T, dir string, opts ...cache.Option) {
+		t.Helper()
+		c, err := cache.NewLRUCache(100, dir, false, 10*1024*1024, 10, opts...)
+		if err != nil {
+			t.Fatalf("Failed to create cache: %v", err)
+		}
+		defer c.Close()
+		for i := 0; i < 10; i++ {
+			if err := c.Set(fmt.Sprintf("doc-%d", i), doc, 0); err != nil {
+				t.Fatalf("Set failed: %v", err)
+			}
+		}
+		// Values below the threshold stay in plain records
+		if err := c.Set("small", "tiny", 0); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+	}
+
+	plainDir := t.TempDir()
+	write(t, plainDir)
+
+	tests := []struct {
+		name string
+		opts []cache.Option
+	}{
+		{"compressed", []cache.Option{cache.WithWALCompression(1024)}},
+		{"compressed and encrypted", []cache.Option{
+			cache.WithWALCompression(1024),
+			cache.WithEncryption(mustKeyRing(t, "1:"+testKey(1))),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			write(t, dir, tt.opts...)
+			if plain, compressed := walSize(t, plainDir), walSize(t, dir); compressed*4 > plain {
+				t.Errorf("WAL is %d bytes, want well under the %d bytes written without compression", compressed, plain)
+			}
+
+			recovered, err := cache.NewLRUCache(100, dir, false, 10*1024*1024, 10, tt.opts...)
+			if err != nil {
+				t.Fatalf("Failed to recover cache: %v", err)
+			}
+			defer recovered.Close()
+			for i := 0; i < 10; i++ {
+				if value, _ := recovered.Get(fmt.Sprintf("doc-%d", i)); value != doc {
+					t.Fatalf("Get(doc-%d) returned a different value after recovery", i)
+				}
+			}
+			if value, _ := recovered.Get("small"); value != "tiny" {
+				t.Errorf("Get(small) = %v", value)
+			}
+		})
+	}
+
+	// Compressed records are read without the option, which only affects writes
+	dir := t.TempDir()
+	write(t, dir, cache.WithWALCompression(1024))
+	recovered, err := cache.NewLRUCache(100, dir, false, 10*1024*1024, 10)
+	if err != nil {
+		t.Fatalf("Failed to recover cache without compression: %v", err)
+	}
+	defer recovered.Close()
+	if value, _ := recovered.Get("doc-0"); value != doc {
+		t.Errorf("Get(doc-0) returned a different value")
+	}
+}
+
+func TestInMemoryCompression(t *testing.T) {
+	c, err := cache.NewLRUCache(100, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	doc := jsonDocument(16 * 1024)
+	blob := bytes.Repeat([]byte("abcd"), 4096)
+	c.Set("plain", doc, 0)
+	before, _ := c.Stats()
+
+	c.SetCompressionThreshold(1024)
+	if c.CompressionThreshold() != 1024 {
+		t.Fatalf("CompressionThreshold = %d", c.CompressionThreshold())
+	}
+	c.Set("doc", doc, 0)
+	c.Set("blob", blob, 0)
+	c.Set("small", "tiny", 0)
+	after, _ := c.Stats()
+	if grown := after.Bytes - before.Bytes; grown*4 > before.Bytes {
+		t.Errorf("Compressed values grew Bytes by %d, want well under the %d of the uncompressed value", grown, before.Bytes)
+	}
+
+	if value, _ := c.Get("doc"); value != doc {
+		t.Errorf("Get(doc) returned a different value")
+	}
+	if value, _ := c.Get("blob"); !bytes.Equal(value.([]byte), blob) {
+		t.Errorf("Get(blob) returned a different value")
+	}
+	if value, _ := c.Get("small"); value != "tiny" {
+		t.Errorf("Get(small) = %v", value)
+	}
+
+	// Compressed values compare, and are reported to OnRemoval, uncompressed
+	if swapped, err := c.CompareAndSwap("doc", doc, "replaced", 0); err != nil || !swapped {
+		t.Errorf("CompareAndSwap = %v, %v, want true", swapped, err)
+	}
+	var removed any
+	c.OnRemoval(func(key string, value any, reason cache.RemovalReason) {
+		removed = value
+	})
+	c.Delete("blob")
+	if data, ok := removed.([]byte); !ok || !bytes.Equal(data, blob) {
+		t.Errorf("OnRemoval got %T, want the uncompressed value", removed)
+	}
+}
@@ -0,0 +1,145 @@
+package main_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nishanth-gowda/kv-store/config"
+)
+
+// envFrom returns a lookup function backed by a fixed map instead of the process environment
+func envFrom(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+}
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestConfigDefaults(t *testing.T) {
+	cfg, err := config.Load(nil, envFrom(nil))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if *cfg != *config.Default() {
+		t.Fatalf("Load without overrides returned %+v, want defaults", cfg)
+	}
+}
+
+func TestConfigFileFormats(t *testing.T) {
+	files := map[string]string{
+		"kv.json": `{"cache": {"capacity": 42}, "wal": {"dir": "/data/wal", "force_sync": true, "max_file_size": "2MB"}}`,
+		"kv.yaml": "cache:\n  capacity: 42 # entries\nwal:\n  dir: \"/data/wal\"\n  force_sync: true\n  max_file_size: 2MB\n",
+		"kv.toml": "[cache]\ncapacity = 42\n\n[wal]\ndir = '/data/wal'\nforce_sync = true\nmax_file_size = \"2MB\"\n",
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			path := writeConfigFile(t, name, content)
+
+			cfg, err := config.Load([]string{"-config", path}, envFrom(nil))
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			if cfg.Cache.Capacity != 42 || cfg.WAL.Directory != "/data/wal" || !cfg.WAL.ForceSync || cfg.WAL.MaxFileSize != 2<<20 {
+				t.Fatalf("Load returned %+v", cfg)
+			}
+			// Unset values keep their defaults
+			if cfg.WAL.MaxSegments != config.Default().WAL.MaxSegments {
+				t.Fatalf("MaxSegments = %d, want default", cfg.WAL.MaxSegments)
+			}
+		})
+	}
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	path := writeConfigFile(t, "kv.yaml", "cache:\n  capacity: 10\nserver:\n  addr: :7000\nwal:\n  max_segments: 3\n")
+	env := envFrom(map[string]string{
+		"KV_CONFIG":         path,
+		"KV_CACHE_CAPACITY": "20",
+		"KV_SERVER_ADDR":    ":7001",
+	})
+
+	cfg, err := config.Load([]string{"-server-addr", ":7002"}, env)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if cfg.WAL.MaxSegments != 3 {
+		t.Errorf("file should override defaults: MaxSegments = %d", cfg.WAL.MaxSegments)
+	}
+	if cfg.Cache.Capacity != 20 {
+		t.Errorf("env should override file: Capacity = %d", cfg.Cache.Capacity)
+	}
+	if cfg.Server.Addr != ":7002" {
+		t.Errorf("flag should override env: Addr = %q", cfg.Server.Addr)
+	}
+}
+
+func TestConfigValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"capacity", []string{"-cache-capacity", "0"}, "cache.capacity must be positive"},
+		{"segments", []string{"-wal-max-segments", "0"}, "wal.max_segments must be positive"},
+		{"bad integer", []string{"-cache-capacity", "lots"}, "invalid integer"},
+		{"bad size", []string{"-wal-max-file-size", "10XB"}, "invalid size"},
+		{"eviction policy", []string{"-cache-eviction-policy", "lfu"}, "cache.eviction_policy must be lru, random or noeviction"},
+		{"sliding granularity", []string{"-cache-sliding-granularity", "-1s"}, "cache.sliding_granularity must not be negative"},
+		{"compression threshold", []string{"-wal-compression-threshold", "-1KB"}, "wal.compression_threshold must not be negative"},
+		{"encryption keys", []string{"-wal-encryption-key-file", "keys", "-wal-encryption-keys", "1:a2V5"}, "set only one of wal.encryption_key_file and wal.encryption_keys"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := config.Load(tt.args, envFrom(nil))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Load returned %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+
+	// WAL limits are not checked when the WAL is disabled
+	if _, err := config.Load([]string{"-wal-dir", "", "-wal-max-segments", "0"}, envFrom(nil)); err != nil {
+		t.Fatalf("Load with WAL disabled failed: %v", err)
+	}
+}
+
+func TestConfigUnknownFileSetting(t *testing.T) {
+	path := writeConfigFile(t, "kv.toml", "[cache]\ncapacty = 5\n")
+
+	_, err := config.Load([]string{"-config", path}, envFrom(nil))
+	if err == nil || !strings.Contains(err.Error(), `unknown setting "cache.capacty"`) {
+		t.Fatalf("Load returned %v, want unknown setting error", err)
+	}
+}
+
+func TestConfigPrintRedactsKeys(t *testing.T) {
+	cfg, err := config.Load(nil, envFrom(map[string]string{"KV_WAL_ENCRYPTION_KEYS": "1:c2VjcmV0"}))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.WAL.EncryptionKeys != "1:c2VjcmV0" {
+		t.Fatalf("EncryptionKeys = %q", cfg.WAL.EncryptionKeys)
+	}
+
+	var out strings.Builder
+	if err := cfg.Print(&out); err != nil {
+		t.Fatalf("Print failed: %v", err)
+	}
+	if strings.Contains(out.String(), "c2VjcmV0") || !strings.Contains(out.String(), `"encryption_keys": "redacted"`) {
+		t.Fatalf("Print did not redact the keys:\n%s", out.String())
+	}
+}
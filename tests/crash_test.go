@@ -0,0 +1,202 @@
+package main_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/nishanth-gowda/kv-store/cache"
+)
+
+// crashOp is one step of the crash test workload: a Set, a Delete if value
+// is empty, or a snapshot
+type crashOp struct {
+	key      string
+	value    string
+	snapshot bool
+}
+
+// crashWorkload returns writes to a few keys with values from a few bytes to
+// more than the WAL write buffer, so records are written in one or several
+// writes and segments rotate, with snapshots in between
+func crashWorkload(keys []string, n int) []crashOp {
+	sizes := []int{0, 100, 1500, 5000}
+	var ops []crashOp
+	for i := 0; i < n; i++ {
+		key := keys[i%len(keys)]
+		switch {
+		case i%13 == 12:
+			ops = append(ops, crashOp{snapshot: true})
+		case i%5 == 4:
+			ops = append(ops, crashOp{key: key})
+		default:
+			value := fmt.Sprintf("%s-%d-%s", key, i, strings.Repeat("x", sizes[i%len(sizes)]))
+			ops = append(ops, crashOp{key: key, value: value})
+		}
+	}
+	return ops
+}
+
+// crashModel tracks the values each key may hold after recovery: the last
+// acknowledged one, or one written by an unacknowledged write since
+type crashModel struct {
+	acked   map[string]any
+	pending map[string][]any
+}
+
+func newCrashModel() *crashModel {
+	return &crashModel{acked: make(map[string]any), pending: make(map[string][]any)}
+}
+
+func (m *crashModel) record(key string, value any, err error) {
+	if err != nil {
+		m.pending[key] = append(m.pending[key], value)
+		return
+	}
+	m.acked[key] = value
+	delete(m.pending, key)
+}
+
+// check reports keys whose recovered value is neither acknowledged nor pending
+func (m *crashModel) check(t *testing.T, c *cache.LRUCache, keys []string, label string) {
+	t.Helper()
+	for _, key := range keys {
+		value, _ := c.Get(key)
+		allowed := append([]any{m.acked[key]}, m.pending[key]...)
+		found := false
+		for _, want := range allowed {
+			found = found || value == want
+		}
+		if !found {
+			t.Errorf("%s: %s = %.40v after recovery, want the acknowledged %.40v or one of %d unacknowledged writes",
+				label, key, value, m.acked[key], len(m.pending[key]))
+		}
+	}
+}
+
+// openCrashCache opens a cache that fsyncs every write over storage, with
+// small segments so the workload rotates them
+func openCrashCache(storage *faultFS) (*cache.LRUCache, error) {
+	return cache.NewLRUCache(100, "", true, 4096, 1000, cache.WithWALStorage(storage))
+}
+
+// runCrashWorkload applies ops, recording in model which were acknowledged.
+// Unless keepGoing is set it stops at the first failure, as a process that
+// lost its disk would. The cache is returned open.
+func runCrashWorkload(c *cache.LRUCache, ops []crashOp, model *crashModel, keepGoing bool) {
+	for _, op := range ops {
+		var err error
+		switch {
+		case op.snapshot:
+			err = c.Snapshot()
+		case op.value == "":
+			err = c.Delete(op.key)
+			model.record(op.key, nil, err)
+		default:
+			err = c.Set(op.key, op.value, 0)
+			model.record(op.key, op.value, err)
+		}
+		if err != nil && !keepGoing {
+			return
+		}
+	}
+}
+
+// recoverAndCheck recovers a cache from image, checks it against model, and
+// checks that writes acknowledged after recovery survive a second crash
+func recoverAndCheck(t *testing.T, image *faultFS, model *crashModel, keys []string, label string) {
+	t.Helper()
+	c, err := openCrashCache(image)
+	if err != nil {
+		t.Fatalf("%s: recovery failed: %v", label, err)
+	}
+	model.check(t, c, keys, label)
+
+	// Acknowledged state now includes whatever was recovered
+	for _, key := range keys {
+		value, _ := c.Get(key)
+		model.record(key, value, nil)
+	}
+	runCrashWorkload(c, crashWorkload(keys, 6), model, false)
+	again := image.image(loseTail)
+	c.Close()
+
+	c, err = openCrashCache(again)
+	if err != nil {
+		t.Fatalf("%s: second recovery failed: %v", label, err)
+	}
+	defer c.Close()
+	model.check(t, c, keys, label+", after writing past the recovered WAL")
+}
+
+func TestCrashRecovery(t *testing.T) {
+	keys := []string{"a", "b", "c", "d"}
+	ops := crashWorkload(keys, 60)
+
+	// Count the storage operations of a run without faults
+	clean := newFaultFS()
+	c, err := openCrashCache(clean)
+	if err != nil {
+		t.Fatalf("Failed to open cache: %v", err)
+	}
+	runCrashWorkload(c, ops, newCrashModel(), false)
+	c.Close()
+	total := clean.ops
+
+	tails := []struct {
+		name string
+		tail tailPolicy
+	}{
+		{"unsynced data lost", loseTail},
+		{"unsynced data kept", keepTail},
+		{"unsynced data torn", tearTail},
+		{"size prefix torn", tearPrefix},
+	}
+
+	// Power loss at every write, fsync, create, rename and remove
+	for crashAt := 1; crashAt <= total; crashAt++ {
+		for _, tt := range tails {
+			storage := newFaultFS()
+			storage.crashAt = crashAt
+			model := newCrashModel()
+			c, err := openCrashCache(storage)
+			if err == nil {
+				runCrashWorkload(c, ops, model, false)
+			}
+			image := storage.image(tt.tail)
+			if c != nil {
+				c.Close()
+			}
+			recoverAndCheck(t, image, model, keys, fmt.Sprintf("crash at operation %d/%d, %s", crashAt, total, tt.name))
+		}
+	}
+}
+
+func TestCrashRecoveryAfterFailedFsync(t *testing.T) {
+	keys := []string{"a", "b", "c"}
+	ops := crashWorkload(keys, 40)
+
+	clean := newFaultFS()
+	c, err := openCrashCache(clean)
+	if err != nil {
+		t.Fatalf("Failed to open cache: %v", err)
+	}
+	runCrashWorkload(c, ops, newCrashModel(), false)
+	c.Close()
+
+	// A failed fsync fails its write, but the process carries on until the
+	// power is lost at the end
+	for failAt := 1; failAt <= clean.ops; failAt++ {
+		storage := newFaultFS()
+		storage.failSyncAt = failAt
+		model := newCrashModel()
+		c, err := openCrashCache(storage)
+		if err != nil {
+			continue
+		}
+		runCrashWorkload(c, ops, model, true)
+		image := storage.image(loseTail)
+		c.Close()
+		recoverAndCheck(t, image, model, keys, fmt.Sprintf("fsync failure at operation %d", failAt))
+	}
+}
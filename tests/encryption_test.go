@@ -0,0 +1,187 @@
+package main_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nishanth-gowda/kv-store/cache"
+	"github.com/nishanth-gowda/kv-store/wal"
+)
+
+// testKey returns a base64 AES-256 key filled with b
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+}
+
+func mustKeyRing(t *testing.T, text string) *wal.KeyRing {
+	t.Helper()
+	ring, err := wal.ParseKeyRing(text)
+	if err != nil {
+		t.Fatalf("ParseKeyRing failed: %v", err)
+	}
+	return ring
+}
+
+// walContains reports whether any file in a WAL directory contains s
+func walContains(t *testing.T, dir, s string) bool {
+	t.Helper()
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read WAL directory: %v", err)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			t.Fatalf("Failed to read WAL file: %v", err)
+		}
+		if bytes.Contains(data, []byte(s)) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestEncryptedWAL(t *testing.T) {
+	walDir := t.TempDir()
+	keys := mustKeyRing(t, "1:"+testKey(1))
+
+	c, err := cache.NewLRUCache(10, walDir, false, 10*1024*1024, 10, cache.WithEncryption(keys))
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	c.Set("customer-email", "alice@example.com", 0)
+	c.HSet("customer-card", map[string]string{"number": "4111111111111111"})
+	if err := c.Snapshot(); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	c.Set("customer-phone", "555-0100", 0)
+	c.Close()
+
+	for _, secret := range []string{"customer", "alice@example.com", "4111111111111111", "555-0100"} {
+		if walContains(t, walDir, secret) {
+			t.Errorf("WAL contains %q in plaintext", secret)
+		}
+	}
+
+	recovered, err := cache.NewLRUCache(10, walDir, false, 10*1024*1024, 10, cache.WithEncryption(keys))
+	if err != nil {
+		t.Fatalf("Failed to recover cache: %v", err)
+	}
+	if value, _ := recovered.Get("customer-email"); value != "alice@example.com" {
+		t.Errorf("Get(customer-email) = %v", value)
+	}
+	if value, _, _ := recovered.HGet("customer-card", "number"); value != "4111111111111111" {
+		t.Errorf("HGet(customer-card) = %v", value)
+	}
+	if value, _ := recovered.Get("customer-phone"); value != "555-0100" {
+		t.Errorf("Get(customer-phone) = %v", value)
+	}
+	recovered.Close()
+
+	// Records that cannot be decrypted fail recovery instead of being dropped
+	tests := []struct {
+		name string
+		opts []cache.Option
+		want string
+	}{
+		{"no keys", nil, "encrypted with key 1, which is not in the key ring"},
+		{"other key", []cache.Option{cache.WithEncryption(mustKeyRing(t, "2:"+testKey(2)))}, "not in the key ring"},
+		{"wrong key", []cache.Option{cache.WithEncryption(mustKeyRing(t, "1:"+testKey(9)))}, "failed to decrypt record"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := cache.NewLRUCache(10, walDir, false, 10*1024*1024, 10, tt.opts...)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("NewLRUCache returned %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyRotationAndReencrypt(t *testing.T) {
+	walDir := t.TempDir()
+	open := func(keys *wal.KeyRing) *cache.LRUCache {
+		t.Helper()
+		var opts []cache.Option
+		if keys != nil {
+			opts = append(opts, cache.WithEncryption(keys))
+		}
+		c, err := cache.NewLRUCache(10, walDir, false, 10*1024*1024, 10, opts...)
+		if err != nil {
+			t.Fatalf("Failed to open cache: %v", err)
+		}
+		return c
+	}
+
+	// A plaintext WAL, then records under key 1, then under key 2
+	c := open(nil)
+	c.Set("plain", "v0", 0)
+	c.Close()
+	ring1 := mustKeyRing(t, "1:"+testKey(1))
+	c = open(ring1)
+	c.Set("first", "v1", 0)
+	c.Close()
+	rotated := mustKeyRing(t, "1:"+testKey(1)+"\n# rotated\n2:"+testKey(2)+"\n")
+	if rotated.ActiveKeyID() != 2 {
+		t.Fatalf("ActiveKeyID = %d, want the last key", rotated.ActiveKeyID())
+	}
+	c = open(rotated)
+	c.Set("second", "v2", 0)
+	want := expiryState(c, []string{"plain", "first", "second"})
+	c.Close()
+
+	// Rewriting moves every record to key 2, so key 1 can be retired
+	n, err := wal.Reencrypt(walDir, rotated, rotated)
+	if err != nil || n == 0 {
+		t.Fatalf("Reencrypt = %d, %v", n, err)
+	}
+	if walContains(t, walDir, "plain") {
+		t.Errorf("Reencrypt left a plaintext record")
+	}
+	c = open(mustKeyRing(t, "2:"+testKey(2)))
+	if got := expiryState(c, []string{"plain", "first", "second"}); got != want {
+		t.Errorf("State after Reencrypt = %s, want %s", got, want)
+	}
+	c.Close()
+
+	// Decrypting makes the WAL readable without keys
+	if _, err := wal.Reencrypt(walDir, rotated, nil); err != nil {
+		t.Fatalf("Reencrypt to plaintext failed: %v", err)
+	}
+	c = open(nil)
+	defer c.Close()
+	if got := expiryState(c, []string{"plain", "first", "second"}); got != want {
+		t.Errorf("State after decrypting = %s, want %s", got, want)
+	}
+}
+
+func TestParseKeyRing(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"empty", "# no keys\n", "no keys given"},
+		{"missing id", testKey(1), "want id:base64-key"},
+		{"bad id", "x:" + testKey(1), "invalid key ID"},
+		{"bad base64", "1:not base64!", "key 1 is not valid base64"},
+		{"bad length", "1:" + base64.StdEncoding.EncodeToString([]byte("short")), "invalid key size"},
+		{"duplicate", "1:" + testKey(1) + ",1:" + testKey(2), "duplicate key ID 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := wal.ParseKeyRing(tt.text)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("ParseKeyRing returned %v, want error containing %q", err, tt.want)
+			}
+			// Errors never echo key material
+			if strings.Contains(err.Error(), testKey(1)[4:]) {
+				t.Fatalf("Error reveals the key: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package main_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nishanth-gowda/kv-store/cache"
+	"github.com/nishanth-gowda/kv-store/server"
+)
+
+func TestExpireOperations(t *testing.T) {
+	c, err := cache.NewLRUCache(10, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	if ok, err := c.Expire("missing", time.Minute); ok || err != nil {
+		t.Fatalf("Expire of a missing key = %v, %v", ok, err)
+	}
+
+	c.Set("k", "v", 0)
+	if ok, err := c.Expire("k", time.Minute); !ok || err != nil {
+		t.Fatalf("Expire = %v, %v", ok, err)
+	}
+	if ttl, _ := c.TTL("k"); ttl <= 50*time.Second || ttl > time.Minute {
+		t.Fatalf("TTL after Expire = %v", ttl)
+	}
+	if value, _ := c.Get("k"); value != "v" {
+		t.Fatalf("Expire changed the value to %v", value)
+	}
+
+	if ok, _ := c.Persist("k"); !ok {
+		t.Fatalf("Persist of a key with a TTL returned false")
+	}
+	if ttl, ok := c.TTL("k"); !ok || ttl != 0 {
+		t.Fatalf("TTL after Persist = %v, %v", ttl, ok)
+	}
+	if ok, _ := c.Persist("k"); ok {
+		t.Fatalf("Persist of a key without a TTL returned true")
+	}
+
+	c.ExpireAt("k", time.Now().Add(time.Hour))
+	if ttl, _ := c.TTL("k"); ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Fatalf("TTL after ExpireAt = %v", ttl)
+	}
+	// A time in the past deletes the key
+	if ok, err := c.ExpireAt("k", time.Now().Add(-time.Second)); !ok || err != nil {
+		t.Fatalf("ExpireAt in the past = %v, %v", ok, err)
+	}
+	if _, ok := c.Get("k"); ok {
+		t.Fatalf("ExpireAt in the past kept the key")
+	}
+
+	// Touch restarts the TTL
+	c.Set("t", "v", 100*time.Millisecond)
+	time.Sleep(60 * time.Millisecond)
+	if ok, _ := c.Touch("t"); !ok {
+		t.Fatalf("Touch of a live key returned false")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if _, ok := c.Get("t"); !ok {
+		t.Fatalf("Touched key expired with its original deadline")
+	}
+	if ok, _ := c.Touch("missing"); ok {
+		t.Fatalf("Touch of a missing key returned true")
+	}
+}
+
+func TestExpireRecovery(t *testing.T) {
+	walDir := t.TempDir()
+
+	c, err := cache.NewLRUCache(10, walDir, false, 10*1024*1024, 10)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	large := strings.Repeat("x", 10000)
+	c.Set("expiring", large, 0)
+	c.Set("persisted", "v", time.Hour)
+	c.Set("gone", "v", 0)
+
+	before := walSize(t, walDir)
+	c.Expire("expiring", time.Hour)
+	// The record holds the new expiration, not the value
+	if grown := walSize(t, walDir) - before; grown > 256 {
+		t.Fatalf("Expire wrote %d bytes for a %d byte value", grown, len(large))
+	}
+	c.Persist("persisted")
+	c.Expire("gone", 50*time.Millisecond)
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	recovered, err := cache.NewLRUCache(10, walDir, false, 10*1024*1024, 10)
+	if err != nil {
+		t.Fatalf("Failed to recover cache: %v", err)
+	}
+	defer recovered.Close()
+
+	if ttl, ok := recovered.TTL("expiring"); !ok || ttl <= 59*time.Minute {
+		t.Errorf("TTL(expiring) = %v, %v", ttl, ok)
+	}
+	if value, _ := recovered.Get("expiring"); value != large {
+		t.Errorf("Expire changed the recovered value")
+	}
+	if ttl, ok := recovered.TTL("persisted"); !ok || ttl != 0 {
+		t.Errorf("TTL(persisted) = %v, %v", ttl, ok)
+	}
+	if _, ok := recovered.Get("gone"); ok {
+		t.Errorf("Key whose new expiration passed was recovered")
+	}
+}
+
+// walSize returns the combined size of the files in a WAL directory
+func walSize(t testing.TB, dir string) int64 {
+	t.Helper()
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read WAL directory: %v", err)
+	}
+	var size int64
+	for _, file := range files {
+		info, err := os.Stat(filepath.Join(dir, file.Name()))
+		if err != nil {
+			t.Fatalf("Failed to stat WAL file: %v", err)
+		}
+		size += info.Size()
+	}
+	return size
+}
+
+func TestExpireEndpoints(t *testing.T) {
+	c, err := cache.NewLRUCache(10, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+	c.Set("k", "v", 0)
+
+	srv := httptest.NewServer(server.New(c))
+	defer srv.Close()
+
+	future := time.Now().Add(2 * time.Hour).UTC().Format(time.RFC3339)
+	tests := []struct {
+		path     string
+		status   int
+		response string
+	}{
+		{"/expire?key=k", http.StatusBadRequest, "key and ttl are required"},
+		{"/expire?key=k&ttl=soon", http.StatusBadRequest, "Invalid TTL format"},
+		{"/expire?key=missing&ttl=1m", http.StatusNotFound, "Key not found"},
+		{"/expire?key=k&ttl=1h", http.StatusOK, "OK"},
+		{"/persist?key=k", http.StatusOK, `{"persisted":true}`},
+		{"/persist?key=k", http.StatusOK, `{"persisted":false}`},
+		{"/expireat?key=k&at=tomorrow", http.StatusBadRequest, "at must be an RFC 3339 time"},
+		{"/expireat?key=k&at=" + future, http.StatusOK, "OK"},
+		{"/touch?key=k", http.StatusOK, "OK"},
+		{"/touch?key=missing", http.StatusNotFound, "Key not found"},
+	}
+	for _, tt := range tests {
+		resp, err := http.Post(srv.URL+tt.path, "", nil)
+		if err != nil {
+			t.Fatalf("POST %s failed: %v", tt.path, err)
+		}
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tt.status || strings.TrimSpace(string(data)) != tt.response {
+			t.Errorf("POST %s = %d %q, want %d %q", tt.path, resp.StatusCode, data, tt.status, tt.response)
+		}
+	}
+
+	if ttl, _ := c.TTL("k"); ttl <= 119*time.Minute || ttl > 2*time.Hour {
+		t.Errorf("TTL after /expireat = %v", ttl)
+	}
+}
@@ -0,0 +1,239 @@
+package main_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/nishanth-gowda/kv-store/wal"
+)
+
+// errCrashed is returned by every write to a faultFS after its simulated crash
+var errCrashed = errors.New("simulated crash")
+
+// errSyncFailed is returned by the fsync a faultFS was told to fail
+var errSyncFailed = errors.New("simulated fsync failure")
+
+// faultFS is a wal.Storage that models what survives a power loss. Written
+// data is lost unless the file was synced, and creating, renaming and
+// removing files is lost unless the storage was synced. It counts every
+// operation that changes state so a test can crash at, or fail the fsync
+// of, any of them.
+type faultFS struct {
+	mu sync.Mutex
+	// files is what the running process sees, durable what survives a crash
+	files   map[string]*faultFile
+	durable map[string]*faultFile
+
+	ops int
+	// crashAt is the operation at which the power is lost; a write at that
+	// point is torn halfway. Zero never crashes.
+	crashAt int
+	// failSyncAt is the operation whose fsync fails without a crash
+	failSyncAt int
+	crashed    bool
+}
+
+// faultFile is the content of a file, of which the first synced bytes are durable
+type faultFile struct {
+	data   []byte
+	synced int
+}
+
+func newFaultFS() *faultFS {
+	return &faultFS{files: make(map[string]*faultFile), durable: make(map[string]*faultFile)}
+}
+
+// tailPolicy decides how much of the unsynced data of a file survives a crash
+type tailPolicy func(unsynced int) int
+
+var (
+	loseTail tailPolicy = func(int) int { return 0 }
+	keepTail tailPolicy = func(n int) int { return n }
+	tearTail tailPolicy = func(n int) int { return n / 2 }
+	// Records are synced whole, so unsynced data starts with a size prefix
+	tearPrefix tailPolicy = func(n int) int { return min(n, 2) }
+)
+
+// image returns the storage as it would be found after a power loss, with
+// each durable file holding its synced data and the part of the rest kept by tail
+func (f *faultFS) image(tail tailPolicy) *faultFS {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	image := newFaultFS()
+	for name, file := range f.durable {
+		n := file.synced + tail(len(file.data)-file.synced)
+		survivor := &faultFile{data: bytes.Clone(file.data[:n]), synced: n}
+		image.files[name] = survivor
+		image.durable[name] = survivor
+	}
+	return image
+}
+
+// step counts an operation that changes state and reports whether it may
+// proceed. The caller must hold f.mu.
+func (f *faultFS) step() error {
+	if f.crashed {
+		return errCrashed
+	}
+	f.ops++
+	if f.ops == f.crashAt {
+		f.crashed = true
+		return errCrashed
+	}
+	return nil
+}
+
+func (f *faultFS) List(prefix string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var names []string
+	for name := range f.files {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (f *faultFS) Create(name string) (wal.File, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.step(); err != nil {
+		return nil, err
+	}
+	file := &faultFile{}
+	f.files[name] = file
+	return &faultHandle{fs: f, file: file}, nil
+}
+
+func (f *faultFS) OpenAppend(name string) (wal.File, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, ok := f.files[name]
+	if !ok {
+		if err := f.step(); err != nil {
+			return nil, err
+		}
+		file = &faultFile{}
+		f.files[name] = file
+	}
+	return &faultHandle{fs: f, file: file}, nil
+}
+
+func (f *faultFS) OpenRead(name string) (io.ReadCloser, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, ok := f.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return io.NopCloser(bytes.NewReader(bytes.Clone(file.data))), nil
+}
+
+func (f *faultFS) Size(name string) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, ok := f.files[name]
+	if !ok {
+		return 0, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return int64(len(file.data)), nil
+}
+
+func (f *faultFS) Rename(oldName, newName string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.step(); err != nil {
+		return err
+	}
+	file, ok := f.files[oldName]
+	if !ok {
+		return &fs.PathError{Op: "rename", Path: oldName, Err: fs.ErrNotExist}
+	}
+	delete(f.files, oldName)
+	f.files[newName] = file
+	return nil
+}
+
+func (f *faultFS) Remove(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.step(); err != nil {
+		return err
+	}
+	if _, ok := f.files[name]; !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	delete(f.files, name)
+	return nil
+}
+
+// Sync makes the current set of files and their names durable
+func (f *faultFS) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.step(); err != nil {
+		return err
+	}
+	f.durable = make(map[string]*faultFile, len(f.files))
+	for name, file := range f.files {
+		f.durable[name] = file
+	}
+	return nil
+}
+
+// faultHandle is a file of a faultFS opened for writing
+type faultHandle struct {
+	fs   *faultFS
+	file *faultFile
+}
+
+func (h *faultHandle) Write(p []byte) (int, error) {
+	h.fs.mu.Lock()
+	defer h.fs.mu.Unlock()
+
+	crashedBefore := h.fs.crashed
+	if err := h.fs.step(); err != nil {
+		if !crashedBefore {
+			// The power went out halfway through the write
+			h.file.data = append(h.file.data, p[:len(p)/2]...)
+		}
+		return 0, err
+	}
+	h.file.data = append(h.file.data, p...)
+	return len(p), nil
+}
+
+func (h *faultHandle) Sync() error {
+	h.fs.mu.Lock()
+	defer h.fs.mu.Unlock()
+
+	if err := h.fs.step(); err != nil {
+		return err
+	}
+	if h.fs.ops == h.fs.failSyncAt {
+		return fmt.Errorf("sync: %w", errSyncFailed)
+	}
+	h.file.synced = len(h.file.data)
+	return nil
+}
+
+func (h *faultHandle) Close() error {
+	return nil
+}
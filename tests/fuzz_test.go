@@ -0,0 +1,179 @@
+package main_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/nishanth-gowda/kv-store/cache"
+	"github.com/nishanth-gowda/kv-store/wal"
+)
+
+// fuzzSegment is the name the segment fuzzed by FuzzWALSegment is stored under
+const fuzzSegment = "wal-segment-0"
+
+// writtenSegment returns the segment a WAL writes for a few entries, with
+// values above threshold compressed when it is positive
+func writtenSegment(tb testing.TB, threshold int) []byte {
+	tb.Helper()
+	storage := wal.NewMemoryStorage()
+	w, err := wal.NewWal("", false, 1<<20, 10, wal.WithStorage(storage), wal.WithCompression(threshold))
+	if err != nil {
+		tb.Fatalf("Failed to create WAL: %v", err)
+	}
+	w.Append(wal.EntryTypeSET, "a", []byte("short"), 0)
+	w.Append(wal.EntryTypeSET, "b", []byte(strings.Repeat("long ", 40)), 0)
+	w.Append(wal.EntryTypeDELETE, "a", nil, 0)
+	w.Append(wal.EntryTypeEXPIRE, "b", nil, 1234)
+	if err := w.Close(); err != nil {
+		tb.Fatalf("Failed to close WAL: %v", err)
+	}
+	return readFile(tb, storage, fuzzSegment)
+}
+
+// readFile returns the content of a file of storage
+func readFile(tb testing.TB, storage wal.Storage, name string) []byte {
+	tb.Helper()
+	r, err := storage.OpenRead(name)
+	if err != nil {
+		tb.Fatalf("Failed to open %s: %v", name, err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		tb.Fatalf("Failed to read %s: %v", name, err)
+	}
+	return data
+}
+
+// sizePrefix returns a record size prefix as the WAL writes it
+func sizePrefix(size int32) []byte {
+	return binary.LittleEndian.AppendUint32(nil, uint32(size))
+}
+
+// FuzzWALSegment opens a WAL over an arbitrary segment file. Whatever the
+// segment holds, recovery must not panic or allocate more than the file
+// holds, and a record appended afterwards must be readable after a restart.
+func FuzzWALSegment(f *testing.F) {
+	plain := writtenSegment(f, 0)
+	compressed := writtenSegment(f, 16)
+	f.Add([]byte{})
+	f.Add(plain)
+	f.Add(compressed)
+	f.Add(plain[:len(plain)-3])
+	f.Add(plain[:2])
+	f.Add(append(sizePrefix(-1), plain...))
+	f.Add(append(sizePrefix(0), plain...))
+	f.Add(append(bytes.Clone(plain), sizePrefix(1<<31-1)...))
+	f.Add(append(sizePrefix(8), "not a gob"...))
+
+	f.Fuzz(func(t *testing.T, segment []byte) {
+		storage := wal.NewMemoryStorage()
+		file, err := storage.Create(fuzzSegment)
+		if err != nil {
+			t.Fatalf("Failed to create segment: %v", err)
+		}
+		file.Write(segment)
+		file.Close()
+
+		w, err := wal.NewWal("", false, 1<<20, 10, wal.WithStorage(storage))
+		if err != nil {
+			return
+		}
+		recovered, err := w.ReadAll()
+		if err != nil {
+			// An intact record that cannot be unwrapped, which is reported
+			w.Close()
+			return
+		}
+		if err := w.Append(wal.EntryTypeSET, "fuzz", []byte("value"), 0); err != nil {
+			t.Fatalf("Failed to append: %v", err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Failed to close WAL: %v", err)
+		}
+
+		w, err = wal.NewWal("", false, 1<<20, 10, wal.WithStorage(storage))
+		if err != nil {
+			t.Fatalf("Failed to reopen WAL: %v", err)
+		}
+		defer w.Close()
+		entries, err := w.ReadAll()
+		if err != nil {
+			t.Fatalf("Failed to read WAL after appending: %v", err)
+		}
+		if len(entries) != len(recovered)+1 {
+			t.Fatalf("Read %d entries after appending to %d recovered ones", len(entries), len(recovered))
+		}
+		last := entries[len(entries)-1]
+		if last.Key != "fuzz" || string(last.Value) != "value" {
+			t.Fatalf("Last entry is %s=%q, want the appended fuzz=\"value\"", last.Key, last.Value)
+		}
+	})
+}
+
+// FuzzCacheOperations applies a sequence of Set, Delete, snapshot and
+// restart operations decoded from the input to a cache, and checks every
+// key against a map after each of them. Each byte is one operation: the
+// low bits pick it, the high bits the key and the length of the value, so
+// large values are compressed in the WAL and segments rotate.
+func FuzzCacheOperations(f *testing.F) {
+	f.Add([]byte{0x00, 0x13, 0x03, 0x22, 0x04, 0x03})
+	f.Add([]byte{0xf0, 0xe1, 0x04, 0xd0, 0x12, 0x03, 0xc5, 0x03})
+	f.Add([]byte(strings.Repeat("\xf0\xf1\x03\x04", 8)))
+
+	keys := []string{"a", "b", "c", "d"}
+	f.Fuzz(func(t *testing.T, ops []byte) {
+		storage := wal.NewMemoryStorage()
+		open := func() *cache.LRUCache {
+			c, err := cache.NewLRUCache(16, "", false, 512, 1000,
+				cache.WithWALStorage(storage), cache.WithWALCompression(64))
+			if err != nil {
+				t.Fatalf("Failed to open cache: %v", err)
+			}
+			return c
+		}
+		c := open()
+		defer func() { c.Close() }()
+
+		model := make(map[string]string)
+		for i, op := range ops {
+			key := keys[int(op>>4)%len(keys)]
+			var err error
+			var name string
+			switch op % 5 {
+			case 0, 1:
+				value := fmt.Sprintf("%d:%s", i, strings.Repeat("v", int(op>>2)*4))
+				name = fmt.Sprintf("Set(%s)", key)
+				err = c.Set(key, value, 0)
+				model[key] = value
+			case 2:
+				name = fmt.Sprintf("Delete(%s)", key)
+				err = c.Delete(key)
+				delete(model, key)
+			case 3:
+				name = "restart"
+				err = c.Close()
+				c = open()
+			case 4:
+				name = "Snapshot"
+				err = c.Snapshot()
+			}
+			if err != nil {
+				t.Fatalf("Operation %d, %s, failed: %v", i, name, err)
+			}
+
+			for _, key := range keys {
+				value, found := c.Get(key)
+				want, ok := model[key]
+				if found != ok || (ok && value != want) {
+					t.Fatalf("After operation %d, %s: %s = %.40v (found %t), want %.40q (found %t)",
+						i, name, key, value, found, want, ok)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,172 @@
+package main_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nishanth-gowda/kv-store/cache"
+	"github.com/nishanth-gowda/kv-store/server"
+)
+
+func TestHashOperations(t *testing.T) {
+	c, err := cache.NewLRUCache(10, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	if added, err := c.HSet("user:1", map[string]string{"name": "alice", "visits": "1"}); err != nil || added != 2 {
+		t.Fatalf("HSet = %d, %v", added, err)
+	}
+	if added, err := c.HSet("user:1", map[string]string{"name": "alicia", "city": "Paris"}); err != nil || added != 1 {
+		t.Fatalf("HSet update = %d, %v", added, err)
+	}
+	if value, ok, err := c.HGet("user:1", "name"); err != nil || !ok || value != "alicia" {
+		t.Fatalf("HGet(name) = %q, %v, %v", value, ok, err)
+	}
+	if _, ok, _ := c.HGet("user:1", "missing"); ok {
+		t.Fatalf("HGet found a missing field")
+	}
+	if value, err := c.HIncrBy("user:1", "visits", 41); err != nil || value != 42 {
+		t.Fatalf("HIncrBy = %d, %v", value, err)
+	}
+	if _, err := c.HIncrBy("user:1", "name", 1); !errors.Is(err, cache.ErrNotInteger) {
+		t.Fatalf("HIncrBy on a non-integer returned %v", err)
+	}
+
+	all, err := c.HGetAll("user:1")
+	if err != nil {
+		t.Fatalf("HGetAll failed: %v", err)
+	}
+	want := cache.Hash{"name": "alicia", "visits": "42", "city": "Paris"}
+	if !reflect.DeepEqual(all, want) {
+		t.Fatalf("HGetAll = %v, want %v", all, want)
+	}
+
+	// The returned hash is a copy
+	all["name"] = "mallory"
+	if value, _, _ := c.HGet("user:1", "name"); value != "alicia" {
+		t.Fatalf("modifying HGetAll result changed the cache")
+	}
+
+	if removed, err := c.HDel("user:1", "name", "city", "missing"); err != nil || removed != 2 {
+		t.Fatalf("HDel = %d, %v", removed, err)
+	}
+	if removed, _ := c.HDel("user:1", "visits"); removed != 1 {
+		t.Fatalf("HDel(visits) = %d", removed)
+	}
+	if _, ok := c.Get("user:1"); ok {
+		t.Fatalf("hash still exists after its last field was removed")
+	}
+
+	c.Set("plain", "value", 0)
+	if _, err := c.HSet("plain", map[string]string{"f": "v"}); !errors.Is(err, cache.ErrWrongType) {
+		t.Fatalf("HSet on a string returned %v", err)
+	}
+	if _, _, err := c.HGet("plain", "f"); !errors.Is(err, cache.ErrWrongType) {
+		t.Fatalf("HGet on a string returned %v", err)
+	}
+}
+
+func TestHashRecovery(t *testing.T) {
+	walDir := t.TempDir()
+
+	c, err := cache.NewLRUCache(10, walDir, false, 10*1024*1024, 10)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	c.HSet("profile", map[string]string{"name": "bob", "age": "30", "tmp": "x"})
+	c.HIncrBy("profile", "age", 1)
+	c.HDel("profile", "tmp")
+	c.HSet("gone", map[string]string{"f": "v"})
+	c.HDel("gone", "f")
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	recovered, err := cache.NewLRUCache(10, walDir, false, 10*1024*1024, 10)
+	if err != nil {
+		t.Fatalf("Failed to recover cache: %v", err)
+	}
+	want := cache.Hash{"name": "bob", "age": "31"}
+	if all, _ := recovered.HGetAll("profile"); !reflect.DeepEqual(all, want) {
+		t.Fatalf("recovered hash = %v, want %v", all, want)
+	}
+	if _, ok := recovered.Get("gone"); ok {
+		t.Fatalf("emptied hash was recovered")
+	}
+
+	// Hashes survive a snapshot as whole values
+	if err := recovered.Snapshot(); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	recovered.HSet("profile", map[string]string{"city": "Oslo"})
+	if err := recovered.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	again, err := cache.NewLRUCache(10, walDir, false, 10*1024*1024, 10)
+	if err != nil {
+		t.Fatalf("Failed to recover cache: %v", err)
+	}
+	defer again.Close()
+	want["city"] = "Oslo"
+	if all, _ := again.HGetAll("profile"); !reflect.DeepEqual(all, want) {
+		t.Fatalf("hash recovered from snapshot = %v, want %v", all, want)
+	}
+}
+
+func TestHashEndpoints(t *testing.T) {
+	c, err := cache.NewLRUCache(10, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	srv := httptest.NewServer(server.New(c))
+	defer srv.Close()
+
+	request := func(method, path, body string) (int, string) {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, strings.TrimSpace(string(data))
+	}
+
+	tests := []struct {
+		method, path, body string
+		status             int
+		response           string
+	}{
+		{http.MethodPost, "/hset?key=h", `{"a":"1","b":"2"}`, http.StatusOK, `{"added":2}`},
+		{http.MethodGet, "/hget?key=h&field=a", "", http.StatusOK, "1"},
+		{http.MethodGet, "/hget?key=h&field=z", "", http.StatusNotFound, "Field not found"},
+		{http.MethodPost, "/hincrby?key=h&field=a&by=9", "", http.StatusOK, `{"value":10}`},
+		{http.MethodDelete, "/hdel?key=h&field=b&field=z", "", http.StatusOK, `{"removed":1}`},
+		{http.MethodGet, "/hgetall?key=h", "", http.StatusOK, `{"a":"10"}`},
+		{http.MethodGet, "/hgetall?key=missing", "", http.StatusOK, `{}`},
+		{http.MethodGet, "/get?key=h", "", http.StatusBadRequest, "key does not hold a string value"},
+		{http.MethodPost, "/hset?key=h", `not json`, http.StatusBadRequest, "body must be a JSON object of field/value pairs"},
+	}
+	for _, tt := range tests {
+		status, body := request(tt.method, tt.path, tt.body)
+		if status != tt.status || body != tt.response {
+			t.Errorf("%s %s = %d %q, want %d %q", tt.method, tt.path, status, body, tt.status, tt.response)
+		}
+	}
+
+	c.Set("s", "v", 0)
+	if status, _ := request(http.MethodPost, "/hset?key=s", `{"f":"v"}`); status != http.StatusBadRequest {
+		t.Errorf("HSET on a string returned %d, want 400", status)
+	}
+}
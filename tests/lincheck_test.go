@@ -0,0 +1,277 @@
+package main_test
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// opKind is the operation a client performed on a key
+type opKind int
+
+const (
+	opGet opKind = iota
+	opSet
+	opDelete
+)
+
+// unknownReturn is the return time of a write whose outcome the client
+// never learned. It may take effect at any time after its call, or never.
+const unknownReturn = math.MaxInt64
+
+// registerOp is one client operation on a key, called and returned at
+// nanoseconds since the start of the run
+type registerOp struct {
+	client int
+	kind   opKind
+	key    string
+	// value is written by a set, or read by a get that found the key
+	value string
+	found bool
+	call  int64
+	ret   int64
+}
+
+func (op registerOp) String() string {
+	var s string
+	switch op.kind {
+	case opGet:
+		s = fmt.Sprintf("get(%s) -> not found", op.key)
+		if op.found {
+			s = fmt.Sprintf("get(%s) -> %q", op.key, op.value)
+		}
+	case opSet:
+		s = fmt.Sprintf("set(%s, %q)", op.key, op.value)
+	case opDelete:
+		s = fmt.Sprintf("delete(%s)", op.key)
+	}
+	ret := "unknown"
+	if op.ret != unknownReturn {
+		ret = time.Duration(op.ret).String()
+	}
+	return fmt.Sprintf("client %d: %-32s called %s, returned %s", op.client, s, time.Duration(op.call), ret)
+}
+
+// registerState is the value of a key, which starts absent
+type registerState struct {
+	value   string
+	present bool
+}
+
+// apply returns the state after op, and whether op could have observed s
+func (s registerState) apply(op registerOp) (registerState, bool) {
+	switch op.kind {
+	case opGet:
+		return s, op.found == s.present && op.value == s.value
+	case opSet:
+		return registerState{value: op.value, present: true}, true
+	default:
+		return registerState{}, true
+	}
+}
+
+// linearizable reports whether ops, all on one key and sorted by call
+// time, could have taken effect one at a time, each between its call and
+// return, on a register that starts absent. It searches the orders that
+// respect real time, remembering the sets of operations and states already
+// found to be dead ends.
+func linearizable(ops []registerOp) bool {
+	search := &linearizationSearch{
+		ops:      ops,
+		done:     make([]uint64, (len(ops)+63)/64),
+		deadEnds: make(map[string]struct{}),
+	}
+	return search.run(len(ops), registerState{})
+}
+
+type linearizationSearch struct {
+	ops      []registerOp
+	done     []uint64
+	deadEnds map[string]struct{}
+}
+
+func (s *linearizationSearch) isDone(i int) bool {
+	return s.done[i/64]&(1<<(i%64)) != 0
+}
+
+func (s *linearizationSearch) flip(i int) {
+	s.done[i/64] ^= 1 << (i % 64)
+}
+
+func (s *linearizationSearch) run(remaining int, state registerState) bool {
+	if remaining == 0 {
+		return true
+	}
+
+	var key strings.Builder
+	for _, word := range s.done {
+		fmt.Fprintf(&key, "%x.", word)
+	}
+	fmt.Fprintf(&key, "%t:%s", state.present, state.value)
+	if _, ok := s.deadEnds[key.String()]; ok {
+		return false
+	}
+
+	// Only an operation called before every remaining one returned can take effect next
+	deadline := int64(unknownReturn)
+	for i, op := range s.ops {
+		if !s.isDone(i) {
+			deadline = min(deadline, op.ret)
+		}
+	}
+	for i, op := range s.ops {
+		if op.call > deadline {
+			break
+		}
+		if s.isDone(i) {
+			continue
+		}
+		next, ok := state.apply(op)
+		if !ok {
+			continue
+		}
+		s.flip(i)
+		found := s.run(remaining-1, next)
+		s.flip(i)
+		if found {
+			return true
+		}
+	}
+
+	s.deadEnds[key.String()] = struct{}{}
+	return false
+}
+
+// checkHistory checks the operations on each key separately, which is
+// enough since linearizability is local. It returns a minimal violating
+// history of the first key that is not linearizable, or nil.
+func checkHistory(history []registerOp) []registerOp {
+	byKey := make(map[string][]registerOp)
+	for _, op := range history {
+		byKey[op.key] = append(byKey[op.key], op)
+	}
+	keys := make([]string, 0, len(byKey))
+	for key := range byKey {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		ops := byKey[key]
+		sort.SliceStable(ops, func(i, j int) bool { return ops[i].call < ops[j].call })
+		if !linearizable(ops) {
+			return minimizeHistory(ops)
+		}
+	}
+	return nil
+}
+
+// minimizeHistory drops operations from a history that is not
+// linearizable for as long as it stays so, until no single operation can
+// be dropped. Writes whose value a remaining read returned are kept, so
+// the result explains where each read value came from.
+func minimizeHistory(ops []registerOp) []registerOp {
+	for shrunk := true; shrunk; {
+		shrunk = false
+		for i := 0; i < len(ops); i++ {
+			if observed(ops, i) {
+				continue
+			}
+			candidate := slices.Delete(slices.Clone(ops), i, i+1)
+			if !linearizable(candidate) {
+				ops, shrunk = candidate, true
+				i--
+			}
+		}
+	}
+	return ops
+}
+
+// observed reports whether ops[i] is a set whose value a get in ops returned
+func observed(ops []registerOp, i int) bool {
+	if ops[i].kind != opSet {
+		return false
+	}
+	for _, op := range ops {
+		if op.kind == opGet && op.found && op.value == ops[i].value {
+			return true
+		}
+	}
+	return false
+}
+
+// formatHistory lists operations one per line for failure messages
+func formatHistory(ops []registerOp) string {
+	lines := make([]string, len(ops))
+	for i, op := range ops {
+		lines[i] = "  " + op.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestLinearizabilityChecker(t *testing.T) {
+	set := func(client int, value string, call, ret int64) registerOp {
+		return registerOp{client: client, kind: opSet, key: "k", value: value, call: call, ret: ret}
+	}
+	get := func(client int, value string, call, ret int64) registerOp {
+		return registerOp{client: client, kind: opGet, key: "k", value: value, found: value != "", call: call, ret: ret}
+	}
+	del := func(client int, call, ret int64) registerOp {
+		return registerOp{client: client, kind: opDelete, key: "k", call: call, ret: ret}
+	}
+
+	tests := []struct {
+		name    string
+		history []registerOp
+		// minimal is the violating history expected, nil if linearizable
+		minimal []registerOp
+	}{
+		{
+			name:    "sequential",
+			history: []registerOp{get(1, "", 0, 1), set(1, "a", 2, 3), get(2, "a", 4, 5), del(2, 6, 7), get(1, "", 8, 9)},
+		},
+		{
+			name:    "concurrent writes in either order",
+			history: []registerOp{set(1, "a", 0, 10), set(2, "b", 1, 9), get(3, "a", 11, 12), get(3, "a", 13, 14)},
+		},
+		{
+			name:    "read overlapping a write sees either value",
+			history: []registerOp{set(1, "a", 0, 1), set(2, "b", 2, 10), get(3, "a", 3, 4), get(3, "b", 5, 6), get(4, "b", 11, 12)},
+		},
+		{
+			name:    "unknown write may take effect late",
+			history: []registerOp{set(1, "a", 0, unknownReturn), get(2, "", 1, 2), get(2, "a", 100, 101)},
+		},
+		{
+			name:    "unknown write may never take effect",
+			history: []registerOp{set(1, "a", 0, unknownReturn), get(2, "", 100, 101)},
+		},
+		{
+			name:    "stale read",
+			history: []registerOp{get(3, "", 0, 1), set(1, "a", 2, 3), set(2, "b", 4, 5), get(3, "b", 6, 7), get(1, "a", 8, 9)},
+			minimal: []registerOp{set(1, "a", 2, 3), set(2, "b", 4, 5), get(1, "a", 8, 9)},
+		},
+		{
+			name:    "value flips back",
+			history: []registerOp{set(1, "a", 0, 1), set(2, "b", 2, 20), get(3, "b", 3, 4), get(3, "a", 5, 6)},
+			minimal: []registerOp{set(1, "a", 0, 1), set(2, "b", 2, 20), get(3, "b", 3, 4), get(3, "a", 5, 6)},
+		},
+		{
+			name:    "read of a deleted key",
+			history: []registerOp{set(1, "a", 0, 1), get(2, "a", 2, 3), del(1, 4, 5), get(2, "a", 6, 7)},
+			minimal: []registerOp{set(1, "a", 0, 1), del(1, 4, 5), get(2, "a", 6, 7)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := checkHistory(tt.history)
+			if !slices.Equal(got, tt.minimal) {
+				t.Errorf("checkHistory returned\n%s\nwant\n%s", formatHistory(got), formatHistory(tt.minimal))
+			}
+		})
+	}
+}
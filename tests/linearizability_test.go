@@ -0,0 +1,231 @@
+package main_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nishanth-gowda/kv-store/cache"
+	"github.com/nishanth-gowda/kv-store/client"
+	"github.com/nishanth-gowda/kv-store/server"
+)
+
+// historyRecorder collects the operations of concurrent clients
+type historyRecorder struct {
+	start time.Time
+	mu    sync.Mutex
+	ops   []registerOp
+}
+
+func newHistoryRecorder() *historyRecorder {
+	return &historyRecorder{start: time.Now()}
+}
+
+func (r *historyRecorder) now() int64 {
+	return int64(time.Since(r.start))
+}
+
+func (r *historyRecorder) record(op registerOp) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ops = append(r.ops, op)
+}
+
+// runRegisterClients has each client send random /get, /set and /delete
+// requests for keys and records them. Every value written is unique, so a
+// read names the write it observed.
+func runRegisterClients(t *testing.T, kv *client.Client, rec *historyRecorder, clients, opsPerClient int, keys []string) {
+	t.Helper()
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for id := 0; id < clients; id++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(int64(id)))
+			for i := 0; i < opsPerClient; i++ {
+				op := registerOp{client: id, key: keys[rng.Intn(len(keys))]}
+				op.call = rec.now()
+				var err error
+				switch n := rng.Intn(10); {
+				case n < 5:
+					op.kind = opGet
+					op.value, err = kv.Get(ctx, op.key)
+					op.found = err == nil
+					if errors.Is(err, client.ErrNotFound) {
+						err = nil
+					}
+				case n < 9:
+					op.kind = opSet
+					op.value = fmt.Sprintf("c%d-%d", id, i)
+					err = kv.Set(ctx, op.key, op.value, 0)
+				default:
+					op.kind = opDelete
+					err = kv.Delete(ctx, op.key)
+				}
+				op.ret = rec.now()
+
+				if err != nil {
+					if op.kind == opGet {
+						// A failed read observed nothing
+						continue
+					}
+					op.ret = unknownReturn
+				}
+				rec.record(op)
+			}
+		}(id)
+	}
+	wg.Wait()
+}
+
+// restartableServer serves the real handlers over a cache that can be
+// restarted from its WAL while clients keep sending requests. Requests in
+// flight during a restart fail with 503, as if the connection had been
+// lost, so their outcome is unknown to the client.
+type restartableServer struct {
+	walDir string
+
+	mu         sync.RWMutex
+	cache      *cache.LRUCache
+	handler    http.Handler
+	generation int
+	inflight   sync.WaitGroup
+}
+
+func newRestartableServer(t *testing.T) *restartableServer {
+	t.Helper()
+	s := &restartableServer{walDir: t.TempDir()}
+	if err := s.open(); err != nil {
+		t.Fatalf("Failed to open cache: %v", err)
+	}
+	t.Cleanup(func() { s.cache.Close() })
+	return s
+}
+
+// open recovers the cache from the WAL, which fsyncs every write so
+// acknowledged writes survive a restart
+func (s *restartableServer) open() error {
+	c, err := cache.NewLRUCache(1000, s.walDir, true, 10*1024*1024, 10)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.cache, s.handler = c, server.New(c)
+	s.mu.Unlock()
+	return nil
+}
+
+// restart stops the cache once the requests in flight have finished, and
+// recovers a new one from the WAL
+func (s *restartableServer) restart() error {
+	s.mu.Lock()
+	s.handler = nil
+	s.generation++
+	old := s.cache
+	s.mu.Unlock()
+
+	s.inflight.Wait()
+	if err := old.Close(); err != nil {
+		return err
+	}
+	return s.open()
+}
+
+func (s *restartableServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	handler, generation := s.handler, s.generation
+	if handler != nil {
+		s.inflight.Add(1)
+	}
+	s.mu.RUnlock()
+	if handler == nil {
+		http.Error(w, "restarting", http.StatusServiceUnavailable)
+		return
+	}
+	defer s.inflight.Done()
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, r)
+
+	s.mu.RLock()
+	restarted := s.generation != generation
+	s.mu.RUnlock()
+	if restarted {
+		http.Error(w, "restarted", http.StatusServiceUnavailable)
+		return
+	}
+	for name, values := range recorder.Header() {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(recorder.Code)
+	w.Write(recorder.Body.Bytes())
+}
+
+// checkLinearizable fails the test with a minimal violating history, if any
+func checkLinearizable(t *testing.T, rec *historyRecorder) {
+	t.Helper()
+	if violation := checkHistory(rec.ops); violation != nil {
+		t.Fatalf("History of %d operations is not linearizable; minimal violating history:\n%s",
+			len(rec.ops), formatHistory(violation))
+	}
+}
+
+func TestLinearizableServer(t *testing.T) {
+	srv := httptest.NewServer(newRestartableServer(t))
+	defer srv.Close()
+	// Retries could apply a write twice, so every failure is recorded as is
+	kv, err := client.New(srv.URL, client.WithRetries(0, 0, 0))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	rec := newHistoryRecorder()
+	runRegisterClients(t, kv, rec, 8, 150, []string{"a", "b", "c"})
+	checkLinearizable(t, rec)
+}
+
+func TestLinearizableServerWithRestarts(t *testing.T) {
+	s := newRestartableServer(t)
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	kv, err := client.New(srv.URL, client.WithRetries(0, 0, 0))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	// Restart every 20ms until the clients are done
+	done := make(chan struct{})
+	restarted := make(chan int, 1)
+	go func() {
+		n := 0
+		defer func() { restarted <- n }()
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(20 * time.Millisecond):
+			}
+			if err := s.restart(); err != nil {
+				t.Errorf("Restart failed: %v", err)
+				return
+			}
+			n++
+		}
+	}()
+
+	rec := newHistoryRecorder()
+	runRegisterClients(t, kv, rec, 8, 150, []string{"a", "b", "c"})
+	close(done)
+	if n := <-restarted; n == 0 {
+		t.Fatalf("Server was not restarted while the clients ran")
+	}
+	checkLinearizable(t, rec)
+}
@@ -0,0 +1,244 @@
+package main_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nishanth-gowda/kv-store/cache"
+	"github.com/nishanth-gowda/kv-store/server"
+)
+
+func TestListOperations(t *testing.T) {
+	c, err := cache.NewLRUCache(10, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	if n, err := c.RPush("q", "b", "c"); err != nil || n != 2 {
+		t.Fatalf("RPush = %d, %v", n, err)
+	}
+	if n, err := c.LPush("q", "a", "z"); err != nil || n != 4 {
+		t.Fatalf("LPush = %d, %v", n, err)
+	}
+
+	tests := []struct {
+		start, stop int
+		want        []string
+	}{
+		{0, -1, []string{"z", "a", "b", "c"}},
+		{1, 2, []string{"a", "b"}},
+		{-2, 100, []string{"b", "c"}},
+		{3, 1, []string{}},
+		{-100, 0, []string{"z"}},
+	}
+	for _, tt := range tests {
+		got, err := c.LRange("q", tt.start, tt.stop)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("LRange(%d, %d) = %v, %v, want %v", tt.start, tt.stop, got, err, tt.want)
+		}
+	}
+
+	if value, ok, err := c.LPop("q"); err != nil || !ok || value != "z" {
+		t.Fatalf("LPop = %q, %v, %v", value, ok, err)
+	}
+	if value, ok, err := c.RPop("q"); err != nil || !ok || value != "c" {
+		t.Fatalf("RPop = %q, %v, %v", value, ok, err)
+	}
+	if n, _ := c.LLen("q"); n != 2 {
+		t.Fatalf("LLen = %d, want 2", n)
+	}
+
+	c.LPop("q")
+	c.LPop("q")
+	if _, ok := c.Get("q"); ok {
+		t.Fatalf("list still exists after its last element was popped")
+	}
+	if _, ok, err := c.LPop("q"); ok || err != nil {
+		t.Fatalf("LPop on a missing list = %v, %v", ok, err)
+	}
+
+	c.Set("s", "v", 0)
+	if _, err := c.RPush("s", "x"); !errors.Is(err, cache.ErrWrongType) {
+		t.Fatalf("RPush on a string returned %v", err)
+	}
+}
+
+func TestBlockingPop(t *testing.T) {
+	c, err := cache.NewLRUCache(10, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	// Returns immediately when a list already has elements, checking keys in order
+	c.RPush("b", "from-b")
+	key, value, ok, err := c.BLPop(context.Background(), time.Second, "a", "b")
+	if err != nil || !ok || key != "b" || value != "from-b" {
+		t.Fatalf("BLPop = %q, %q, %v, %v", key, value, ok, err)
+	}
+
+	// Times out when nothing is pushed
+	start := time.Now()
+	if _, _, ok, err := c.BLPop(context.Background(), 20*time.Millisecond, "a"); ok || err != nil {
+		t.Fatalf("BLPop on empty lists = %v, %v", ok, err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Fatalf("BLPop returned after %v, before the timeout", elapsed)
+	}
+
+	// Wakes up when another goroutine pushes
+	type popped struct {
+		key, value string
+		ok         bool
+		err        error
+	}
+	results := make(chan popped)
+	for i := 0; i < 2; i++ {
+		go func() {
+			key, value, ok, err := c.BLPop(context.Background(), 0, "jobs")
+			results <- popped{key, value, ok, err}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	c.RPush("jobs", "job-1", "job-2")
+
+	got := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		select {
+		case r := <-results:
+			if r.err != nil || !r.ok || r.key != "jobs" {
+				t.Fatalf("blocked BLPop = %+v", r)
+			}
+			got[r.value] = true
+		case <-time.After(time.Second):
+			t.Fatalf("blocked BLPop did not wake up")
+		}
+	}
+	if !got["job-1"] || !got["job-2"] {
+		t.Fatalf("blocked pops got %v", got)
+	}
+
+	// Close releases blocked pops
+	go func() {
+		_, _, _, err := c.BLPop(context.Background(), 0, "jobs")
+		results <- popped{err: err}
+	}()
+	time.Sleep(10 * time.Millisecond)
+	c.Close()
+	select {
+	case r := <-results:
+		if !errors.Is(r.err, cache.ErrClosed) {
+			t.Fatalf("BLPop after Close returned %v", r.err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Close did not release a blocked BLPop")
+	}
+}
+
+func TestListRecovery(t *testing.T) {
+	walDir := t.TempDir()
+
+	c, err := cache.NewLRUCache(10, walDir, false, 10*1024*1024, 10)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	c.RPush("queue", "1", "2", "3")
+	c.LPush("queue", "0")
+	c.LPop("queue")
+	c.RPop("queue")
+	c.RPush("queue", "4")
+	c.BLPop(context.Background(), time.Second, "queue")
+	c.RPush("drained", "x")
+	c.LPop("drained")
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	recovered, err := cache.NewLRUCache(10, walDir, false, 10*1024*1024, 10)
+	if err != nil {
+		t.Fatalf("Failed to recover cache: %v", err)
+	}
+	defer recovered.Close()
+
+	if got, _ := recovered.LRange("queue", 0, -1); !reflect.DeepEqual(got, []string{"2", "4"}) {
+		t.Fatalf("recovered list = %v, want [2 4]", got)
+	}
+	if _, ok := recovered.Get("drained"); ok {
+		t.Fatalf("drained list was recovered")
+	}
+}
+
+func TestListEndpoints(t *testing.T) {
+	c, err := cache.NewLRUCache(10, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	srv := httptest.NewServer(server.New(c))
+	defer srv.Close()
+
+	request := func(method, path string) (int, string) {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL+path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, strings.TrimSpace(string(data))
+	}
+
+	tests := []struct {
+		method, path string
+		status       int
+		response     string
+	}{
+		{http.MethodPost, "/rpush?key=q&value=a&value=b", http.StatusOK, `{"length":2}`},
+		{http.MethodPost, "/lpush?key=q&value=z", http.StatusOK, `{"length":3}`},
+		{http.MethodGet, "/lrange?key=q", http.StatusOK, `["z","a","b"]`},
+		{http.MethodGet, "/lrange?key=q&start=1&stop=1", http.StatusOK, `["a"]`},
+		{http.MethodGet, "/llen?key=q", http.StatusOK, `{"length":3}`},
+		{http.MethodPost, "/lpop?key=q", http.StatusOK, "z"},
+		{http.MethodPost, "/rpop?key=q", http.StatusOK, "b"},
+		{http.MethodPost, "/blpop?key=empty&key=q&timeout=1s", http.StatusOK, `{"key":"q","value":"a"}`},
+		{http.MethodPost, "/lpop?key=q", http.StatusNotFound, "List is empty"},
+		{http.MethodPost, "/blpop?key=q&timeout=10ms", http.StatusNotFound, "Timed out waiting for an element"},
+		{http.MethodPost, "/blpop?key=q&timeout=soon", http.StatusBadRequest, "Invalid timeout format"},
+	}
+	for _, tt := range tests {
+		status, body := request(tt.method, tt.path)
+		if status != tt.status || body != tt.response {
+			t.Errorf("%s %s = %d %q, want %d %q", tt.method, tt.path, status, body, tt.status, tt.response)
+		}
+	}
+
+	// A blocked request is served by a later push
+	result := make(chan server.BlockingPopResponse, 1)
+	go func() {
+		resp, err := http.Post(srv.URL+"/blpop?key=jobs&timeout=5s", "", nil)
+		if err != nil {
+			close(result)
+			return
+		}
+		defer resp.Body.Close()
+		var popped server.BlockingPopResponse
+		json.NewDecoder(resp.Body).Decode(&popped)
+		result <- popped
+	}()
+	time.Sleep(20 * time.Millisecond)
+	request(http.MethodPost, "/rpush?key=jobs&value=work")
+	if popped := <-result; popped.Value != "work" {
+		t.Fatalf("blocked /blpop got %+v", popped)
+	}
+}
@@ -0,0 +1,317 @@
+package main_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nishanth-gowda/kv-store/cache"
+)
+
+func TestLoaderSingleflight(t *testing.T) {
+	c, err := cache.NewLRUCache(10, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	c.SetLoader(cache.LoaderFunc(func(ctx context.Context, key string) (any, time.Duration, error) {
+		calls.Add(1)
+		<-release
+		return "loaded-" + key, 0, nil
+	}))
+
+	var wg sync.WaitGroup
+	results := make([]any, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = c.Get("k")
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("Loader called %d times for concurrent misses, want 1", n)
+	}
+	for i, value := range results {
+		if value != "loaded-k" {
+			t.Fatalf("Reader %d got %v", i, value)
+		}
+	}
+	// The loaded value is cached
+	if _, ok := c.Get("k"); !ok || calls.Load() != 1 {
+		t.Fatalf("Loaded value was not cached")
+	}
+}
+
+func TestLoaderErrors(t *testing.T) {
+	c, err := cache.NewLRUCache(10, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	if _, err := c.GetContext(context.Background(), "k"); !errors.Is(err, cache.ErrNotFound) {
+		t.Fatalf("GetContext without a loader returned %v", err)
+	}
+
+	var calls atomic.Int32
+	c.SetLoader(cache.LoaderFunc(func(ctx context.Context, key string) (any, time.Duration, error) {
+		calls.Add(1)
+		return nil, 0, cache.ErrNotFound
+	}), cache.WithErrorTTL(100*time.Millisecond))
+
+	for range 3 {
+		if _, err := c.GetContext(context.Background(), "k"); !errors.Is(err, cache.ErrNotFound) {
+			t.Fatalf("GetContext of a missing key returned %v", err)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("Loader called %d times while its error was cached, want 1", n)
+	}
+
+	// Writing the key forgets the cached error
+	c.Set("k", "v", 0)
+	c.Delete("k")
+	c.Get("k")
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("Loader called %d times after the key was written, want 2", n)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	c.Get("k")
+	if n := calls.Load(); n != 3 {
+		t.Fatalf("Loader called %d times after the error TTL, want 3", n)
+	}
+}
+
+func TestLoaderContext(t *testing.T) {
+	c, err := cache.NewLRUCache(10, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	release := make(chan struct{})
+	c.SetLoader(cache.LoaderFunc(func(ctx context.Context, key string) (any, time.Duration, error) {
+		<-release
+		return "loaded", 0, nil
+	}))
+
+	// A reader giving up does not cancel the load
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.GetContext(ctx, "k"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("GetContext with an expired context returned %v", err)
+	}
+	close(release)
+	if value, err := c.GetContext(context.Background(), "k"); err != nil || value != "loaded" {
+		t.Fatalf("GetContext after the load = %v, %v", value, err)
+	}
+}
+
+func TestLoaderDoesNotOverwriteWrites(t *testing.T) {
+	c, err := cache.NewLRUCache(10, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	c.SetLoader(cache.LoaderFunc(func(ctx context.Context, key string) (any, time.Duration, error) {
+		close(started)
+		<-release
+		return "stale", 0, nil
+	}))
+
+	done := make(chan any)
+	go func() {
+		value, _ := c.Get("k")
+		done <- value
+	}()
+	<-started
+	c.Set("k", "fresh", 0)
+	close(release)
+	<-done
+
+	if value, _ := c.Get("k"); value != "fresh" {
+		t.Fatalf("Load finishing after Set replaced the value with %v", value)
+	}
+}
+
+func TestLoaderRefreshAhead(t *testing.T) {
+	c, err := cache.NewLRUCache(10, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	var calls atomic.Int32
+	c.SetLoader(cache.LoaderFunc(func(ctx context.Context, key string) (any, time.Duration, error) {
+		return int(calls.Add(1)), 200 * time.Millisecond, nil
+	}), cache.WithRefreshAhead(150*time.Millisecond))
+
+	if value, _ := c.Get("k"); value != 1 {
+		t.Fatalf("First load = %v, want 1", value)
+	}
+	// Plenty of TTL left: no refresh
+	c.Get("k")
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("Loader called %d times before the refresh window", n)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	// Within the window the old value is served while it is reloaded
+	if value, _ := c.Get("k"); value != 1 {
+		t.Fatalf("Read in the refresh window = %v, want the cached 1", value)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		if value, _ := c.Get("k"); value == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Entry was not refreshed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// recordingWriter records the writes it receives, failing while fail is set
+type recordingWriter struct {
+	mu   sync.Mutex
+	ops  []string
+	fail bool
+}
+
+func (w *recordingWriter) Write(ctx context.Context, key string, value any) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.fail {
+		return errors.New("store unavailable")
+	}
+	w.ops = append(w.ops, "set "+key+"="+value.(string))
+	return nil
+}
+
+func (w *recordingWriter) Delete(ctx context.Context, key string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.fail {
+		return errors.New("store unavailable")
+	}
+	w.ops = append(w.ops, "del "+key)
+	return nil
+}
+
+func (w *recordingWriter) setFail(fail bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.fail = fail
+}
+
+func (w *recordingWriter) recorded() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string(nil), w.ops...)
+}
+
+func TestWriteThrough(t *testing.T) {
+	c, err := cache.NewLRUCache(10, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	w := &recordingWriter{}
+	c.SetWriter(w)
+
+	c.Set("a", "1", 0)
+	if swapped, err := c.CompareAndSwap("a", "1", "2", 0); !swapped || err != nil {
+		t.Fatalf("CompareAndSwap = %v, %v", swapped, err)
+	}
+	c.CompareAndSwap("a", "1", "3", 0)
+	c.Delete("a")
+
+	want := []string{"set a=1", "set a=2", "del a"}
+	if got := w.recorded(); !slices.Equal(got, want) {
+		t.Fatalf("Backing store received %v, want %v", got, want)
+	}
+
+	// A failed write leaves the cache unchanged
+	c.Set("b", "1", 0)
+	w.setFail(true)
+	if err := c.Set("b", "2", 0); err == nil {
+		t.Fatalf("Set succeeded although the backing store failed")
+	}
+	if err := c.Delete("b"); err == nil {
+		t.Fatalf("Delete succeeded although the backing store failed")
+	}
+	if value, _ := c.Get("b"); value != "1" {
+		t.Fatalf("Failed writes changed the cached value to %v", value)
+	}
+}
+
+func TestWriteBehind(t *testing.T) {
+	c, err := cache.NewLRUCache(10, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+
+	w := &recordingWriter{}
+	var failed []string
+	var failedMu sync.Mutex
+	c.SetWriter(w, cache.WithWriteBehind(4, func(key string, err error) {
+		failedMu.Lock()
+		defer failedMu.Unlock()
+		failed = append(failed, key)
+	}))
+
+	var want []string
+	for _, value := range []string{"1", "2", "3", "4", "5", "6"} {
+		if err := c.Set("k", value, 0); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+		want = append(want, "set k="+value)
+	}
+	c.Delete("k")
+	want = append(want, "del k")
+
+	// Close waits for the queue to drain
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if got := w.recorded(); !slices.Equal(got, want) {
+		t.Fatalf("Backing store received %v, want %v", got, want)
+	}
+
+	// Failures are reported to the callback
+	c, err = cache.NewLRUCache(10, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	w.setFail(true)
+	c.SetWriter(w, cache.WithWriteBehind(4, func(key string, err error) {
+		failedMu.Lock()
+		defer failedMu.Unlock()
+		failed = append(failed, key)
+	}))
+	if err := c.Set("x", "1", 0); err != nil {
+		t.Fatalf("Write-behind Set returned the store's error: %v", err)
+	}
+	c.Close()
+	if len(failed) != 1 || failed[0] != "x" {
+		t.Fatalf("Error callback received %v, want [x]", failed)
+	}
+}
@@ -472,3 +472,76 @@ func BenchmarkLRUEvictionWithWAL(b *testing.B) {
 		}
 	})
 }
+
+// BenchmarkSetCompressibleWithWAL benchmarks Set operations with 4KB JSON
+// values and WAL, reporting the WAL bytes written per value
+func BenchmarkSetCompressibleWithWAL(b *testing.B) {
+	benchmarkSetCompressible(b)
+}
+
+// BenchmarkSetCompressibleWithWALCompression benchmarks the same Set
+// operations with WAL records compressed, trading CPU for WAL size
+func BenchmarkSetCompressibleWithWALCompression(b *testing.B) {
+	benchmarkSetCompressible(b, cache.WithWALCompression(1024))
+}
+
+func benchmarkSetCompressible(b *testing.B, opts ...cache.Option) {
+	walDir := b.TempDir()
+	c, err := cache.NewLRUCache(1000, walDir, false, 100*1024*1024, 10, opts...)
+	if err != nil {
+		b.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	value := jsonDocument(4 * 1024)
+	b.SetBytes(int64(len(value)))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := c.Set(fmt.Sprintf("key-%d", i%1000), value, 0); err != nil {
+			b.Fatalf("Set failed: %v", err)
+		}
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(walSize(b, walDir))/float64(b.N), "wal-B/op")
+}
+
+// BenchmarkGetCompressible benchmarks Get operations on 4KB JSON values,
+// reporting the memory the cache accounts per value
+func BenchmarkGetCompressible(b *testing.B) {
+	benchmarkGetCompressible(b, 0)
+}
+
+// BenchmarkGetCompressibleInMemory benchmarks the same Get operations with
+// values kept compressed in memory, which are decompressed on every read
+func BenchmarkGetCompressibleInMemory(b *testing.B) {
+	benchmarkGetCompressible(b, 1024)
+}
+
+func benchmarkGetCompressible(b *testing.B, threshold int) {
+	c, err := cache.NewLRUCache(1000, "", false, 0, 0)
+	if err != nil {
+		b.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+	c.SetCompressionThreshold(threshold)
+
+	value := jsonDocument(4 * 1024)
+	for i := 0; i < 1000; i++ {
+		if err := c.Set(fmt.Sprintf("key-%d", i), value, 0); err != nil {
+			b.Fatalf("Set failed: %v", err)
+		}
+	}
+	stats, _ := c.Stats()
+	b.SetBytes(int64(len(value)))
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			_, _ = c.Get(fmt.Sprintf("key-%d", i%1000))
+			i++
+		}
+	})
+	b.ReportMetric(float64(stats.Bytes)/1000, "mem-B/key")
+}
@@ -0,0 +1,142 @@
+package main_test
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nishanth-gowda/kv-store/cache"
+	"github.com/nishanth-gowda/kv-store/metrics"
+	"github.com/nishanth-gowda/kv-store/server"
+)
+
+// scrapeMetrics serves GET /metrics in-process and parses the unlabelled samples
+func scrapeMetrics(t *testing.T, handler http.Handler) (map[string]float64, string) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /metrics returned %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	samples := make(map[string]float64)
+	scanner := bufio.NewScanner(strings.NewReader(rec.Body.String()))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") || strings.Contains(line, "{") {
+			continue
+		}
+		name, value, ok := strings.Cut(line, " ")
+		if !ok {
+			t.Fatalf("malformed sample line %q", line)
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			t.Fatalf("malformed sample value in %q", line)
+		}
+		samples[name] = v
+	}
+	return samples, rec.Body.String()
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	c, err := cache.NewLRUCache(2, t.TempDir(), true, 10*1024*1024, 10)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	c.Set("a", "1", 0)
+	c.Set("b", "2", 0)
+	c.Set("c", "3", 0) // evicts a
+	c.Set("short", "4", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	c.Get("c")     // hit
+	c.Get("a")     // miss (evicted)
+	c.Get("short") // miss (expired)
+
+	samples, body := scrapeMetrics(t, server.New(c))
+
+	want := map[string]float64{
+		"kv_cache_hits_total":        1,
+		"kv_cache_misses_total":      2,
+		"kv_cache_evictions_total":   2,
+		"kv_cache_expirations_total": 1,
+		"kv_cache_entries":           1,
+		"kv_cache_capacity":          2,
+		"kv_wal_appends_total":       4,
+		"kv_wal_segments":            1,
+	}
+	for name, value := range want {
+		if samples[name] != value {
+			t.Errorf("%s = %v, want %v", name, samples[name], value)
+		}
+	}
+
+	for _, name := range []string{"kv_cache_bytes", "kv_wal_bytes_written_total"} {
+		if samples[name] <= 0 {
+			t.Errorf("%s = %v, want > 0", name, samples[name])
+		}
+	}
+	// forceSync fsyncs every append; the background sync loop may add more
+	if got := samples["kv_wal_fsync_duration_seconds_count"]; got < 4 {
+		t.Errorf("kv_wal_fsync_duration_seconds_count = %v, want >= 4", got)
+	}
+	if _, ok := samples["kv_wal_recovery_duration_seconds"]; !ok {
+		t.Errorf("kv_wal_recovery_duration_seconds missing")
+	}
+	if !strings.Contains(body, "# TYPE kv_wal_fsync_duration_seconds histogram") ||
+		!strings.Contains(body, `kv_wal_fsync_duration_seconds_bucket{le="+Inf"}`) {
+		t.Errorf("fsync histogram missing from output:\n%s", body)
+	}
+}
+
+func TestMetricsWithoutWAL(t *testing.T) {
+	c, err := cache.NewLRUCache(10, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	samples, _ := scrapeMetrics(t, server.New(c))
+	if _, ok := samples["kv_wal_appends_total"]; ok {
+		t.Errorf("WAL metrics exported with the WAL disabled")
+	}
+	if _, ok := samples["kv_cache_hits_total"]; !ok {
+		t.Errorf("cache metrics missing")
+	}
+}
+
+func TestHistogramText(t *testing.T) {
+	registry := metrics.NewRegistry()
+	h := registry.NewHistogram("latency_seconds", "Latency.", []float64{0.1, 1})
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(5)
+
+	var sb strings.Builder
+	if err := registry.WriteText(&sb); err != nil {
+		t.Fatalf("WriteText failed: %v", err)
+	}
+
+	want := `# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 1
+latency_seconds_bucket{le="1"} 2
+latency_seconds_bucket{le="+Inf"} 3
+latency_seconds_sum 5.55
+latency_seconds_count 3
+`
+	if sb.String() != want {
+		t.Fatalf("WriteText output:\n%s\nwant:\n%s", sb.String(), want)
+	}
+}
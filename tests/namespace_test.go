@@ -0,0 +1,313 @@
+package main_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nishanth-gowda/kv-store/cache"
+	"github.com/nishanth-gowda/kv-store/server"
+)
+
+func TestEvictionPolicies(t *testing.T) {
+	c, err := cache.NewLRUCache(2, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	c.SetEvictionPolicy(cache.EvictNone)
+	c.Set("a", "1", 0)
+	c.Set("b", "2", 0)
+	if err := c.Set("c", "3", 0); !errors.Is(err, cache.ErrFull) {
+		t.Fatalf("Set on a full noeviction cache returned %v", err)
+	}
+	if _, err := c.HSet("h", map[string]string{"f": "v"}); !errors.Is(err, cache.ErrFull) {
+		t.Fatalf("HSet on a full noeviction cache returned %v", err)
+	}
+	// Existing keys can still be updated
+	if err := c.Set("a", "updated", 0); err != nil {
+		t.Fatalf("Set of an existing key failed: %v", err)
+	}
+
+	// Shrinking keeps every key under noeviction
+	c.SetCapacity(1)
+	if c.Len() != 2 {
+		t.Fatalf("Len after shrinking a noeviction cache = %d, want 2", c.Len())
+	}
+	c.SetCapacity(2)
+
+	c.SetEvictionPolicy(cache.EvictRandom)
+	if err := c.Set("c", "3", 0); err != nil {
+		t.Fatalf("Set with random eviction failed: %v", err)
+	}
+	if _, ok := c.Get("c"); !ok || c.Len() != 2 {
+		t.Fatalf("random eviction kept %d keys, new key present: %v", c.Len(), ok)
+	}
+
+	if _, err := cache.ParseEvictionPolicy("lfu"); err == nil {
+		t.Fatalf("ParseEvictionPolicy accepted an unknown policy")
+	}
+}
+
+func TestNamespaceIsolation(t *testing.T) {
+	ns, err := cache.NewNamespaces("", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create namespaces: %v", err)
+	}
+	defer ns.Close()
+
+	bulk, err := ns.Create("bulk", cache.NamespaceConfig{Capacity: 10, EvictionPolicy: cache.EvictLRU})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	sessions, _ := ns.Create("sessions", cache.NamespaceConfig{Capacity: 2, EvictionPolicy: cache.EvictNone, DefaultTTL: time.Hour})
+
+	sessions.Set("s1", "alice", 0)
+	for i := 0; i < 100; i++ {
+		bulk.Set(strings.Repeat("k", i+1), "v", 0)
+	}
+	if bulk.Len() != 10 {
+		t.Fatalf("bulk namespace holds %d keys, want 10", bulk.Len())
+	}
+	if value, ok := sessions.Get("s1"); !ok || value != "alice" {
+		t.Fatalf("bulk load evicted another namespace's key")
+	}
+	if ttl, _ := sessions.TTL("s1"); ttl <= 0 {
+		t.Fatalf("namespace default TTL was not applied")
+	}
+
+	tests := []struct {
+		name string
+		cfg  cache.NamespaceConfig
+		want error
+	}{
+		{"bulk", cache.NamespaceConfig{Capacity: 1, EvictionPolicy: cache.EvictLRU}, cache.ErrNamespaceExists},
+		{"bad/name", cache.NamespaceConfig{Capacity: 1, EvictionPolicy: cache.EvictLRU}, cache.ErrInvalidNamespace},
+		{"empty", cache.NamespaceConfig{EvictionPolicy: cache.EvictLRU}, cache.ErrInvalidNamespace},
+	}
+	for _, tt := range tests {
+		if _, err := ns.Create(tt.name, tt.cfg); !errors.Is(err, tt.want) {
+			t.Errorf("Create(%q, %+v) returned %v, want %v", tt.name, tt.cfg, err, tt.want)
+		}
+	}
+
+	if err := ns.Update("bulk", cache.NamespaceConfig{Capacity: 3, EvictionPolicy: cache.EvictLRU}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if bulk.Len() != 3 {
+		t.Fatalf("bulk namespace holds %d keys after shrinking, want 3", bulk.Len())
+	}
+
+	if err := ns.Delete("bulk"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, ok := ns.Get("bulk"); ok {
+		t.Fatalf("deleted namespace still exists")
+	}
+	if err := ns.Delete("bulk"); !errors.Is(err, cache.ErrNoNamespace) {
+		t.Fatalf("second Delete returned %v", err)
+	}
+}
+
+func TestNamespaceRecovery(t *testing.T) {
+	dir := t.TempDir()
+
+	ns, err := cache.NewNamespaces(dir, false, 10*1024*1024, 10)
+	if err != nil {
+		t.Fatalf("Failed to create namespaces: %v", err)
+	}
+	teamA, _ := ns.Create("team-a", cache.NamespaceConfig{Capacity: 5, EvictionPolicy: cache.EvictLRU})
+	teamA.Set("k", "a", 0)
+	teamB, _ := ns.Create("team-b", cache.NamespaceConfig{Capacity: 5, EvictionPolicy: cache.EvictLRU})
+	teamB.Set("k", "b", 0)
+	ns.Update("team-a", cache.NamespaceConfig{Capacity: 7, EvictionPolicy: cache.EvictNone, DefaultTTL: time.Minute})
+	ns.Create("gone", cache.NamespaceConfig{Capacity: 5, EvictionPolicy: cache.EvictLRU})
+	ns.Delete("gone")
+	if err := ns.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	check := func(ns *cache.Namespaces) {
+		t.Helper()
+		if names := ns.Names(); strings.Join(names, ",") != "team-a,team-b" {
+			t.Fatalf("recovered namespaces = %v", names)
+		}
+		cfg, _ := ns.Config("team-a")
+		if cfg != (cache.NamespaceConfig{Capacity: 7, EvictionPolicy: cache.EvictNone, DefaultTTL: time.Minute}) {
+			t.Fatalf("recovered settings = %+v", cfg)
+		}
+		teamA, _ := ns.Get("team-a")
+		if teamA.Capacity() != 7 || teamA.EvictionPolicy() != cache.EvictNone || teamA.DefaultTTL() != time.Minute {
+			t.Fatalf("recovered cache settings were not applied")
+		}
+		teamB, _ := ns.Get("team-b")
+		if a, _ := teamA.Get("k"); a != "a" {
+			t.Fatalf("team-a value = %v", a)
+		}
+		if b, _ := teamB.Get("k"); b != "b" {
+			t.Fatalf("team-b value = %v", b)
+		}
+	}
+
+	recovered, err := cache.NewNamespaces(dir, false, 10*1024*1024, 10)
+	if err != nil {
+		t.Fatalf("Failed to recover namespaces: %v", err)
+	}
+	check(recovered)
+
+	// A namespace created again under a deleted name starts empty
+	reused, _ := recovered.Create("gone", cache.NamespaceConfig{Capacity: 5, EvictionPolicy: cache.EvictLRU})
+	if reused.Len() != 0 {
+		t.Fatalf("recreated namespace has %d keys", reused.Len())
+	}
+	recovered.Delete("gone")
+
+	if err := recovered.Snapshot(); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	if err := recovered.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	again, err := cache.NewNamespaces(dir, false, 10*1024*1024, 10)
+	if err != nil {
+		t.Fatalf("Failed to recover namespaces after snapshot: %v", err)
+	}
+	defer again.Close()
+	check(again)
+}
+
+// The catalog is compacted into a snapshot instead of dropping segments
+// with namespace settings
+func TestNamespaceCatalogSegmentLimit(t *testing.T) {
+	dir := t.TempDir()
+
+	ns, err := cache.NewNamespaces(dir, false, 256, 2)
+	if err != nil {
+		t.Fatalf("Failed to create namespaces: %v", err)
+	}
+	if _, err := ns.Create("first", cache.NamespaceConfig{Capacity: 5, EvictionPolicy: cache.EvictLRU}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	for i := 0; i < 50; i++ {
+		name := fmt.Sprintf("ns-%d", i)
+		ns.Create(name, cache.NamespaceConfig{Capacity: 5, EvictionPolicy: cache.EvictLRU})
+		if i%2 == 0 {
+			ns.Delete(name)
+		}
+	}
+	if err := ns.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	recovered, err := cache.NewNamespaces(dir, false, 256, 2)
+	if err != nil {
+		t.Fatalf("Failed to recover namespaces: %v", err)
+	}
+	defer recovered.Close()
+	if names := recovered.Names(); len(names) != 26 || names[0] != "first" {
+		t.Fatalf("recovered %d namespaces %v, want first and 25 others", len(names), names)
+	}
+}
+
+func TestNamespaceCreateFailureLeavesNoNamespace(t *testing.T) {
+	dir := t.TempDir()
+	cfg := cache.NamespaceConfig{Capacity: 5, EvictionPolicy: cache.EvictLRU}
+
+	ns, err := cache.NewNamespaces(dir, false, 1024*1024, 10)
+	if err != nil {
+		t.Fatalf("Failed to create namespaces: %v", err)
+	}
+	// A file where the namespace data directories go makes opening fail
+	if err := os.WriteFile(filepath.Join(dir, "data"), nil, 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	if _, err := ns.Create("a", cfg); err == nil {
+		t.Fatalf("Create succeeded without a data directory")
+	}
+	if names := ns.Names(); len(names) != 0 {
+		t.Fatalf("Names after a failed Create = %v, want none", names)
+	}
+	if err := ns.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "data")); err != nil {
+		t.Fatalf("Failed to remove file: %v", err)
+	}
+	recovered, err := cache.NewNamespaces(dir, false, 1024*1024, 10)
+	if err != nil {
+		t.Fatalf("Failed to recover namespaces: %v", err)
+	}
+	defer recovered.Close()
+	if names := recovered.Names(); len(names) != 0 {
+		t.Fatalf("recovered namespaces %v, want none", names)
+	}
+	if _, err := recovered.Create("a", cfg); err != nil {
+		t.Fatalf("Create after the failure failed: %v", err)
+	}
+}
+
+func TestNamespaceEndpoints(t *testing.T) {
+	c, err := cache.NewLRUCache(10, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+	ns, err := cache.NewNamespaces("", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create namespaces: %v", err)
+	}
+	defer ns.Close()
+
+	srv := httptest.NewServer(server.New(c, server.WithNamespaces(ns)))
+	defer srv.Close()
+
+	request := func(method, path string) (int, string) {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL+path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, strings.TrimSpace(string(data))
+	}
+
+	tests := []struct {
+		method, path string
+		status       int
+		response     string
+	}{
+		{http.MethodPut, "/ns/tiny", http.StatusBadRequest, "capacity is required"},
+		{http.MethodPut, "/ns/tiny?capacity=1&eviction_policy=noeviction", http.StatusCreated, `{"name":"tiny","capacity":1,"eviction_policy":"noeviction","default_ttl":"0s","keys":0}`},
+		{http.MethodPut, "/ns/bad.name?capacity=1", http.StatusBadRequest, cache.ErrInvalidNamespace.Error()},
+		{http.MethodPut, "/ns/tiny?eviction_policy=lfu", http.StatusBadRequest, `unknown eviction policy "lfu", want lru, random or noeviction`},
+		{http.MethodPost, "/ns/tiny/set?key=k&value=inside", http.StatusOK, "OK"},
+		{http.MethodPost, "/ns/tiny/set?key=k2&value=v", http.StatusInsufficientStorage, cache.ErrFull.Error()},
+		{http.MethodGet, "/ns/tiny/get?key=k", http.StatusOK, "inside"},
+		{http.MethodGet, "/get?key=k", http.StatusNotFound, "Key not found"},
+		{http.MethodGet, "/ns/tiny/keys", http.StatusOK, `["k"]`},
+		{http.MethodPut, "/ns/tiny?capacity=5&default_ttl=1h", http.StatusOK, `{"name":"tiny","capacity":5,"eviction_policy":"noeviction","default_ttl":"1h0m0s","keys":1}`},
+		{http.MethodGet, "/ns", http.StatusOK, `[{"name":"tiny","capacity":5,"eviction_policy":"noeviction","default_ttl":"1h0m0s","keys":1}]`},
+		{http.MethodGet, "/ns/missing/get?key=k", http.StatusNotFound, cache.ErrNoNamespace.Error()},
+		{http.MethodDelete, "/ns/tiny", http.StatusOK, "OK"},
+		{http.MethodGet, "/ns/tiny/get?key=k", http.StatusNotFound, cache.ErrNoNamespace.Error()},
+		{http.MethodGet, "/ns", http.StatusOK, `[]`},
+	}
+	for _, tt := range tests {
+		status, body := request(tt.method, tt.path)
+		if status != tt.status || body != tt.response {
+			t.Errorf("%s %s = %d %q, want %d %q", tt.method, tt.path, status, body, tt.status, tt.response)
+		}
+	}
+}
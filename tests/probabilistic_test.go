@@ -0,0 +1,204 @@
+package main_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nishanth-gowda/kv-store/cache"
+	"github.com/nishanth-gowda/kv-store/server"
+)
+
+func TestHyperLogLog(t *testing.T) {
+	c, err := cache.NewLRUCache(10, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	if count, _ := c.PFCount("visitors"); count != 0 {
+		t.Fatalf("PFCount of a missing key = %d", count)
+	}
+
+	for _, n := range []int{10, 1000, 100000} {
+		key := fmt.Sprintf("hll-%d", n)
+		for i := 0; i < n; i++ {
+			// Every element is added twice; duplicates must not be counted
+			c.PFAdd(key, fmt.Sprintf("user-%d", i), fmt.Sprintf("user-%d", i))
+		}
+		count, err := c.PFCount(key)
+		if err != nil {
+			t.Fatalf("PFCount failed: %v", err)
+		}
+		if relErr := math.Abs(float64(count)-float64(n)) / float64(n); relErr > 0.03 {
+			t.Errorf("PFCount after %d distinct elements = %d (error %.2f%%)", n, count, relErr*100)
+		}
+	}
+
+	if changed, _ := c.PFAdd("hll-10", "user-1"); changed {
+		t.Errorf("PFAdd of a seen element reported a change")
+	}
+
+	// Union of overlapping sets: 0-999 and 500-1499
+	for i := 0; i < 1000; i++ {
+		c.PFAdd("a", fmt.Sprintf("id-%d", i))
+		c.PFAdd("b", fmt.Sprintf("id-%d", i+500))
+	}
+	union, _ := c.PFCount("a", "b")
+	if err := c.PFMerge("merged", "a", "b"); err != nil {
+		t.Fatalf("PFMerge failed: %v", err)
+	}
+	merged, _ := c.PFCount("merged")
+	if merged != union || math.Abs(float64(merged)-1500)/1500 > 0.03 {
+		t.Errorf("merged count = %d, union count = %d, want about 1500", merged, union)
+	}
+
+	c.Set("s", "v", 0)
+	if _, err := c.PFAdd("s", "x"); !errors.Is(err, cache.ErrWrongType) {
+		t.Errorf("PFAdd on a string returned %v", err)
+	}
+}
+
+func TestBloomFilter(t *testing.T) {
+	c, err := cache.NewLRUCache(10, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	if err := c.BFReserve("seen", 0.01, 10000); err != nil {
+		t.Fatalf("BFReserve failed: %v", err)
+	}
+	if err := c.BFReserve("seen", 0.01, 10000); !errors.Is(err, cache.ErrExists) {
+		t.Fatalf("BFReserve on an existing key returned %v", err)
+	}
+	if err := c.BFReserve("bad", 1.5, 10); !errors.Is(err, cache.ErrInvalidBloomFilter) {
+		t.Fatalf("BFReserve with an invalid error rate returned %v", err)
+	}
+	if err := c.BFReserve("huge", 1e-300, math.MaxInt); !errors.Is(err, cache.ErrInvalidBloomFilter) {
+		t.Fatalf("BFReserve of a filter over MaxBloomBits returned %v", err)
+	}
+	if err := c.BFReserve("huge", 0.01, 300_000_000); !errors.Is(err, cache.ErrInvalidBloomFilter) {
+		t.Fatalf("BFReserve of a filter over MaxBloomBits returned %v", err)
+	}
+
+	for i := 0; i < 10000; i++ {
+		c.BFAdd("seen", fmt.Sprintf("id-%d", i))
+	}
+	for i := 0; i < 10000; i++ {
+		if ok, _ := c.BFExists("seen", fmt.Sprintf("id-%d", i)); !ok {
+			t.Fatalf("BFExists returned a false negative for id-%d", i)
+		}
+	}
+
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if ok, _ := c.BFExists("seen", fmt.Sprintf("other-%d", i)); ok {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / 10000; rate > 0.02 {
+		t.Errorf("false positive rate = %.3f, want about 0.01", rate)
+	}
+
+	// BFAdd creates a default filter and reports repeated elements
+	if added, err := c.BFAdd("auto", "x"); err != nil || !added {
+		t.Fatalf("BFAdd on a missing key = %v, %v", added, err)
+	}
+	if added, _ := c.BFAdd("auto", "x"); added {
+		t.Fatalf("BFAdd of a present element reported it as added")
+	}
+	if ok, _ := c.BFExists("missing", "x"); ok {
+		t.Fatalf("BFExists on a missing key = true")
+	}
+}
+
+func TestProbabilisticRecovery(t *testing.T) {
+	walDir := t.TempDir()
+
+	c, err := cache.NewLRUCache(10, walDir, false, 10*1024*1024, 10)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	for i := 0; i < 500; i++ {
+		c.PFAdd("hll", fmt.Sprintf("u%d", i))
+		c.PFAdd("other", fmt.Sprintf("v%d", i))
+	}
+	c.PFMerge("merged", "hll", "other")
+	c.BFReserve("bf", 0.001, 100)
+	c.BFAdd("bf", "alpha")
+	countBefore, _ := c.PFCount("hll")
+	mergedBefore, _ := c.PFCount("merged")
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	recovered, err := cache.NewLRUCache(10, walDir, false, 10*1024*1024, 10)
+	if err != nil {
+		t.Fatalf("Failed to recover cache: %v", err)
+	}
+	defer recovered.Close()
+
+	if count, _ := recovered.PFCount("hll"); count != countBefore {
+		t.Errorf("recovered PFCount = %d, want %d", count, countBefore)
+	}
+	if count, _ := recovered.PFCount("merged"); count != mergedBefore {
+		t.Errorf("recovered merged PFCount = %d, want %d", count, mergedBefore)
+	}
+	if ok, _ := recovered.BFExists("bf", "alpha"); !ok {
+		t.Errorf("recovered Bloom filter lost an element")
+	}
+	value, _ := recovered.Get("bf")
+	if bf, ok := value.(*cache.BloomFilter); !ok || len(bf.Bits)*64 < 1400 {
+		t.Errorf("recovered Bloom filter lost its reserved size: %v", value)
+	}
+}
+
+func TestProbabilisticEndpoints(t *testing.T) {
+	c, err := cache.NewLRUCache(10, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	srv := httptest.NewServer(server.New(c))
+	defer srv.Close()
+
+	tests := []struct {
+		method, path string
+		status       int
+		response     string
+	}{
+		{http.MethodPost, "/pfadd?key=a&element=x&element=y", http.StatusOK, `{"changed":true}`},
+		{http.MethodPost, "/pfadd?key=a&element=x", http.StatusOK, `{"changed":false}`},
+		{http.MethodPost, "/pfadd?key=b&element=z", http.StatusOK, `{"changed":true}`},
+		{http.MethodGet, "/pfcount?key=a", http.StatusOK, `{"count":2}`},
+		{http.MethodPost, "/pfmerge?dest=c&key=a&key=b", http.StatusOK, "OK"},
+		{http.MethodGet, "/pfcount?key=c", http.StatusOK, `{"count":3}`},
+		{http.MethodPost, "/bfreserve?key=bf&error_rate=0.001&capacity=100", http.StatusOK, "OK"},
+		{http.MethodPost, "/bfreserve?key=bf", http.StatusConflict, cache.ErrExists.Error()},
+		{http.MethodPost, "/bfreserve?key=bad&capacity=0", http.StatusBadRequest, cache.ErrInvalidBloomFilter.Error()},
+		{http.MethodPost, "/bfreserve?key=bad&error_rate=1e-300&capacity=9223372036854775807", http.StatusBadRequest, cache.ErrInvalidBloomFilter.Error()},
+		{http.MethodPost, "/bfadd?key=bf&element=e", http.StatusOK, `{"added":true}`},
+		{http.MethodGet, "/bfexists?key=bf&element=e", http.StatusOK, `{"exists":true}`},
+		{http.MethodGet, "/bfexists?key=bf&element=f", http.StatusOK, `{"exists":false}`},
+		{http.MethodPost, "/bfadd?key=a&element=e", http.StatusBadRequest, cache.ErrWrongType.Error()},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, srv.URL+tt.path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", tt.method, tt.path, err)
+		}
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if body := strings.TrimSpace(string(data)); resp.StatusCode != tt.status || body != tt.response {
+			t.Errorf("%s %s = %d %q, want %d %q", tt.method, tt.path, resp.StatusCode, body, tt.status, tt.response)
+		}
+	}
+}
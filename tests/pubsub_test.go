@@ -0,0 +1,159 @@
+package main_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nishanth-gowda/kv-store/cache"
+	"github.com/nishanth-gowda/kv-store/client"
+	"github.com/nishanth-gowda/kv-store/pubsub"
+	"github.com/nishanth-gowda/kv-store/server"
+)
+
+// nextMessage waits briefly for the next message on a subscription
+func nextMessage(t *testing.T, sub *pubsub.Subscription) pubsub.Message {
+	t.Helper()
+	select {
+	case msg, ok := <-sub.C:
+		if !ok {
+			t.Fatalf("subscription closed: %v", sub.Err())
+		}
+		return msg
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for message")
+	}
+	return pubsub.Message{}
+}
+
+func TestPubSubChannelsAndPatterns(t *testing.T) {
+	broker := pubsub.NewBroker(0)
+
+	news := broker.Subscribe("news")
+	defer news.Close()
+	alerts, err := broker.PSubscribe("alerts.*")
+	if err != nil {
+		t.Fatalf("PSubscribe failed: %v", err)
+	}
+	defer alerts.Close()
+
+	if n := broker.Publish("news", "hello"); n != 1 {
+		t.Fatalf("Publish to news reached %d subscriptions, want 1", n)
+	}
+	if n := broker.Publish("alerts.disk", "full"); n != 1 {
+		t.Fatalf("Publish to alerts.disk reached %d subscriptions, want 1", n)
+	}
+	if n := broker.Publish("sports", "goal"); n != 0 {
+		t.Fatalf("Publish to sports reached %d subscriptions, want 0", n)
+	}
+
+	if msg := nextMessage(t, news); msg.Channel != "news" || msg.Payload != "hello" || msg.Pattern != "" {
+		t.Fatalf("news got %+v", msg)
+	}
+	if msg := nextMessage(t, alerts); msg.Channel != "alerts.disk" || msg.Pattern != "alerts.*" || msg.Payload != "full" {
+		t.Fatalf("alerts got %+v", msg)
+	}
+
+	if _, err := broker.PSubscribe("[bad"); err == nil {
+		t.Fatalf("PSubscribe accepted an invalid pattern")
+	}
+
+	news.Close()
+	if _, ok := <-news.C; ok {
+		t.Fatalf("channel still open after Close")
+	}
+	if n := broker.NumSubscribers("news"); n != 0 {
+		t.Fatalf("NumSubscribers after Close = %d", n)
+	}
+}
+
+func TestPubSubSlowConsumer(t *testing.T) {
+	broker := pubsub.NewBroker(4)
+
+	slow := broker.Subscribe("events")
+	fast := broker.Subscribe("events")
+	defer fast.Close()
+
+	for i := 0; i < 10; i++ {
+		broker.Publish("events", "x")
+		nextMessage(t, fast)
+	}
+
+	for range slow.C {
+	}
+	if !errors.Is(slow.Err(), pubsub.ErrSlowConsumer) {
+		t.Fatalf("slow subscriber Err = %v, want ErrSlowConsumer", slow.Err())
+	}
+	if n := broker.NumSubscribers("events"); n != 1 {
+		t.Fatalf("NumSubscribers = %d, want 1", n)
+	}
+}
+
+func TestPubSubEndpoints(t *testing.T) {
+	c, err := cache.NewLRUCache(10, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	srv := httptest.NewServer(server.New(c))
+	defer srv.Close()
+
+	kv, err := client.New(srv.URL)
+	if err != nil {
+		t.Fatalf("client.New failed: %v", err)
+	}
+
+	resp, err := http.Get(srv.URL + "/subscribe?channel=news&pattern=alerts.*")
+	if err != nil {
+		t.Fatalf("GET /subscribe failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	ctx := context.Background()
+	if n, err := kv.Publish(ctx, "news", "hello"); err != nil || n != 1 {
+		t.Fatalf("Publish(news) = %d, %v", n, err)
+	}
+	if n, err := kv.Publish(ctx, "alerts.cpu", "hot"); err != nil || n != 1 {
+		t.Fatalf("Publish(alerts.cpu) = %d, %v", n, err)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	var messages []server.PubSubMessage
+	for len(messages) < 2 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading stream: %v", err)
+		}
+		if data, ok := strings.CutPrefix(strings.TrimSpace(line), "data: "); ok {
+			var msg server.PubSubMessage
+			if err := json.Unmarshal([]byte(data), &msg); err != nil {
+				t.Fatalf("invalid message data %q: %v", data, err)
+			}
+			messages = append(messages, msg)
+		}
+	}
+
+	if messages[0].Channel != "news" || messages[0].Message != "hello" ||
+		messages[1].Pattern != "alerts.*" || messages[1].Message != "hot" {
+		t.Fatalf("messages = %+v", messages)
+	}
+
+	missing, err := http.Get(srv.URL + "/subscribe")
+	if err != nil {
+		t.Fatalf("GET /subscribe failed: %v", err)
+	}
+	missing.Body.Close()
+	if missing.StatusCode != http.StatusBadRequest {
+		t.Fatalf("subscribe without channels returned %d, want 400", missing.StatusCode)
+	}
+}
@@ -0,0 +1,187 @@
+package main_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nishanth-gowda/kv-store/cache"
+	"github.com/nishanth-gowda/kv-store/wal"
+)
+
+func TestRecoveryFromWAL(t *testing.T) {
+	walDir := t.TempDir()
+
+	c, err := cache.NewLRUCache(10, walDir, false, 10*1024*1024, 10)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	if err := c.Set("string", "value", 0); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := c.Set("bytes", []byte{1, 2, 3}, time.Hour); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := c.Set("deleted", "value", 0); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := c.Delete("deleted"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	recovered, err := cache.NewLRUCache(10, walDir, false, 10*1024*1024, 10)
+	if err != nil {
+		t.Fatalf("Failed to recover cache: %v", err)
+	}
+	defer recovered.Close()
+
+	if value, ok := recovered.Get("string"); !ok || value != "value" {
+		t.Errorf("Get(string) = %v, %v", value, ok)
+	}
+	if value, ok := recovered.Get("bytes"); !ok || string(value.([]byte)) != "\x01\x02\x03" {
+		t.Errorf("Get(bytes) = %v, %v", value, ok)
+	}
+	if ttl, ok := recovered.TTL("bytes"); !ok || ttl <= 0 || ttl > time.Hour {
+		t.Errorf("TTL(bytes) = %v, %v", ttl, ok)
+	}
+	if _, ok := recovered.Get("deleted"); ok {
+		t.Errorf("deleted key was recovered")
+	}
+}
+
+func TestRecoveryFromSnapshot(t *testing.T) {
+	walDir := t.TempDir()
+
+	// Small segments so the snapshot has several segments to replace
+	c, err := cache.NewLRUCache(100, walDir, false, 512, 100)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	for i := 0; i < 50; i++ {
+		if err := c.Set(fmt.Sprintf("key-%d", i), fmt.Sprintf("value-%d", i), 0); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+	}
+	if err := c.Snapshot(); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+
+	// Writes after the snapshot are replayed on top of it
+	if err := c.Set("key-0", "updated", 0); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := c.Delete("key-1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	recovered, err := cache.NewLRUCache(100, walDir, false, 512, 100)
+	if err != nil {
+		t.Fatalf("Failed to recover cache: %v", err)
+	}
+	defer recovered.Close()
+
+	if got := recovered.Len(); got != 49 {
+		t.Fatalf("recovered %d entries, want 49", got)
+	}
+	if value, ok := recovered.Get("key-0"); !ok || value != "updated" {
+		t.Errorf("Get(key-0) = %v, %v", value, ok)
+	}
+	if _, ok := recovered.Get("key-1"); ok {
+		t.Errorf("key-1 deleted after the snapshot was recovered")
+	}
+	if value, ok := recovered.Get("key-49"); !ok || value != "value-49" {
+		t.Errorf("Get(key-49) = %v, %v", value, ok)
+	}
+
+	// A second restart must not reuse sequence numbers covered by the snapshot
+	if err := recovered.Set("after-restart", "value", 0); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := recovered.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	again, err := cache.NewLRUCache(100, walDir, false, 512, 100)
+	if err != nil {
+		t.Fatalf("Failed to recover cache: %v", err)
+	}
+	defer again.Close()
+
+	if _, ok := again.Get("after-restart"); !ok {
+		t.Errorf("write after restart was lost")
+	}
+}
+
+// Segments beyond maxSegments hold writes made since the last snapshot, so
+// they are compacted into a new snapshot instead of being dropped
+func TestSegmentLimitKeepsWritesSinceSnapshot(t *testing.T) {
+	storage := wal.NewMemoryStorage()
+	open := func() *cache.LRUCache {
+		t.Helper()
+		c, err := cache.NewLRUCache(100, "", false, 512, 5, cache.WithWALStorage(storage))
+		if err != nil {
+			t.Fatalf("Failed to create cache: %v", err)
+		}
+		return c
+	}
+
+	c := open()
+	c.Set("a", "old", 0)
+	c.Set("b", "deleted", 0)
+	if err := c.Snapshot(); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	c.Set("a", "new", 0)
+	c.Delete("b")
+	filler := strings.Repeat("x", 230)
+	for i := 0; i < 200; i++ {
+		if err := c.Set("c", fmt.Sprintf("%d-%s", i, filler), 0); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+	}
+
+	// Compaction runs in the background, so wait for it to catch up
+	deadline := time.Now().Add(time.Second)
+	segments, _ := storage.List("wal-segment-")
+	for len(segments) > 6 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+		segments, _ = storage.List("wal-segment-")
+	}
+	if len(segments) > 6 {
+		t.Errorf("WAL kept %d segments, want at most 6", len(segments))
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	recovered := open()
+	defer recovered.Close()
+	if value, _ := recovered.Get("a"); value != "new" {
+		t.Errorf("Get(a) = %v after recovery, want new", value)
+	}
+	if _, ok := recovered.Get("b"); ok {
+		t.Errorf("b deleted after the snapshot came back")
+	}
+	if value, _ := recovered.Get("c"); value != "199-"+filler {
+		t.Errorf("Get(c) = %.20v after recovery, want the last write", value)
+	}
+}
+
+// The checksum of a record must not depend on what else the process has
+// gob-encoded, or records written by one process fail to verify in another
+func TestRecordChecksumIsStable(t *testing.T) {
+	entry := &wal.WAL_Entry{Type: wal.EntryTypeSET, SequenceNumber: 7, Key: "k", Value: []byte("v"), ExpiresAtUnixNano: 42}
+	if _, err := wal.Marshal(entry); err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if entry.CRC != 3540456042 {
+		t.Fatalf("CRC = %d, want 3540456042", entry.CRC)
+	}
+}
@@ -0,0 +1,88 @@
+package main_test
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nishanth-gowda/kv-store/cache"
+)
+
+func TestOnRemoval(t *testing.T) {
+	c, err := cache.NewLRUCache(2, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	var mu sync.Mutex
+	var got []string
+	c.OnRemoval(func(key string, value any, reason cache.RemovalReason) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, fmt.Sprintf("%s %s=%v", reason, key, value))
+	})
+
+	c.Set("a", "1", 0)
+	c.Set("a", "2", 0)
+	c.CompareAndSwap("a", "2", "3", 0)
+	c.Set("b", "1", 0)
+	c.Set("c", "1", 0) // evicts a
+	c.Delete("b")
+	c.Set("t", "1", 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	c.Get("t")
+	c.RPush("l", "x")
+	c.RPop("l")
+	c.HSet("h", map[string]string{"f": "v"})
+	c.HDel("h", "f", "missing")
+	c.SAdd("s", "m", "n")
+	c.SRem("s", "m")
+	c.SRem("s", "n")
+
+	want := []string{
+		"replaced a=1",
+		"replaced a=2",
+		"evicted a=3",
+		"deleted b=1",
+		"expired t=1",
+		"deleted l=[x]",
+		"deleted h=map[f:v]",
+		"deleted s=map[n:true]",
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !slices.Equal(got, want) {
+		t.Fatalf("OnRemoval received %q, want %q", got, want)
+	}
+}
+
+func TestOnRemovalCallsBackIntoCache(t *testing.T) {
+	c, err := cache.NewLRUCache(1, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	// The callback runs without the cache lock, so it may use the cache
+	c.OnRemoval(func(key string, value any, reason cache.RemovalReason) {
+		if reason == cache.RemovalEvicted {
+			c.Len()
+			c.Get(key)
+		}
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Set("a", "1", 0)
+		c.Set("b", "1", 0)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Calling into the cache from OnRemoval deadlocked")
+	}
+}
@@ -0,0 +1,264 @@
+package main_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/nishanth-gowda/kv-store/cache"
+	"github.com/nishanth-gowda/kv-store/server"
+)
+
+func TestSetOperations(t *testing.T) {
+	c, err := cache.NewLRUCache(10, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	if added, err := c.SAdd("a", "x", "y", "z", "x"); err != nil || added != 3 {
+		t.Fatalf("SAdd = %d, %v", added, err)
+	}
+	if added, _ := c.SAdd("a", "x", "w"); added != 1 {
+		t.Fatalf("SAdd with an existing member = %d, want 1", added)
+	}
+	c.SAdd("b", "y", "z", "v")
+
+	if ok, err := c.SIsMember("a", "w"); err != nil || !ok {
+		t.Fatalf("SIsMember(a, w) = %v, %v", ok, err)
+	}
+	if ok, _ := c.SIsMember("a", "v"); ok {
+		t.Fatalf("SIsMember(a, v) = true")
+	}
+	if members, _ := c.SMembers("a"); !reflect.DeepEqual(members, []string{"w", "x", "y", "z"}) {
+		t.Fatalf("SMembers = %v", members)
+	}
+	if members, _ := c.SInter("a", "b"); !reflect.DeepEqual(members, []string{"y", "z"}) {
+		t.Fatalf("SInter = %v", members)
+	}
+	if members, _ := c.SInter("a", "missing"); len(members) != 0 {
+		t.Fatalf("SInter with a missing key = %v", members)
+	}
+	if members, _ := c.SUnion("a", "b", "missing"); !reflect.DeepEqual(members, []string{"v", "w", "x", "y", "z"}) {
+		t.Fatalf("SUnion = %v", members)
+	}
+
+	if removed, err := c.SRem("b", "y", "q"); err != nil || removed != 1 {
+		t.Fatalf("SRem = %d, %v", removed, err)
+	}
+	c.SRem("b", "z", "v")
+	if _, ok := c.Get("b"); ok {
+		t.Fatalf("set still exists after its last member was removed")
+	}
+
+	c.Set("s", "v", 0)
+	if _, err := c.SAdd("s", "x"); !errors.Is(err, cache.ErrWrongType) {
+		t.Fatalf("SAdd on a string returned %v", err)
+	}
+	if _, err := c.SUnion("a", "s"); !errors.Is(err, cache.ErrWrongType) {
+		t.Fatalf("SUnion with a string returned %v", err)
+	}
+}
+
+func TestSortedSetOperations(t *testing.T) {
+	c, err := cache.NewLRUCache(10, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	if added, err := c.ZAdd("board", map[string]float64{"alice": 30, "bob": 10, "carol": 20}); err != nil || added != 3 {
+		t.Fatalf("ZAdd = %d, %v", added, err)
+	}
+	if added, _ := c.ZAdd("board", map[string]float64{"bob": 40, "dave": 20}); added != 1 {
+		t.Fatalf("ZAdd update = %d, want 1", added)
+	}
+	if score, err := c.ZIncrBy("board", "carol", 5.5); err != nil || score != 25.5 {
+		t.Fatalf("ZIncrBy = %v, %v", score, err)
+	}
+
+	want := []cache.ZMember{{Member: "dave", Score: 20}, {Member: "carol", Score: 25.5}, {Member: "alice", Score: 30}, {Member: "bob", Score: 40}}
+	if members, _ := c.ZRange("board", 0, -1); !reflect.DeepEqual(members, want) {
+		t.Fatalf("ZRange = %v, want %v", members, want)
+	}
+	if members, _ := c.ZRange("board", -2, -1); !reflect.DeepEqual(members, want[2:]) {
+		t.Fatalf("ZRange(-2, -1) = %v", members)
+	}
+	if members, _ := c.ZRangeByScore("board", 21, 30); !reflect.DeepEqual(members, want[1:3]) {
+		t.Fatalf("ZRangeByScore = %v", members)
+	}
+	if rank, ok, err := c.ZRank("board", "alice"); err != nil || !ok || rank != 2 {
+		t.Fatalf("ZRank(alice) = %d, %v, %v", rank, ok, err)
+	}
+	if _, ok, _ := c.ZRank("board", "nobody"); ok {
+		t.Fatalf("ZRank found a missing member")
+	}
+
+	if _, err := c.ZAdd("board", map[string]float64{"x": math.NaN()}); !errors.Is(err, cache.ErrInvalidScore) {
+		t.Fatalf("ZAdd with NaN returned %v", err)
+	}
+	c.ZAdd("inf", map[string]float64{"x": math.Inf(1)})
+	if _, err := c.ZIncrBy("inf", "x", math.Inf(-1)); !errors.Is(err, cache.ErrInvalidScore) {
+		t.Fatalf("ZIncrBy producing NaN returned %v", err)
+	}
+
+	// Get returns an independent copy
+	value, _ := c.Get("board")
+	if zset, ok := value.(*cache.SortedSet); !ok || zset.Len() != 4 || !reflect.DeepEqual(zset.Members(), want) {
+		t.Fatalf("Get(board) = %v", value)
+	}
+}
+
+func TestSortedSetMatchesSortedSlice(t *testing.T) {
+	c, err := cache.NewLRUCache(10, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	scores := map[string]float64{}
+	for i := 0; i < 2000; i++ {
+		member := fmt.Sprintf("m%d", rand.IntN(500))
+		score := float64(rand.IntN(100))
+		scores[member] = score
+		c.ZAdd("z", map[string]float64{member: score})
+	}
+
+	var want []cache.ZMember
+	for member, score := range scores {
+		want = append(want, cache.ZMember{Member: member, Score: score})
+	}
+	sort.Slice(want, func(i, j int) bool {
+		if want[i].Score != want[j].Score {
+			return want[i].Score < want[j].Score
+		}
+		return want[i].Member < want[j].Member
+	})
+
+	if got, _ := c.ZRange("z", 0, -1); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ZRange does not match the sorted members")
+	}
+	for i, m := range want {
+		if rank, ok, _ := c.ZRank("z", m.Member); !ok || rank != i {
+			t.Fatalf("ZRank(%s) = %d, %v, want %d", m.Member, rank, ok, i)
+		}
+		if got, _ := c.ZRange("z", i, i); len(got) != 1 || got[0] != m {
+			t.Fatalf("ZRange(%d, %d) = %v, want %v", i, i, got, m)
+		}
+	}
+
+	var inRange []cache.ZMember
+	for _, m := range want {
+		if m.Score >= 25 && m.Score <= 50 {
+			inRange = append(inRange, m)
+		}
+	}
+	if got, _ := c.ZRangeByScore("z", 25, 50); !reflect.DeepEqual(got, inRange) {
+		t.Fatalf("ZRangeByScore does not match the sorted members")
+	}
+}
+
+func TestSetAndSortedSetRecovery(t *testing.T) {
+	walDir := t.TempDir()
+
+	c, err := cache.NewLRUCache(10, walDir, false, 10*1024*1024, 10)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	c.SAdd("tags", "go", "db", "tmp")
+	c.SRem("tags", "tmp")
+	c.ZAdd("board", map[string]float64{"a": 1, "b": 2})
+	c.ZIncrBy("board", "a", 5)
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	recovered, err := cache.NewLRUCache(10, walDir, false, 10*1024*1024, 10)
+	if err != nil {
+		t.Fatalf("Failed to recover cache: %v", err)
+	}
+	wantTags := []string{"db", "go"}
+	wantBoard := []cache.ZMember{{Member: "b", Score: 2}, {Member: "a", Score: 6}}
+	if tags, _ := recovered.SMembers("tags"); !reflect.DeepEqual(tags, wantTags) {
+		t.Fatalf("recovered set = %v, want %v", tags, wantTags)
+	}
+	if board, _ := recovered.ZRange("board", 0, -1); !reflect.DeepEqual(board, wantBoard) {
+		t.Fatalf("recovered sorted set = %v, want %v", board, wantBoard)
+	}
+
+	// Both types survive a snapshot as whole values
+	if err := recovered.Snapshot(); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	if err := recovered.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	again, err := cache.NewLRUCache(10, walDir, false, 10*1024*1024, 10)
+	if err != nil {
+		t.Fatalf("Failed to recover cache: %v", err)
+	}
+	defer again.Close()
+	if tags, _ := again.SMembers("tags"); !reflect.DeepEqual(tags, wantTags) {
+		t.Fatalf("set recovered from snapshot = %v", tags)
+	}
+	if board, _ := again.ZRange("board", 0, -1); !reflect.DeepEqual(board, wantBoard) {
+		t.Fatalf("sorted set recovered from snapshot = %v", board)
+	}
+	if rank, ok, _ := again.ZRank("board", "a"); !ok || rank != 1 {
+		t.Fatalf("ZRank after snapshot recovery = %d, %v", rank, ok)
+	}
+}
+
+func TestSetAndSortedSetEndpoints(t *testing.T) {
+	c, err := cache.NewLRUCache(10, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	srv := httptest.NewServer(server.New(c))
+	defer srv.Close()
+
+	tests := []struct {
+		method, path, body string
+		status             int
+		response           string
+	}{
+		{http.MethodPost, "/sadd?key=a&member=x&member=y", "", http.StatusOK, `{"added":2}`},
+		{http.MethodPost, "/sadd?key=b&member=y&member=z", "", http.StatusOK, `{"added":2}`},
+		{http.MethodGet, "/sismember?key=a&member=x", "", http.StatusOK, `{"member":true}`},
+		{http.MethodGet, "/smembers?key=a", "", http.StatusOK, `["x","y"]`},
+		{http.MethodGet, "/sinter?key=a&key=b", "", http.StatusOK, `["y"]`},
+		{http.MethodGet, "/sunion?key=a&key=b", "", http.StatusOK, `["x","y","z"]`},
+		{http.MethodDelete, "/srem?key=a&member=x", "", http.StatusOK, `{"removed":1}`},
+		{http.MethodPost, "/zadd?key=z", `{"a":3,"b":1}`, http.StatusOK, `{"added":2}`},
+		{http.MethodPost, "/zincrby?key=z&member=c&by=2", "", http.StatusOK, `{"score":2}`},
+		{http.MethodGet, "/zrange?key=z", "", http.StatusOK, `[{"member":"b","score":1},{"member":"c","score":2},{"member":"a","score":3}]`},
+		{http.MethodGet, "/zrangebyscore?key=z&min=2", "", http.StatusOK, `[{"member":"c","score":2},{"member":"a","score":3}]`},
+		{http.MethodGet, "/zrank?key=z&member=a", "", http.StatusOK, `{"rank":2}`},
+		{http.MethodGet, "/zrank?key=z&member=q", "", http.StatusNotFound, "Member not found"},
+		{http.MethodPost, "/zincrby?key=z&member=a&by=inf", "", http.StatusBadRequest, "by must be a finite number"},
+		{http.MethodPost, "/sadd?key=z&member=x", "", http.StatusBadRequest, cache.ErrWrongType.Error()},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, srv.URL+tt.path, strings.NewReader(tt.body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", tt.method, tt.path, err)
+		}
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if body := strings.TrimSpace(string(data)); resp.StatusCode != tt.status || body != tt.response {
+			t.Errorf("%s %s = %d %q, want %d %q", tt.method, tt.path, resp.StatusCode, body, tt.status, tt.response)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package main_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nishanth-gowda/kv-store/cache"
+	"github.com/nishanth-gowda/kv-store/server"
+)
+
+func TestSlidingExpiration(t *testing.T) {
+	clock := newFakeClock()
+	c, err := cache.NewLRUCache(10, "", false, 0, 0, cache.WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	c.Set("sliding", "v", 10*time.Second, cache.WithSlidingExpiration())
+	c.Set("fixed", "v", 10*time.Second)
+
+	// Reads every 6s keep the sliding key alive well past its first deadline
+	for i := 0; i < 5; i++ {
+		clock.Advance(6 * time.Second)
+		if _, ok := c.Get("sliding"); !ok {
+			t.Fatalf("Sliding key expired after %v", time.Duration(i+1)*6*time.Second)
+		}
+		if ttl, _ := c.TTL("sliding"); ttl != 10*time.Second {
+			t.Fatalf("TTL after a read = %v, want 10s", ttl)
+		}
+	}
+	if _, ok := c.Get("fixed"); ok {
+		t.Fatalf("Reads extended a key without sliding expiration")
+	}
+
+	// It still expires once left unread for its TTL
+	clock.Advance(10 * time.Second)
+	if _, ok := c.Get("sliding"); ok {
+		t.Fatalf("Sliding key outlived its TTL without reads")
+	}
+
+	// Expire replaces the sliding expiration with a fixed one
+	c.Set("sliding", "v", 10*time.Second, cache.WithSlidingExpiration())
+	c.Expire("sliding", 10*time.Second)
+	clock.Advance(6 * time.Second)
+	c.Get("sliding")
+	clock.Advance(6 * time.Second)
+	if _, ok := c.Get("sliding"); ok {
+		t.Fatalf("Reads extended a key after Expire")
+	}
+
+	// Without a TTL the option has nothing to extend
+	c.Set("forever", "v", 0, cache.WithSlidingExpiration())
+	if ttl, ok := c.TTL("forever"); !ok || ttl != 0 {
+		t.Fatalf("TTL of a sliding key without TTL = %v, %v", ttl, ok)
+	}
+}
+
+func TestSlidingGranularity(t *testing.T) {
+	walDir := t.TempDir()
+	clock := newFakeClock()
+	c, err := cache.NewLRUCache(10, walDir, false, 10*1024*1024, 10, cache.WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	c.SetSlidingGranularity(5 * time.Second)
+	c.Set("k", "v", time.Minute, cache.WithSlidingExpiration())
+
+	// Reads that move the deadline less than the granularity write nothing
+	before := walSize(t, walDir)
+	for i := 0; i < 4; i++ {
+		clock.Advance(time.Second)
+		c.Get("k")
+	}
+	if grown := walSize(t, walDir) - before; grown != 0 {
+		t.Fatalf("Reads within the granularity wrote %d bytes", grown)
+	}
+	clock.Advance(time.Second)
+	c.Get("k")
+	if walSize(t, walDir) == before {
+		t.Fatalf("Extension reaching the granularity was not logged")
+	}
+
+	// An unlogged extension is lost in a crash, but by less than the granularity
+	clock.Advance(4 * time.Second)
+	c.Get("k")
+	want, _ := c.TTL("k")
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	recovered, err := cache.NewLRUCache(10, walDir, false, 10*1024*1024, 10, cache.WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to recover cache: %v", err)
+	}
+	defer recovered.Close()
+
+	ttl, ok := recovered.TTL("k")
+	if !ok || ttl > want || want-ttl >= 5*time.Second {
+		t.Fatalf("Recovered TTL = %v, %v, want within 5s of %v", ttl, ok, want)
+	}
+	// The window survives recovery, so reads slide the full TTL again
+	recovered.Get("k")
+	if ttl, _ := recovered.TTL("k"); ttl != time.Minute {
+		t.Fatalf("TTL after a read of the recovered key = %v, want 1m", ttl)
+	}
+}
+
+func TestSlidingRecoveryWithUpdates(t *testing.T) {
+	for _, snapshot := range []bool{false, true} {
+		walDir := t.TempDir()
+		clock := newFakeClock()
+		c, err := cache.NewLRUCache(10, walDir, false, 10*1024*1024, 10, cache.WithClock(clock))
+		if err != nil {
+			t.Fatalf("Failed to create cache: %v", err)
+		}
+		c.SetSlidingGranularity(time.Hour)
+		c.Set("k", "v", 10*time.Second, cache.WithSlidingExpiration())
+		c.Set("list", cache.List{"a"}, 10*time.Second, cache.WithSlidingExpiration())
+
+		// Reads extend the deadlines past the logged ones without logging
+		// them, then updates are logged with the extended deadlines
+		for i := 0; i < 2; i++ {
+			clock.Advance(8 * time.Second)
+			c.Get("k")
+			c.Get("list")
+		}
+		c.Set("k", "w", 10*time.Second, cache.WithSlidingExpiration())
+		c.RPush("list", "b", "c")
+		clock.Advance(8 * time.Second)
+		c.Get("list")
+		c.LPop("list")
+
+		if snapshot {
+			if err := c.Snapshot(); err != nil {
+				t.Fatalf("Snapshot failed: %v", err)
+			}
+		}
+		live := expiryState(c, []string{"k", "list"})
+		if err := c.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+
+		recovered, err := cache.NewLRUCache(10, walDir, false, 10*1024*1024, 10, cache.WithClock(clock))
+		if err != nil {
+			t.Fatalf("Failed to recover cache: %v", err)
+		}
+		if got := expiryState(recovered, []string{"k", "list"}); got != live {
+			t.Errorf("snapshot=%v: recovered state differs\nlive:      %s\nrecovered: %s", snapshot, live, got)
+		}
+		// Recovered keys keep sliding
+		clock.Advance(8 * time.Second)
+		recovered.Get("k")
+		clock.Advance(8 * time.Second)
+		if _, ok := recovered.Get("k"); !ok {
+			t.Errorf("snapshot=%v: recovered key stopped sliding", snapshot)
+		}
+		recovered.Close()
+	}
+}
+
+func TestSetSlidingEndpoint(t *testing.T) {
+	c, err := cache.NewLRUCache(10, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	srv := httptest.NewServer(server.New(c))
+	defer srv.Close()
+
+	tests := []struct {
+		path     string
+		status   int
+		response string
+	}{
+		{"/set?key=k&value=v&ttl=1h&sliding=maybe", http.StatusBadRequest, "sliding must be true or false"},
+		{"/set?key=k&value=v&ttl=1h&sliding=true", http.StatusOK, "OK"},
+	}
+	for _, tt := range tests {
+		resp, err := http.Post(srv.URL+tt.path, "", nil)
+		if err != nil {
+			t.Fatalf("POST %s failed: %v", tt.path, err)
+		}
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tt.status || strings.TrimSpace(string(data)) != tt.response {
+			t.Errorf("POST %s = %d %q, want %d %q", tt.path, resp.StatusCode, data, tt.status, tt.response)
+		}
+	}
+
+	// A read restarts the TTL the key was set with
+	time.Sleep(10 * time.Millisecond)
+	if _, err := http.Get(srv.URL + "/get?key=k"); err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	if ttl, _ := c.TTL("k"); ttl < time.Hour-5*time.Millisecond {
+		t.Errorf("TTL after /get = %v, want close to 1h", ttl)
+	}
+}
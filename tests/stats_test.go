@@ -0,0 +1,114 @@
+package main_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nishanth-gowda/kv-store/cache"
+	"github.com/nishanth-gowda/kv-store/server"
+)
+
+func TestStats(t *testing.T) {
+	c, err := cache.NewLRUCache(2, t.TempDir(), false, 10*1024*1024, 10)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	c.Set("a", "1", 0)
+	time.Sleep(10 * time.Millisecond)
+	c.Set("b", "2", 0)
+	c.Set("c", "3", 0) // evicts a
+	c.Get("b")
+	c.Get("a")
+
+	stats, err := c.Stats()
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Evictions != 1 {
+		t.Errorf("counters = %+v", stats)
+	}
+	if stats.HitRatio() != 0.5 {
+		t.Errorf("HitRatio = %v, want 0.5", stats.HitRatio())
+	}
+	if stats.Size != 2 || stats.Capacity != 2 || stats.Bytes <= 0 {
+		t.Errorf("occupancy = %+v", stats)
+	}
+	if stats.OldestEntryAge <= 0 || stats.OldestEntryAge >= 10*time.Millisecond {
+		t.Errorf("OldestEntryAge = %v, want the age of b", stats.OldestEntryAge)
+	}
+	if stats.WAL == nil || stats.WAL.LastSequenceNumber != 3 || stats.WAL.CurrentSegment != "wal-segment-0" || stats.WAL.DiskBytes <= 0 {
+		t.Errorf("WAL stats = %+v", stats.WAL)
+	}
+
+	c.ResetStats()
+	stats, _ = c.Stats()
+	if stats.Hits != 0 || stats.Misses != 0 || stats.Evictions != 0 || stats.Size != 2 {
+		t.Errorf("after ResetStats = %+v", stats)
+	}
+	c.Get("b")
+	if stats, _ = c.Stats(); stats.Hits != 1 || stats.Misses != 0 {
+		t.Errorf("after a hit following ResetStats = %+v", stats)
+	}
+}
+
+// Prometheus counters must never decrease, so ResetStats leaves them alone
+func TestResetStatsKeepsMetrics(t *testing.T) {
+	c, err := cache.NewLRUCache(10, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+	handler := server.New(c)
+
+	c.Set("a", "1", 0)
+	c.Get("a")
+	c.Get("missing")
+	c.ResetStats()
+	c.Get("a")
+
+	samples, _ := scrapeMetrics(t, handler)
+	if samples["kv_cache_hits_total"] != 2 || samples["kv_cache_misses_total"] != 1 {
+		t.Errorf("metrics after ResetStats: hits %v, misses %v, want 2 and 1",
+			samples["kv_cache_hits_total"], samples["kv_cache_misses_total"])
+	}
+	if stats, _ := c.Stats(); stats.Hits != 1 || stats.Misses != 0 {
+		t.Errorf("Stats after ResetStats = %+v, want 1 hit", stats)
+	}
+}
+
+func TestStatsEndpoint(t *testing.T) {
+	c, err := cache.NewLRUCache(10, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	c.Set("a", "1", 0)
+	c.Get("a")
+
+	handler := server.New(c)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats", nil))
+
+	var resp server.StatsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON %q: %v", rec.Body.String(), err)
+	}
+	if resp.Hits != 1 || resp.Size != 1 || resp.Capacity != 10 || resp.WAL != nil {
+		t.Errorf("GET /stats = %+v", resp)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/stats/reset", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /stats/reset returned %d", rec.Code)
+	}
+	if stats, _ := c.Stats(); stats.Hits != 0 {
+		t.Errorf("hits after reset = %d", stats.Hits)
+	}
+}
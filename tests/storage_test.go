@@ -0,0 +1,170 @@
+package main_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/nishanth-gowda/kv-store/cache"
+	"github.com/nishanth-gowda/kv-store/wal"
+)
+
+func TestStorageImplementations(t *testing.T) {
+	storages := map[string]func(t *testing.T) wal.Storage{
+		"os": func(t *testing.T) wal.Storage {
+			storage, err := wal.NewOSStorage(t.TempDir())
+			if err != nil {
+				t.Fatalf("NewOSStorage failed: %v", err)
+			}
+			return storage
+		},
+		"memory": func(t *testing.T) wal.Storage {
+			return wal.NewMemoryStorage()
+		},
+	}
+
+	for name, newStorage := range storages {
+		t.Run(name, func(t *testing.T) {
+			storage := newStorage(t)
+			read := func(name string) string {
+				t.Helper()
+				r, err := storage.OpenRead(name)
+				if err != nil {
+					t.Fatalf("OpenRead(%s) failed: %v", name, err)
+				}
+				defer r.Close()
+				data, err := io.ReadAll(r)
+				if err != nil {
+					t.Fatalf("Failed to read %s: %v", name, err)
+				}
+				return string(data)
+			}
+			write := func(file wal.File, s string) {
+				t.Helper()
+				if _, err := io.WriteString(file, s); err != nil {
+					t.Fatalf("Write failed: %v", err)
+				}
+				if err := file.Sync(); err != nil {
+					t.Fatalf("Sync failed: %v", err)
+				}
+				if err := file.Close(); err != nil {
+					t.Fatalf("Close failed: %v", err)
+				}
+			}
+
+			// OpenAppend creates a file and then appends to it
+			for _, s := range []string{"a", "b"} {
+				file, err := storage.OpenAppend("seg-1")
+				if err != nil {
+					t.Fatalf("OpenAppend failed: %v", err)
+				}
+				write(file, s)
+			}
+			if got := read("seg-1"); got != "ab" {
+				t.Errorf("seg-1 = %q, want %q", got, "ab")
+			}
+			if size, err := storage.Size("seg-1"); err != nil || size != 2 {
+				t.Errorf("Size(seg-1) = %d, %v", size, err)
+			}
+
+			// Create truncates, and Rename replaces the target
+			for _, name := range []string{"tmp", "tmp"} {
+				file, err := storage.Create(name)
+				if err != nil {
+					t.Fatalf("Create failed: %v", err)
+				}
+				write(file, "new")
+			}
+			if err := storage.Rename("tmp", "seg-1"); err != nil {
+				t.Fatalf("Rename failed: %v", err)
+			}
+			if got := read("seg-1"); got != "new" {
+				t.Errorf("seg-1 after Rename = %q, want %q", got, "new")
+			}
+
+			file, err := storage.OpenAppend("seg-2")
+			if err != nil {
+				t.Fatalf("OpenAppend failed: %v", err)
+			}
+			file.Close()
+			names, err := storage.List("seg-")
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+			slices.Sort(names)
+			if !slices.Equal(names, []string{"seg-1", "seg-2"}) {
+				t.Errorf("List = %v, want [seg-1 seg-2]", names)
+			}
+
+			if err := storage.Remove("seg-1"); err != nil {
+				t.Fatalf("Remove failed: %v", err)
+			}
+			if err := storage.Sync(); err != nil {
+				t.Fatalf("Sync failed: %v", err)
+			}
+			if _, err := storage.OpenRead("seg-1"); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("OpenRead of a removed file returned %v, want fs.ErrNotExist", err)
+			}
+			if _, err := storage.Size("missing"); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("Size of a missing file returned %v, want fs.ErrNotExist", err)
+			}
+		})
+	}
+}
+
+func TestCacheWithMemoryStorage(t *testing.T) {
+	storage := wal.NewMemoryStorage()
+	open := func() *cache.LRUCache {
+		t.Helper()
+		// A small segment size so the WAL rotates and drops old segments
+		c, err := cache.NewLRUCache(100, "", false, 1024, 3, cache.WithWALStorage(storage))
+		if err != nil {
+			t.Fatalf("Failed to open cache: %v", err)
+		}
+		return c
+	}
+
+	wd, _ := os.Getwd()
+	before, _ := os.ReadDir(wd)
+
+	c := open()
+	for i := 0; i < 20; i++ {
+		c.Set(fmt.Sprintf("key-%d", i), fmt.Sprintf("value-%d", i), 0)
+	}
+	c.HSet("hash", map[string]string{"field": "value"})
+	if err := c.Snapshot(); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	c.Delete("key-0")
+	c.Set("key-1", "updated", 0)
+	stats, _ := c.Stats()
+	if stats.WAL == nil || stats.WAL.DiskBytes == 0 {
+		t.Fatalf("Stats.WAL = %+v, want the WAL in memory", stats.WAL)
+	}
+	want := expiryState(c, []string{"key-0", "key-1", "key-19"})
+	c.Close()
+
+	recovered := open()
+	defer recovered.Close()
+	if got := expiryState(recovered, []string{"key-0", "key-1", "key-19"}); got != want {
+		t.Errorf("Recovered state = %s, want %s", got, want)
+	}
+	if value, _, _ := recovered.HGet("hash", "field"); value != "value" {
+		t.Errorf("HGet(hash, field) = %v", value)
+	}
+
+	// Nothing was written to the working directory
+	after, _ := os.ReadDir(wd)
+	if len(after) != len(before) {
+		t.Errorf("Working directory changed from %d to %d entries", len(before), len(after))
+	}
+
+	names, _ := storage.List("")
+	if !slices.Contains(names, "snapshot") {
+		t.Errorf("Storage holds %v, want a snapshot", names)
+	}
+}
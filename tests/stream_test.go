@@ -0,0 +1,377 @@
+package main_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nishanth-gowda/kv-store/cache"
+	"github.com/nishanth-gowda/kv-store/server"
+)
+
+// streamIDs returns the IDs of entries
+func streamIDs(entries []cache.StreamEntry) []cache.StreamID {
+	ids := make([]cache.StreamID, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.ID
+	}
+	return ids
+}
+
+func TestStreamOperations(t *testing.T) {
+	c, err := cache.NewLRUCache(10, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	// Generated IDs increase even within the same millisecond
+	var ids []cache.StreamID
+	for i := 0; i < 3; i++ {
+		id, err := c.XAdd("events", cache.StreamID{}, map[string]string{"n": string(rune('a' + i))})
+		if err != nil {
+			t.Fatalf("XAdd failed: %v", err)
+		}
+		if len(ids) > 0 && id.Compare(ids[len(ids)-1]) <= 0 {
+			t.Fatalf("XAdd returned %v after %v", id, ids[len(ids)-1])
+		}
+		ids = append(ids, id)
+	}
+
+	if _, err := c.XAdd("events", ids[2], map[string]string{"n": "x"}); !errors.Is(err, cache.ErrStreamIDTooSmall) {
+		t.Fatalf("XAdd with a used ID returned %v", err)
+	}
+	explicit := cache.StreamID{Ms: ids[2].Ms + 1000, Seq: 5}
+	if id, err := c.XAdd("events", explicit, map[string]string{"n": "d"}); err != nil || id != explicit {
+		t.Fatalf("XAdd with an explicit ID = %v, %v", id, err)
+	}
+	ids = append(ids, explicit)
+
+	if n, _ := c.XLen("events"); n != 4 {
+		t.Fatalf("XLen = %d, want 4", n)
+	}
+
+	tests := []struct {
+		start, end cache.StreamID
+		count      int
+		want       []cache.StreamID
+	}{
+		{cache.StreamID{}, cache.LastStreamID, 0, ids},
+		{ids[1], ids[2], 0, ids[1:3]},
+		{cache.StreamID{}, cache.LastStreamID, 2, ids[:2]},
+		{ids[3], ids[0], 0, []cache.StreamID{}},
+	}
+	for _, tt := range tests {
+		got, err := c.XRange("events", tt.start, tt.end, tt.count)
+		if err != nil || !reflect.DeepEqual(streamIDs(got), tt.want) {
+			t.Errorf("XRange(%v, %v, %d) = %v, %v, want %v", tt.start, tt.end, tt.count, streamIDs(got), err, tt.want)
+		}
+	}
+
+	entries, _ := c.XRange("events", ids[0], ids[0], 0)
+	if !reflect.DeepEqual(entries[0].Fields, map[string]string{"n": "a"}) {
+		t.Fatalf("entry fields = %v", entries[0].Fields)
+	}
+
+	read, err := c.XRead(map[string]cache.StreamID{"events": ids[1], "missing": {}}, 1)
+	if err != nil || len(read) != 1 || !reflect.DeepEqual(streamIDs(read["events"]), ids[2:3]) {
+		t.Fatalf("XRead = %v, %v", read, err)
+	}
+
+	c.Set("plain", "value", 0)
+	if _, err := c.XAdd("plain", cache.StreamID{}, map[string]string{"f": "v"}); !errors.Is(err, cache.ErrWrongType) {
+		t.Fatalf("XAdd on a string returned %v", err)
+	}
+
+	for input, want := range map[string]cache.StreamID{"5": {Ms: 5}, "5-7": {Ms: 5, Seq: 7}} {
+		if id, err := cache.ParseStreamID(input); err != nil || id != want {
+			t.Errorf("ParseStreamID(%q) = %v, %v", input, id, err)
+		}
+	}
+	for _, input := range []string{"", "x", "1-", "-1", "1-2-3"} {
+		if _, err := cache.ParseStreamID(input); !errors.Is(err, cache.ErrInvalidStreamID) {
+			t.Errorf("ParseStreamID(%q) returned %v", input, err)
+		}
+	}
+}
+
+func TestBlockingStreamRead(t *testing.T) {
+	c, err := cache.NewLRUCache(10, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	first, _ := c.XAdd("events", cache.StreamID{}, map[string]string{"n": "1"})
+
+	// Existing entries are returned immediately, but $ waits for new ones
+	read, err := c.XReadBlock(context.Background(), time.Second, map[string]cache.StreamID{"events": {}}, 0)
+	if err != nil || !reflect.DeepEqual(streamIDs(read["events"]), []cache.StreamID{first}) {
+		t.Fatalf("XReadBlock = %v, %v", read, err)
+	}
+	if read, err := c.XReadBlock(context.Background(), 20*time.Millisecond, map[string]cache.StreamID{"events": cache.LastStreamID}, 0); err != nil || len(read) != 0 {
+		t.Fatalf("XReadBlock with $ and no new entries = %v, %v", read, err)
+	}
+
+	type result struct {
+		read map[string][]cache.StreamEntry
+		err  error
+	}
+	results := make(chan result)
+	go func() {
+		read, err := c.XReadBlock(context.Background(), 0, map[string]cache.StreamID{"events": cache.LastStreamID}, 0)
+		results <- result{read, err}
+	}()
+	time.Sleep(10 * time.Millisecond)
+	second, _ := c.XAdd("events", cache.StreamID{}, map[string]string{"n": "2"})
+
+	select {
+	case r := <-results:
+		if r.err != nil || !reflect.DeepEqual(streamIDs(r.read["events"]), []cache.StreamID{second}) {
+			t.Fatalf("blocked XReadBlock = %v, %v", r.read, r.err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("blocked XReadBlock did not wake up")
+	}
+
+	// A missing group fails instead of blocking
+	go func() {
+		read, err := c.XReadGroupBlock(context.Background(), 0, "g", "c", 0, "missing")
+		results <- result{read, err}
+	}()
+	if r := <-results; !errors.Is(r.err, cache.ErrNoGroup) {
+		t.Fatalf("XReadGroupBlock without a group returned %v", r.err)
+	}
+
+	// Close releases blocked reads
+	go func() {
+		read, err := c.XReadBlock(context.Background(), 0, map[string]cache.StreamID{"events": cache.LastStreamID}, 0)
+		results <- result{read, err}
+	}()
+	time.Sleep(10 * time.Millisecond)
+	c.Close()
+	select {
+	case r := <-results:
+		if !errors.Is(r.err, cache.ErrClosed) {
+			t.Fatalf("XReadBlock after Close returned %v", r.err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Close did not release a blocked XReadBlock")
+	}
+}
+
+func TestStreamConsumerGroups(t *testing.T) {
+	c, err := cache.NewLRUCache(10, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	var ids []cache.StreamID
+	for i := 0; i < 3; i++ {
+		id, _ := c.XAdd("jobs", cache.StreamID{}, map[string]string{"job": "work"})
+		ids = append(ids, id)
+	}
+
+	if err := c.XGroupCreate("jobs", "workers", cache.StreamID{}); err != nil {
+		t.Fatalf("XGroupCreate failed: %v", err)
+	}
+	if err := c.XGroupCreate("jobs", "workers", cache.StreamID{}); !errors.Is(err, cache.ErrGroupExists) {
+		t.Fatalf("duplicate XGroupCreate returned %v", err)
+	}
+	if _, err := c.XReadGroup("nobody", "c1", 0, "jobs"); !errors.Is(err, cache.ErrNoGroup) {
+		t.Fatalf("XReadGroup without a group returned %v", err)
+	}
+
+	// Each entry is delivered to one consumer of the group
+	read, err := c.XReadGroup("workers", "c1", 2, "jobs")
+	if err != nil || !reflect.DeepEqual(streamIDs(read["jobs"]), ids[:2]) {
+		t.Fatalf("XReadGroup for c1 = %v, %v", read, err)
+	}
+	read, err = c.XReadGroup("workers", "c2", 0, "jobs")
+	if err != nil || !reflect.DeepEqual(streamIDs(read["jobs"]), ids[2:]) {
+		t.Fatalf("XReadGroup for c2 = %v, %v", read, err)
+	}
+	if read, _ := c.XReadGroup("workers", "c1", 0, "jobs"); len(read) != 0 {
+		t.Fatalf("XReadGroup with nothing new = %v", read)
+	}
+
+	if n, err := c.XAck("jobs", "workers", ids[0], ids[0], cache.StreamID{Ms: 1}); err != nil || n != 1 {
+		t.Fatalf("XAck = %d, %v", n, err)
+	}
+	pending, _ := c.XPending("jobs", "workers")
+	if len(pending) != 2 || pending[0].ID != ids[1] || pending[0].Consumer != "c1" || pending[1].Consumer != "c2" {
+		t.Fatalf("XPending = %+v", pending)
+	}
+
+	// A claim moves an idle entry to another consumer and counts the delivery
+	if claimed, _ := c.XClaim("jobs", "workers", "c2", time.Hour, ids[1]); len(claimed) != 0 {
+		t.Fatalf("XClaim of a recently delivered entry = %v", claimed)
+	}
+	claimed, err := c.XClaim("jobs", "workers", "c2", 0, ids[1], ids[0])
+	if err != nil || !reflect.DeepEqual(streamIDs(claimed), ids[1:2]) {
+		t.Fatalf("XClaim = %v, %v", claimed, err)
+	}
+	pending, _ = c.XPending("jobs", "workers")
+	if pending[0].Consumer != "c2" || pending[0].Deliveries != 2 {
+		t.Fatalf("claimed pending entry = %+v", pending[0])
+	}
+
+	// A group starting at $ only sees later entries
+	c.XGroupCreate("jobs", "latecomers", cache.LastStreamID)
+	fourth, _ := c.XAdd("jobs", cache.StreamID{}, map[string]string{"job": "more"})
+	read, _ = c.XReadGroup("latecomers", "c1", 0, "jobs")
+	if !reflect.DeepEqual(streamIDs(read["jobs"]), []cache.StreamID{fourth}) {
+		t.Fatalf("XReadGroup from $ = %v", read)
+	}
+}
+
+func TestStreamRecovery(t *testing.T) {
+	walDir := t.TempDir()
+
+	c, err := cache.NewLRUCache(10, walDir, false, 10*1024*1024, 10)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	var ids []cache.StreamID
+	for i := 0; i < 3; i++ {
+		id, _ := c.XAdd("jobs", cache.StreamID{}, map[string]string{"job": "work"})
+		ids = append(ids, id)
+	}
+	c.XGroupCreate("jobs", "workers", cache.StreamID{})
+	c.XReadGroup("workers", "c1", 2, "jobs")
+	c.XAck("jobs", "workers", ids[0])
+	c.XClaim("jobs", "workers", "c2", 0, ids[1])
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	check := func(c *cache.LRUCache) {
+		t.Helper()
+		entries, _ := c.XRange("jobs", cache.StreamID{}, cache.LastStreamID, 0)
+		if !reflect.DeepEqual(streamIDs(entries), ids) {
+			t.Fatalf("recovered entries = %v, want %v", streamIDs(entries), ids)
+		}
+		pending, err := c.XPending("jobs", "workers")
+		if err != nil || len(pending) != 1 || pending[0].ID != ids[1] || pending[0].Consumer != "c2" || pending[0].Deliveries != 2 {
+			t.Fatalf("recovered pending entries = %+v, %v", pending, err)
+		}
+		// The group resumes after the last delivered entry
+		read, _ := c.XReadGroup("workers", "c3", 0, "jobs")
+		if !reflect.DeepEqual(streamIDs(read["jobs"]), ids[2:]) {
+			t.Fatalf("XReadGroup after recovery = %v", read)
+		}
+		c.XAck("jobs", "workers", ids[2])
+	}
+
+	recovered, err := cache.NewLRUCache(10, walDir, false, 10*1024*1024, 10)
+	if err != nil {
+		t.Fatalf("Failed to recover cache: %v", err)
+	}
+	check(recovered)
+	// New IDs stay greater than recovered ones
+	if id, _ := recovered.XAdd("jobs", cache.StreamID{}, map[string]string{"job": "new"}); id.Compare(ids[2]) <= 0 {
+		t.Fatalf("XAdd after recovery returned %v", id)
+	}
+	if err := recovered.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// Streams and their groups survive a snapshot as whole values
+	c, err = cache.NewLRUCache(10, walDir, false, 10*1024*1024, 10)
+	if err != nil {
+		t.Fatalf("Failed to recover cache: %v", err)
+	}
+	c.XClaim("jobs", "workers", "c1", 0, ids[1])
+	if err := c.Snapshot(); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	c.XClaim("jobs", "workers", "c2", 0, ids[1])
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	again, err := cache.NewLRUCache(10, walDir, false, 10*1024*1024, 10)
+	if err != nil {
+		t.Fatalf("Failed to recover cache: %v", err)
+	}
+	defer again.Close()
+	pending, _ := again.XPending("jobs", "workers")
+	if len(pending) != 1 || pending[0].Consumer != "c2" || pending[0].Deliveries != 4 {
+		t.Fatalf("pending entries after snapshot = %+v", pending)
+	}
+	if n, _ := again.XLen("jobs"); n != 4 {
+		t.Fatalf("XLen after snapshot = %d, want 4", n)
+	}
+}
+
+func TestStreamEndpoints(t *testing.T) {
+	c, err := cache.NewLRUCache(10, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	srv := httptest.NewServer(server.New(c))
+	defer srv.Close()
+
+	request := func(method, path, body string) (int, string) {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, strings.TrimSpace(string(data))
+	}
+
+	tests := []struct {
+		method, path, body string
+		status             int
+		response           string
+	}{
+		{http.MethodPost, "/xadd?key=s&id=1-1", `{"f":"a"}`, http.StatusOK, `{"id":"1-1"}`},
+		{http.MethodPost, "/xadd?key=s&id=2", `{"f":"b"}`, http.StatusOK, `{"id":"2-0"}`},
+		{http.MethodPost, "/xadd?key=s&id=2", `{"f":"c"}`, http.StatusBadRequest, cache.ErrStreamIDTooSmall.Error()},
+		{http.MethodPost, "/xadd?key=s", `{}`, http.StatusBadRequest, "at least one field is required"},
+		{http.MethodGet, "/xlen?key=s", "", http.StatusOK, `{"length":2}`},
+		{http.MethodGet, "/xrange?key=s", "", http.StatusOK, `[{"id":"1-1","fields":{"f":"a"}},{"id":"2-0","fields":{"f":"b"}}]`},
+		{http.MethodGet, "/xrange?key=s&start=2&end=%2B", "", http.StatusOK, `[{"id":"2-0","fields":{"f":"b"}}]`},
+		{http.MethodGet, "/xread?key=s&id=1-1", "", http.StatusOK, `{"s":[{"id":"2-0","fields":{"f":"b"}}]}`},
+		{http.MethodGet, "/xread?key=s&id=$&block=10ms", "", http.StatusOK, `{}`},
+		{http.MethodGet, "/xread?key=s", "", http.StatusBadRequest, "each key requires an id"},
+		{http.MethodPost, "/xgroup?key=s&group=g&id=0", "", http.StatusOK, "OK"},
+		{http.MethodPost, "/xgroup?key=s&group=g", "", http.StatusConflict, cache.ErrGroupExists.Error()},
+		{http.MethodPost, "/xreadgroup?group=g&consumer=c1&key=s&count=1", "", http.StatusOK, `{"s":[{"id":"1-1","fields":{"f":"a"}}]}`},
+		{http.MethodPost, "/xreadgroup?group=g&consumer=c1&key=s", "", http.StatusOK, `{"s":[{"id":"2-0","fields":{"f":"b"}}]}`},
+		{http.MethodPost, "/xreadgroup?group=nope&consumer=c1&key=s", "", http.StatusNotFound, cache.ErrNoGroup.Error()},
+		{http.MethodPost, "/xclaim?key=s&group=g&consumer=c2&id=1-1", "", http.StatusOK, `[{"id":"1-1","fields":{"f":"a"}}]`},
+		{http.MethodPost, "/xack?key=s&group=g&id=1-1&id=3-0", "", http.StatusOK, `{"acknowledged":1}`},
+	}
+	for _, tt := range tests {
+		status, body := request(tt.method, tt.path, tt.body)
+		if status != tt.status || body != tt.response {
+			t.Errorf("%s %s = %d %q, want %d %q", tt.method, tt.path, status, body, tt.status, tt.response)
+		}
+	}
+
+	// A blocked group read is served by a later XADD
+	result := make(chan string, 1)
+	go func() {
+		_, body := request(http.MethodPost, "/xreadgroup?group=g&consumer=c1&key=s&block=5s", "")
+		result <- body
+	}()
+	time.Sleep(20 * time.Millisecond)
+	request(http.MethodPost, "/xadd?key=s&id=3-0", `{"f":"c"}`)
+	if body := <-result; body != `{"s":[{"id":"3-0","fields":{"f":"c"}}]}` {
+		t.Fatalf("blocked /xreadgroup got %s", body)
+	}
+}
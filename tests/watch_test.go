@@ -0,0 +1,247 @@
+package main_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nishanth-gowda/kv-store/cache"
+	"github.com/nishanth-gowda/kv-store/server"
+)
+
+// nextEvent waits briefly for the next event on a watcher
+func nextEvent(t *testing.T, w *cache.Watcher) cache.Event {
+	t.Helper()
+	select {
+	case event, ok := <-w.C:
+		if !ok {
+			t.Fatalf("watcher closed: %v", w.Err())
+		}
+		return event
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for event")
+	}
+	return cache.Event{}
+}
+
+func TestWatchKeyAndPrefix(t *testing.T) {
+	c, err := cache.NewLRUCache(2, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	keyWatcher, err := c.Watch("user:1")
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	prefixWatcher, err := c.WatchPrefix("user:")
+	if err != nil {
+		t.Fatalf("WatchPrefix failed: %v", err)
+	}
+
+	c.Set("user:1", "alice", 0)
+	c.Set("other", "x", 0)
+	c.Set("user:2", "bob", time.Millisecond) // evicts user:1
+	time.Sleep(5 * time.Millisecond)
+	c.Get("user:2") // expires
+	c.Set("user:3", "carol", 0)
+	c.Delete("user:3")
+
+	want := []struct {
+		typ cache.EventType
+		key string
+	}{
+		{cache.EventSet, "user:1"},
+		{cache.EventEvict, "user:1"},
+		{cache.EventSet, "user:2"},
+		{cache.EventExpire, "user:2"},
+		{cache.EventSet, "user:3"},
+		{cache.EventDelete, "user:3"},
+	}
+	var lastSeq uint64
+	for _, w := range want {
+		event := nextEvent(t, prefixWatcher)
+		if event.Type != w.typ || event.Key != w.key {
+			t.Fatalf("got %s %s, want %s %s", event.Type, event.Key, w.typ, w.key)
+		}
+		if event.Sequence <= lastSeq {
+			t.Fatalf("sequence %d not increasing after %d", event.Sequence, lastSeq)
+		}
+		lastSeq = event.Sequence
+	}
+
+	if event := nextEvent(t, keyWatcher); event.Type != cache.EventSet || event.Value != "alice" {
+		t.Fatalf("key watcher got %+v", event)
+	}
+	if event := nextEvent(t, keyWatcher); event.Type != cache.EventEvict {
+		t.Fatalf("key watcher got %+v", event)
+	}
+
+	keyWatcher.Close()
+	if _, ok := <-keyWatcher.C; ok {
+		t.Fatalf("channel still open after Close")
+	}
+}
+
+func TestWatchResumeAndOverflow(t *testing.T) {
+	c, err := cache.NewLRUCache(100000, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	c.Set("a", "1", 0)
+	c.Set("b", "2", 0)
+	c.Set("a", "3", 0)
+
+	// Resume after the first event replays only later matching events
+	w, err := c.Watch("a", cache.FromSequence(1))
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	if event := nextEvent(t, w); event.Sequence != 3 || event.Value != "3" {
+		t.Fatalf("resumed watcher got %+v", event)
+	}
+	w.Close()
+
+	// A watcher that never reads is closed once its buffer fills
+	slow, err := c.WatchPrefix("")
+	if err != nil {
+		t.Fatalf("WatchPrefix failed: %v", err)
+	}
+	for i := 0; i < 2000; i++ {
+		c.Set(fmt.Sprintf("key-%d", i), "v", 0)
+	}
+	for range slow.C {
+	}
+	if !errors.Is(slow.Err(), cache.ErrWatcherOverflow) {
+		t.Fatalf("slow watcher Err = %v, want ErrWatcherOverflow", slow.Err())
+	}
+
+	// The first events have dropped out of the history
+	if _, err := c.Watch("a", cache.FromSequence(1)); !errors.Is(err, cache.ErrHistoryCompacted) {
+		t.Fatalf("Watch from compacted sequence returned %v", err)
+	}
+
+	// So have none after the last one
+	if _, err := c.Watch("a", cache.FromSequence(5000)); !errors.Is(err, cache.ErrHistoryCompacted) {
+		t.Fatalf("Watch from a future sequence returned %v", err)
+	}
+}
+
+func TestWatchEndpoint(t *testing.T) {
+	c, err := cache.NewLRUCache(10, "", false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer c.Close()
+
+	c.Set("k", "before", 0)
+
+	srv := httptest.NewServer(server.New(c))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/watch?prefix=k", nil)
+	req.Header.Set("Last-Event-ID", "0")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /watch failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	c.Set("k", "after", 0)
+
+	reader := bufio.NewReader(resp.Body)
+	var events []server.WatchEvent
+	var ids []string
+	for len(events) < 2 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading stream: %v", err)
+		}
+		if id, ok := strings.CutPrefix(strings.TrimSpace(line), "id: "); ok {
+			ids = append(ids, id)
+		}
+		if data, ok := strings.CutPrefix(strings.TrimSpace(line), "data: "); ok {
+			var event server.WatchEvent
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				t.Fatalf("invalid event data %q: %v", data, err)
+			}
+			events = append(events, event)
+		}
+	}
+
+	if ids[0] != c.WatchEpoch()+"-1" {
+		t.Fatalf("first event id = %q, want %q", ids[0], c.WatchEpoch()+"-1")
+	}
+	if events[0].Value != "before" || events[0].Sequence != 1 || events[1].Value != "after" || events[1].Type != "set" {
+		t.Fatalf("events = %+v", events)
+	}
+
+	// Resuming from a sequence that is no longer retained is rejected
+	for i := 0; i < 2000; i++ {
+		c.Set("other", "v", 0)
+	}
+	compacted, err := http.Get(srv.URL + "/watch?key=k&since=1")
+	if err != nil {
+		t.Fatalf("GET /watch failed: %v", err)
+	}
+	compacted.Body.Close()
+	if compacted.StatusCode != http.StatusGone {
+		t.Fatalf("compacted resume returned %d, want 410", compacted.StatusCode)
+	}
+}
+
+func TestWatchEndpointAfterRestart(t *testing.T) {
+	walDir := t.TempDir()
+	c, err := cache.NewLRUCache(10, walDir, false, 10*1024*1024, 10)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	c.Set("k", "1", 0)
+	c.Set("k", "2", 0)
+	oldID := c.WatchEpoch() + "-2"
+	c.Close()
+
+	// The reopened cache numbers its events from 1 again
+	c, err = cache.NewLRUCache(10, walDir, false, 10*1024*1024, 10)
+	if err != nil {
+		t.Fatalf("Failed to reopen cache: %v", err)
+	}
+	defer c.Close()
+	c.Set("k", "3", 0)
+
+	srv := httptest.NewServer(server.New(c))
+	defer srv.Close()
+
+	tests := []struct {
+		since string
+		want  int
+	}{
+		{oldID, http.StatusGone},
+		{"2", http.StatusGone},
+		{c.WatchEpoch() + "-x", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/watch?key=k", nil)
+		req.Header.Set("Last-Event-ID", tt.since)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET /watch failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Fatalf("resume after %q returned %d, want %d", tt.since, resp.StatusCode, tt.want)
+		}
+	}
+}
@@ -1,8 +1,6 @@
 package utils
 
 import (
-	"fmt"
-	"os"
 	"path/filepath"
 	"sort"
 	"strconv"
@@ -42,16 +40,6 @@ func GetLastSegmentID(files []string) (int, error) {
 	return maxID, nil
 }
 
-// Create a new log segment file with the given segment ID
-func CreateLogSegmentFile(directory string, segmentID int) (*os.File, error) {
-	filePath := filepath.Join(directory, fmt.Sprintf("%s%d", segmentPrefix, segmentID))
-	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create log segment file: %w", err)
-	}
-	return file, nil
-}
-
 // Sort segment files by their segment ID in ascending order
 func SortSegmentFiles(files []string) ([]string, error) {
 	type segmentInfo struct {
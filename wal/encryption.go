@@ -0,0 +1,216 @@
+package wal
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// KeyRing holds the AES keys that encrypt WAL records by key ID. New
+// records are sealed with the active key; the others open records written
+// before the keys were rotated.
+type KeyRing struct {
+	keys   map[uint32]cipher.AEAD
+	active uint32
+}
+
+// ParseKeyRing parses keys written as id:base64-key, one per line or
+// separated by commas. Keys are 16, 24 or 32 bytes for AES-128, AES-192 or
+// AES-256, and the last key listed is the active one. Blank lines and lines
+// starting with # are ignored.
+func ParseKeyRing(text string) (*KeyRing, error) {
+	ring := &KeyRing{keys: make(map[uint32]cipher.AEAD)}
+	fields := strings.FieldsFunc(text, func(r rune) bool { return r == '\n' || r == ',' })
+	for _, field := range fields {
+		field = strings.TrimSpace(field)
+		if field == "" || strings.HasPrefix(field, "#") {
+			continue
+		}
+
+		idText, keyText, ok := strings.Cut(field, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid key %q, want id:base64-key", redact(field))
+		}
+		id, err := strconv.ParseUint(strings.TrimSpace(idText), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key ID %q", idText)
+		}
+		if _, exists := ring.keys[uint32(id)]; exists {
+			return nil, fmt.Errorf("duplicate key ID %d", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(keyText))
+		if err != nil {
+			return nil, fmt.Errorf("key %d is not valid base64", id)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %w", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %w", id, err)
+		}
+		ring.keys[uint32(id)] = aead
+		ring.active = uint32(id)
+	}
+	if len(ring.keys) == 0 {
+		return nil, fmt.Errorf("no keys given")
+	}
+	return ring, nil
+}
+
+// LoadKeyRing reads a key ring from a file in the format of ParseKeyRing
+func LoadKeyRing(path string) (*KeyRing, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	ring, err := ParseKeyRing(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return ring, nil
+}
+
+// ActiveKeyID returns the ID of the key that seals new records
+func (ring *KeyRing) ActiveKeyID() uint32 {
+	return ring.active
+}
+
+// redact hides the key material of a malformed key ring entry
+func redact(field string) string {
+	if len(field) <= 4 {
+		return field
+	}
+	return field[:4] + "..."
+}
+
+// seal appends the active key ID to header, which holds the envelope flags,
+// followed by a nonce and data encrypted with the active key. The flags and
+// key ID are authenticated with data.
+func (ring *KeyRing) seal(header, data []byte) ([]byte, error) {
+	aead := ring.keys[ring.active]
+	header = binary.BigEndian.AppendUint32(header, ring.active)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	value := make([]byte, 0, len(header)+len(nonce)+len(data)+aead.Overhead())
+	value = append(append(value, header...), nonce...)
+	return aead.Seal(value, nonce, data, header), nil
+}
+
+// open decrypts the value of an encrypted envelope with the key it names
+func (ring *KeyRing) open(sequenceNumber uint64, value []byte) ([]byte, error) {
+	if len(value) < 5 {
+		return nil, fmt.Errorf("record %d has a truncated envelope", sequenceNumber)
+	}
+	keyID := binary.BigEndian.Uint32(value[1:5])
+	var aead cipher.AEAD
+	if ring != nil {
+		aead = ring.keys[keyID]
+	}
+	if aead == nil {
+		return nil, fmt.Errorf("record %d is encrypted with key %d, which is not in the key ring", sequenceNumber, keyID)
+	}
+	if len(value) < 5+aead.NonceSize() {
+		return nil, fmt.Errorf("record %d has a truncated envelope", sequenceNumber)
+	}
+
+	header, nonce, ciphertext := value[:5], value[5:5+aead.NonceSize()], value[5+aead.NonceSize():]
+	data, err := aead.Open(nil, nonce, ciphertext, header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt record %d with key %d: %w", sequenceNumber, keyID, err)
+	}
+	return data, nil
+}
+
+// reencryptTemp is the file a record file is rewritten to before replacing it
+const reencryptTemp = "reencrypt.tmp"
+
+// Reencrypt rewrites the segments and snapshot in directory, opening their
+// records with from and sealing them with the active key of to, or writing
+// plaintext if to is nil. Plaintext records need no key, so from may be nil
+// when encrypting a WAL for the first time; to rotate keys, pass one ring
+// holding the old keys and the new active key as both. Each file is
+// replaced atomically, and the WAL must not be open while this runs. Options
+// such as WithCompression apply to the rewritten records. It returns the
+// number of files rewritten.
+func Reencrypt(directory string, from, to *KeyRing, opts ...Option) (int, error) {
+	target := &WAL{keys: to}
+	for _, opt := range opts {
+		opt(target)
+	}
+	storage := target.storage
+	if storage == nil {
+		storage = &OSStorage{directory: directory}
+	}
+
+	files, err := storage.List(segmentPrefix)
+	if err != nil {
+		return 0, err
+	}
+	files, err = sortSegmentFiles(files)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := storage.Size(snapshotFile); err == nil {
+		files = append(files, snapshotFile)
+	}
+
+	if len(files) == 0 {
+		return 0, nil
+	}
+
+	for i, name := range files {
+		if err := rewriteRecords(storage, name, from, target.codec()); err != nil {
+			return i, err
+		}
+	}
+	return len(files), storage.Sync()
+}
+
+// rewriteRecords replaces the records of a segment or snapshot file with
+// the same entries wrapped by codec
+func rewriteRecords(storage Storage, name string, from *KeyRing, codec recordCodec) error {
+	entries, err := readRecords(storage, name, from)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", name, err)
+	}
+
+	file, err := storage.Create(reencryptTemp)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", reencryptTemp, err)
+	}
+	writer := bufio.NewWriter(file)
+	for _, entry := range entries {
+		if err := writeEntry(writer, entry, codec); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to rewrite %s: %w", name, err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to rewrite %s: %w", name, err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to sync %s: %w", reencryptTemp, err)
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	if err := storage.Rename(reencryptTemp, name); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package wal
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Flags of an envelope record, stored in the first byte of its Value
+const (
+	// envelopeEncrypted marks a record sealed with AES-GCM. The flags are
+	// followed by the big-endian uint32 key ID and the nonce.
+	envelopeEncrypted byte = 1 << 0
+	// envelopeCompressed marks a record compressed with DEFLATE before it
+	// was sealed, if it was
+	envelopeCompressed byte = 1 << 1
+)
+
+// envelopeKnownFlags holds every flag this version can unwrap
+const envelopeKnownFlags = envelopeEncrypted | envelopeCompressed
+
+// recordCodec describes how records are wrapped before they are written
+type recordCodec struct {
+	// keys seals records when not nil
+	keys *KeyRing
+	// compressAbove compresses records whose Value is at least this long
+	// when positive
+	compressAbove int
+}
+
+// codec returns how the WAL wraps the records it writes
+func (wal *WAL) codec() recordCodec {
+	return recordCodec{keys: wal.keys, compressAbove: wal.compressAbove}
+}
+
+// marshal serializes entry, wrapping it in an envelope when it is
+// compressed or sealed. Records that are neither keep the plain layout.
+func (codec recordCodec) marshal(entry *WAL_Entry) ([]byte, error) {
+	data, err := Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	var flags byte
+	if codec.compressAbove > 0 && len(entry.Value) >= codec.compressAbove {
+		if compressed, ok := compress(data); ok {
+			data, flags = compressed, envelopeCompressed
+		}
+	}
+	if codec.keys != nil {
+		flags |= envelopeEncrypted
+	}
+	if flags == 0 {
+		return data, nil
+	}
+
+	value := []byte{flags}
+	if codec.keys != nil {
+		if value, err = codec.keys.seal(value, data); err != nil {
+			return nil, err
+		}
+	} else {
+		value = append(value, data...)
+	}
+	return Marshal(&WAL_Entry{
+		Type:           EntryTypeENVELOPE,
+		SequenceNumber: entry.SequenceNumber,
+		Value:          value,
+	})
+}
+
+// unwrapEnvelope returns the entry held by an envelope record, decrypting it
+// with a key from ring and decompressing it as its flags say
+func unwrapEnvelope(envelope *WAL_Entry, ring *KeyRing) (*WAL_Entry, error) {
+	value := envelope.Value
+	if len(value) == 0 || value[0] == 0 || value[0]&^envelopeKnownFlags != 0 {
+		return nil, fmt.Errorf("record %d has an unsupported envelope", envelope.SequenceNumber)
+	}
+
+	flags, data := value[0], value[1:]
+	var err error
+	if flags&envelopeEncrypted != 0 {
+		if data, err = ring.open(envelope.SequenceNumber, value); err != nil {
+			return nil, err
+		}
+	}
+	if flags&envelopeCompressed != 0 {
+		if data, err = decompress(data); err != nil {
+			return nil, fmt.Errorf("failed to decompress record %d: %w", envelope.SequenceNumber, err)
+		}
+	}
+
+	entry, err := unMarshalAndVerifyEntry(data)
+	if err != nil {
+		return nil, fmt.Errorf("record %d: %w", envelope.SequenceNumber, err)
+	}
+	if entry.SequenceNumber != envelope.SequenceNumber {
+		return nil, fmt.Errorf("record %d holds record %d", envelope.SequenceNumber, entry.SequenceNumber)
+	}
+	return entry, nil
+}
+
+// Compressors keep large internal state, so they are reused across records
+var (
+	flateWriters = sync.Pool{New: func() any {
+		w, _ := flate.NewWriter(nil, flate.BestSpeed)
+		return w
+	}}
+	flateReaders = sync.Pool{New: func() any {
+		return flate.NewReader(nil)
+	}}
+)
+
+// compress deflates data, reporting false if that does not make it smaller
+func compress(data []byte) ([]byte, bool) {
+	var buf bytes.Buffer
+	w := flateWriters.Get().(*flate.Writer)
+	defer flateWriters.Put(w)
+	w.Reset(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, false
+	}
+	if err := w.Close(); err != nil || buf.Len() >= len(data) {
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
+
+// decompress inflates data written by compress, which is never larger
+// than a record
+func decompress(data []byte) ([]byte, error) {
+	r := flateReaders.Get().(io.ReadCloser)
+	defer flateReaders.Put(r)
+	if err := r.(flate.Resetter).Reset(bytes.NewReader(data), nil); err != nil {
+		return nil, err
+	}
+	inflated, err := io.ReadAll(io.LimitReader(r, maxRecordSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(inflated) > maxRecordSize {
+		return nil, fmt.Errorf("inflates to more than %d bytes", maxRecordSize)
+	}
+	return inflated, nil
+}
@@ -0,0 +1,44 @@
+package wal
+
+import (
+	"io"
+
+	"github.com/nishanth-gowda/kv-store/metrics"
+)
+
+// walMetrics holds the instruments updated by WAL
+type walMetrics struct {
+	registry      *metrics.Registry
+	appends       *metrics.Counter
+	bytesWritten  *metrics.Counter
+	rotations     *metrics.Counter
+	fsyncDuration *metrics.Histogram
+}
+
+func newWALMetrics(wal *WAL) *walMetrics {
+	registry := metrics.NewRegistry()
+	m := &walMetrics{
+		registry:     registry,
+		appends:      registry.NewCounter("kv_wal_appends_total", "Number of entries appended to the WAL."),
+		bytesWritten: registry.NewCounter("kv_wal_bytes_written_total", "Bytes appended to WAL segments, including record headers."),
+		rotations:    registry.NewCounter("kv_wal_rotations_total", "Number of times a new WAL segment was started."),
+		// 100µs up to ~1.6s
+		fsyncDuration: registry.NewHistogram("kv_wal_fsync_duration_seconds", "Latency of fsync calls on WAL segments.",
+			metrics.ExponentialBuckets(0.0001, 2, 15)),
+	}
+
+	registry.NewGaugeFunc("kv_wal_segments", "Number of WAL segment files on disk.", func() float64 {
+		files, err := wal.storage.List(segmentPrefix)
+		if err != nil {
+			return 0
+		}
+		return float64(len(files))
+	})
+
+	return m
+}
+
+// WriteMetrics writes the WAL metrics in the Prometheus text format
+func (wal *WAL) WriteMetrics(w io.Writer) error {
+	return wal.metrics.registry.WriteText(w)
+}
@@ -0,0 +1,40 @@
+package wal
+
+// Stats describes the WAL state on disk
+type Stats struct {
+	// Directory is the one given to NewWal, which is unused with WithStorage
+	Directory          string
+	CurrentSegment     string
+	LastSequenceNumber uint64
+	Segments           int
+	// DiskBytes is the combined size of the segments and the snapshot
+	DiskBytes int64
+}
+
+// Stats returns the current segment, last sequence number and on-disk usage
+func (wal *WAL) Stats() (Stats, error) {
+	wal.lock.Lock()
+	stats := Stats{
+		Directory:          wal.directory,
+		CurrentSegment:     wal.currentName,
+		LastSequenceNumber: wal.lastSequenceNumber,
+	}
+	wal.lock.Unlock()
+
+	files, err := wal.storage.List(segmentPrefix)
+	if err != nil {
+		return Stats{}, err
+	}
+	stats.Segments = len(files)
+
+	for _, file := range append(files, snapshotFile) {
+		size, err := wal.storage.Size(file)
+		if err != nil {
+			// Segments can be removed by a concurrent rotation; the snapshot may not exist
+			continue
+		}
+		stats.DiskBytes += size
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,269 @@
+package wal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Storage holds the segment and snapshot files of a WAL by name. The WAL
+// reads and writes its files only through it, so it can run against the
+// local filesystem, memory, or another backend.
+type Storage interface {
+	// List returns the names of the files starting with prefix
+	List(prefix string) ([]string, error)
+	// Create creates the named file for writing, truncating it if it exists
+	Create(name string) (File, error)
+	// OpenAppend opens the named file for writing at its end, creating it if needed
+	OpenAppend(name string) (File, error)
+	// OpenRead opens the named file for reading. The error of a missing
+	// file matches fs.ErrNotExist.
+	OpenRead(name string) (io.ReadCloser, error)
+	// Size returns the size of the named file
+	Size(name string) (int64, error)
+	// Rename atomically replaces newName with oldName
+	Rename(oldName, newName string) error
+	// Remove deletes the named file
+	Remove(name string) error
+	// Sync makes files created, renamed and removed so far durable
+	Sync() error
+}
+
+// File is a file of a Storage opened for writing
+type File interface {
+	io.Writer
+	// Sync makes the data written to the file so far durable
+	Sync() error
+	Close() error
+}
+
+// WithStorage keeps the segments and snapshot in storage instead of the
+// directory passed to NewWal
+func WithStorage(storage Storage) Option {
+	return func(wal *WAL) {
+		wal.storage = storage
+	}
+}
+
+// OSStorage stores files in a directory of the local filesystem
+type OSStorage struct {
+	directory string
+}
+
+// NewOSStorage returns a storage for the files in directory, creating it if needed
+func NewOSStorage(directory string) (*OSStorage, error) {
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return nil, err
+	}
+	return &OSStorage{directory: directory}, nil
+}
+
+func (s *OSStorage) path(name string) string {
+	return filepath.Join(s.directory, name)
+}
+
+func (s *OSStorage) List(prefix string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(s.directory, prefix+"*"))
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(paths))
+	for i, path := range paths {
+		names[i] = filepath.Base(path)
+	}
+	return names, nil
+}
+
+func (s *OSStorage) Create(name string) (File, error) {
+	return os.OpenFile(s.path(name), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+}
+
+func (s *OSStorage) OpenAppend(name string) (File, error) {
+	return os.OpenFile(s.path(name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+}
+
+func (s *OSStorage) OpenRead(name string) (io.ReadCloser, error) {
+	return os.Open(s.path(name))
+}
+
+func (s *OSStorage) Size(name string) (int64, error) {
+	info, err := os.Stat(s.path(name))
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func (s *OSStorage) Rename(oldName, newName string) error {
+	return os.Rename(s.path(oldName), s.path(newName))
+}
+
+func (s *OSStorage) Remove(name string) error {
+	return os.Remove(s.path(name))
+}
+
+// Sync fsyncs the directory so renames and new files in it are durable
+func (s *OSStorage) Sync() error {
+	dir, err := os.Open(s.directory)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+// MemoryStorage keeps files in memory, e.g. to run a cache in tests without
+// touching the disk. Files survive closing the WAL, so a new WAL on the same
+// storage recovers what the previous one wrote.
+type MemoryStorage struct {
+	mu    sync.Mutex
+	files map[string]*bytes.Buffer
+}
+
+// NewMemoryStorage returns an empty in-memory storage
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{files: make(map[string]*bytes.Buffer)}
+}
+
+func (s *MemoryStorage) List(prefix string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var names []string
+	for name := range s.files {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *MemoryStorage) Create(name string) (File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.files[name] = new(bytes.Buffer)
+	return &memoryFile{storage: s, name: name}, nil
+}
+
+func (s *MemoryStorage) OpenAppend(name string) (File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.files[name]; !ok {
+		s.files[name] = new(bytes.Buffer)
+	}
+	return &memoryFile{storage: s, name: name}, nil
+}
+
+func (s *MemoryStorage) OpenRead(name string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, ok := s.files[name]
+	if !ok {
+		return nil, notExist("open", name)
+	}
+	// Readers see the file as it was when opened
+	return io.NopCloser(bytes.NewReader(bytes.Clone(file.Bytes()))), nil
+}
+
+func (s *MemoryStorage) Size(name string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, ok := s.files[name]
+	if !ok {
+		return 0, notExist("stat", name)
+	}
+	return int64(file.Len()), nil
+}
+
+func (s *MemoryStorage) Rename(oldName, newName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, ok := s.files[oldName]
+	if !ok {
+		return notExist("rename", oldName)
+	}
+	delete(s.files, oldName)
+	s.files[newName] = file
+	return nil
+}
+
+func (s *MemoryStorage) Remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.files[name]; !ok {
+		return notExist("remove", name)
+	}
+	delete(s.files, name)
+	return nil
+}
+
+// Sync does nothing; memory holds no unsynced state
+func (s *MemoryStorage) Sync() error {
+	return nil
+}
+
+// memoryFile writes to a file of a MemoryStorage by name, so writes after
+// the file was removed or replaced fail instead of being lost silently
+type memoryFile struct {
+	storage *MemoryStorage
+	name    string
+	closed  bool
+}
+
+func (f *memoryFile) Write(p []byte) (int, error) {
+	f.storage.mu.Lock()
+	defer f.storage.mu.Unlock()
+
+	if f.closed {
+		return 0, fmt.Errorf("write %s: %w", f.name, fs.ErrClosed)
+	}
+	file, ok := f.storage.files[f.name]
+	if !ok {
+		return 0, notExist("write", f.name)
+	}
+	return file.Write(p)
+}
+
+func (f *memoryFile) Sync() error {
+	f.storage.mu.Lock()
+	defer f.storage.mu.Unlock()
+
+	if f.closed {
+		return fmt.Errorf("sync %s: %w", f.name, fs.ErrClosed)
+	}
+	return nil
+}
+
+func (f *memoryFile) Close() error {
+	f.storage.mu.Lock()
+	defer f.storage.mu.Unlock()
+
+	if f.closed {
+		return fmt.Errorf("close %s: %w", f.name, fs.ErrClosed)
+	}
+	f.closed = true
+	return nil
+}
+
+func notExist(op, name string) error {
+	return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+}
+
+// isNotExist reports whether err says a file does not exist
+func isNotExist(err error) bool {
+	return errors.Is(err, fs.ErrNotExist)
+}
@@ -2,18 +2,20 @@ package wal
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/binary"
 	"encoding/gob"
+	"errors"
 	"fmt"
 	"hash/crc32"
 	"io"
-	"os"
 	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/nishanth-gowda/kv-store/utils"
@@ -22,6 +24,7 @@ import (
 const (
 	syncInterval  = 100 * time.Millisecond
 	segmentPrefix = "wal-segment-"
+	snapshotFile  = "snapshot"
 )
 
 // EntryType represents the type of WAL entry
@@ -30,6 +33,34 @@ type EntryType uint8
 const (
 	EntryTypeSET    EntryType = 1
 	EntryTypeDELETE EntryType = 2
+	// Field-level hash updates; Value holds the gob-encoded fields
+	EntryTypeHSET EntryType = 3
+	EntryTypeHDEL EntryType = 4
+	// List updates; pushes carry the gob-encoded values, pops remove one element
+	EntryTypeLPUSH EntryType = 5
+	EntryTypeRPUSH EntryType = 6
+	EntryTypeLPOP  EntryType = 7
+	EntryTypeRPOP  EntryType = 8
+	// Set and sorted set updates; Value holds the gob-encoded members
+	EntryTypeSADD EntryType = 9
+	EntryTypeSREM EntryType = 10
+	EntryTypeZADD EntryType = 11
+	// Probabilistic structure updates; Value holds the gob-encoded elements
+	EntryTypePFADD EntryType = 12
+	EntryTypeBFADD EntryType = 13
+	// Stream updates; Value holds the gob-encoded entry, group, delivery or
+	// acknowledgement so consumer group state is durable
+	EntryTypeXADD   EntryType = 14
+	EntryTypeXGROUP EntryType = 15
+	EntryTypeXCLAIM EntryType = 16
+	EntryTypeXACK   EntryType = 17
+	// Expiration update; ExpiresAtUnixNano holds the new expiration of the
+	// key (0 for none) and Value is empty
+	EntryTypeEXPIRE EntryType = 18
+	// Envelope holding another marshaled record, transformed as described
+	// by the flags that start Value, e.g. encrypted with a key ring. Only
+	// the sequence number of the record it holds is readable.
+	EntryTypeENVELOPE EntryType = 19
 )
 
 // WAL_Entry represents a single entry in the WAL
@@ -49,7 +80,9 @@ func (e *WAL_Entry) GetLogSequenceNumber() uint64 {
 
 type WAL struct {
 	directory          string
-	currentSegment     *os.File
+	storage            Storage
+	currentSegment     File
+	currentName        string
 	lock               sync.Mutex
 	lastSequenceNumber uint64
 	bufferedWriter     *bufio.Writer
@@ -59,73 +92,129 @@ type WAL struct {
 	maxSegments        int
 	ctx                context.Context
 	cancel             context.CancelFunc
+	metrics            *walMetrics
+	// segments counts the segment files, including the current one. It is
+	// atomic so NeedsSnapshot does not wait for a sync holding lock.
+	segments atomic.Int64
+	// snapshotNeeded is signalled when a rotation leaves more than
+	// maxSegments segments, and closed by Close
+	snapshotNeeded chan struct{}
+	// keys encrypts new records and decrypts existing ones; nil writes plaintext
+	keys *KeyRing
+	// compressAbove is the Value size from which records are compressed; 0 disables compression
+	compressAbove int
 }
 
-func NewWal(directory string, forceSync bool, maxFileSize int, maxSegments int) (*WAL, error) {
-	if err := os.MkdirAll(directory, 0755); err != nil {
-		return nil, err
+// Option configures a WAL opened by NewWal
+type Option func(*WAL)
+
+// WithKeyRing encrypts new records with the active key of keys and decrypts
+// existing records with the key they name. Plaintext records written before
+// encryption was enabled are still read.
+func WithKeyRing(keys *KeyRing) Option {
+	return func(wal *WAL) {
+		wal.keys = keys
 	}
+}
 
-	files, err := filepath.Glob(filepath.Join(directory, segmentPrefix+"*"))
-	if err != nil {
-		return nil, err
+// WithCompression compresses records whose Value is at least threshold
+// bytes with DEFLATE when that makes them smaller. A threshold of zero
+// disables compression; compressed records are read either way.
+func WithCompression(threshold int) Option {
+	return func(wal *WAL) {
+		wal.compressAbove = threshold
 	}
+}
 
-	var lastSegmentId int
-	if len(files) > 0 {
-		// find the last segmentId
-		lastSegmentId, err = utils.GetLastSegmentID(files)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		// create the new log segment
-		file, err := utils.CreateLogSegmentFile(directory, 0)
+// NewWal opens the WAL in directory, or in the storage given by WithStorage
+func NewWal(directory string, forceSync bool, maxFileSize int, maxSegments int, opts ...Option) (*WAL, error) {
+	wal := &WAL{
+		directory:          directory,
+		lastSequenceNumber: 0,
+		syncTimer:          time.NewTimer(syncInterval),
+		forceFSync:         forceSync,
+		maxFileSize:        maxFileSize,
+		maxSegments:        maxSegments,
+		snapshotNeeded:     make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(wal)
+	}
+	if wal.storage == nil {
+		storage, err := NewOSStorage(directory)
 		if err != nil {
 			return nil, err
 		}
-
-		if err := file.Close(); err != nil {
-			return nil, err
-		}
+		wal.storage = storage
 	}
 
-	filePath := filepath.Join(directory, fmt.Sprintf("%s%d", segmentPrefix, lastSegmentId))
-	file, err := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	files, err := wal.storage.List(segmentPrefix)
 	if err != nil {
 		return nil, err
 	}
 
-	// seek to the end of the file
-	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+	// find the last segmentId; without segments, the first one is created
+	lastSegmentId, err := utils.GetLastSegmentID(files)
+	if err != nil {
 		return nil, err
 	}
+	segments, err := sortSegmentFiles(files)
+	if err != nil {
+		return nil, err
+	}
+	wal.segments.Store(int64(len(segments)))
+	create := len(files) == 0
+	if !create {
+		// Records appended after a torn one could not be read, so a segment
+		// torn by a crash is left as it is and writing continues in a new one
+		_, torn, err := scanSegment(wal.storage, segmentName(lastSegmentId))
+		if err != nil {
+			return nil, err
+		}
+		if torn {
+			lastSegmentId++
+			create = true
+		}
+	}
+	if create {
+		wal.segments.Add(1)
+	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-
-	wal := &WAL{
-		directory:          directory,
-		currentSegment:     file,
-		lastSequenceNumber: 0,
-		bufferedWriter:     bufio.NewWriter(file),
-		syncTimer:          time.NewTimer(syncInterval),
-		forceFSync:         forceSync,
-		maxFileSize:        maxFileSize,
-		maxSegments:        maxSegments,
-		ctx:                ctx,
-		cancel:             cancel,
+	wal.currentName = segmentName(lastSegmentId)
+	file, err := wal.storage.OpenAppend(wal.currentName)
+	if err != nil {
+		return nil, err
 	}
+	if create {
+		// A synced record in a segment whose creation was lost is lost too
+		if err := wal.storage.Sync(); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	wal.currentSegment = file
+	wal.bufferedWriter = bufio.NewWriter(file)
+	wal.ctx, wal.cancel = context.WithCancel(context.Background())
+	wal.metrics = newWALMetrics(wal)
 
-	if wal.lastSequenceNumber, err = getLastSequenceNumberFromFile(filePath); err != nil {
+	if wal.lastSequenceNumber, err = wal.findLastSequenceNumber(); err != nil {
+		file.Close()
 		return nil, err
 	}
 
+	// A WAL reopened with a lower maxSegments may already hold too many
+	wal.signalSnapshot()
 	go wal.syncLoop()
 
 	return wal, nil
 
 }
 
+// segmentName returns the file name of the segment with the given ID
+func segmentName(id int) string {
+	return fmt.Sprintf("%s%d", segmentPrefix, id)
+}
+
 // Marshal serializes a WAL_Entry to bytes
 func Marshal(entry *WAL_Entry) ([]byte, error) {
 	// Calculate CRC before marshaling
@@ -139,16 +228,44 @@ func Marshal(entry *WAL_Entry) ([]byte, error) {
 	return buf, nil
 }
 
-// MustUnmarshal deserializes bytes to a WAL_Entry (panics on error)
-func MustUnmarshal(data []byte, entry *WAL_Entry) {
+// Unmarshal deserializes bytes to a WAL_Entry
+func Unmarshal(data []byte, entry *WAL_Entry) error {
 	decoder := gob.NewDecoder(&buffer{data: &data})
 	if err := decoder.Decode(entry); err != nil {
-		panic(fmt.Sprintf("failed to unmarshal WAL entry: %v", err))
+		return fmt.Errorf("failed to unmarshal WAL entry: %w", err)
+	}
+	return nil
+}
+
+// MustUnmarshal deserializes bytes to a WAL_Entry (panics on error). Data
+// read back from storage may be corrupt, so the WAL itself uses Unmarshal.
+func MustUnmarshal(data []byte, entry *WAL_Entry) {
+	if err := Unmarshal(data, entry); err != nil {
+		panic(err.Error())
 	}
 }
 
-// calculateCRC calculates CRC32 checksum for the entry (excluding CRC field)
+// calculateCRC calculates the CRC32 checksum of the entry's fields other
+// than CRC over a fixed layout, so it is the same in every process
 func calculateCRC(entry *WAL_Entry) uint32 {
+	var fixed [binary.MaxVarintLen64 + 8]byte
+	hash := crc32.NewIEEE()
+
+	hash.Write([]byte{byte(entry.Type)})
+	hash.Write(binary.LittleEndian.AppendUint64(fixed[:0], entry.SequenceNumber))
+	hash.Write(binary.AppendUvarint(fixed[:0], uint64(len(entry.Key))))
+	hash.Write([]byte(entry.Key))
+	hash.Write(binary.AppendUvarint(fixed[:0], uint64(len(entry.Value))))
+	hash.Write(entry.Value)
+	hash.Write(binary.LittleEndian.AppendUint64(fixed[:0], uint64(entry.ExpiresAtUnixNano)))
+	return hash.Sum32()
+}
+
+// legacyCRC calculates the checksum written by earlier versions, over the
+// gob encoding of the entry. It depends on the ID gob assigned to WAL_Entry
+// in the process, which varies with the types it encoded first, so it only
+// verifies in a process that assigned the same ID.
+func legacyCRC(entry *WAL_Entry) uint32 {
 	// Create a copy without CRC for checksum calculation
 	tempEntry := *entry
 	tempEntry.CRC = 0
@@ -163,8 +280,7 @@ func calculateCRC(entry *WAL_Entry) uint32 {
 
 // verifyCRC verifies the CRC checksum of an entry
 func verifyCRC(entry *WAL_Entry) bool {
-	expectedCRC := calculateCRC(entry)
-	return entry.CRC == expectedCRC
+	return entry.CRC == calculateCRC(entry) || entry.CRC == legacyCRC(entry)
 }
 
 // buffer is a simple buffer implementation for gob encoder/decoder
@@ -189,7 +305,9 @@ func (b *buffer) Read(p []byte) (n int, err error) {
 
 func unMarshalAndVerifyEntry(data []byte) (*WAL_Entry, error) {
 	var entry WAL_Entry
-	MustUnmarshal(data, &entry)
+	if err := Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
 
 	if !verifyCRC(&entry) {
 		return nil, fmt.Errorf("invalid CRC")
@@ -214,7 +332,7 @@ func (wal *WAL) Append(entryType EntryType, key string, value []byte, expiresAtU
 	}
 
 	// Marshal entry
-	data, err := Marshal(entry)
+	data, err := wal.codec().marshal(entry)
 	if err != nil {
 		return fmt.Errorf("failed to marshal entry: %w", err)
 	}
@@ -224,16 +342,11 @@ func (wal *WAL) Append(entryType EntryType, key string, value []byte, expiresAtU
 		return fmt.Errorf("failed to rotate segment: %w", err)
 	}
 
-	// Write size prefix (int32)
-	size := int32(len(data))
-	if err := binary.Write(wal.bufferedWriter, binary.LittleEndian, size); err != nil {
-		return fmt.Errorf("failed to write size: %w", err)
-	}
-
-	// Write entry data
-	if _, err := wal.bufferedWriter.Write(data); err != nil {
-		return fmt.Errorf("failed to write entry: %w", err)
+	if err := writeRecord(wal.bufferedWriter, data); err != nil {
+		return err
 	}
+	wal.metrics.appends.Inc()
+	wal.metrics.bytesWritten.Add(uint64(recordHeaderSize + len(data)))
 
 	// Flush buffer
 	if err := wal.bufferedWriter.Flush(); err != nil {
@@ -242,7 +355,7 @@ func (wal *WAL) Append(entryType EntryType, key string, value []byte, expiresAtU
 
 	// Force fsync if configured
 	if wal.forceFSync {
-		if err := wal.currentSegment.Sync(); err != nil {
+		if err := wal.syncSegment(); err != nil {
 			return fmt.Errorf("failed to sync: %w", err)
 		}
 	}
@@ -250,83 +363,140 @@ func (wal *WAL) Append(entryType EntryType, key string, value []byte, expiresAtU
 	return nil
 }
 
+// recordHeaderSize is the size of the int32 length prefix of every record
+const recordHeaderSize = 4
+
+// maxRecordSize bounds the size of a record, so a corrupt size prefix is
+// recognized as such
+const maxRecordSize = 1 << 30
+
+// errInvalidRecordSize is returned for a size prefix no record can have
+var errInvalidRecordSize = errors.New("invalid record size")
+
+// writeRecord writes a size-prefixed marshaled entry
+func writeRecord(w io.Writer, data []byte) error {
+	if len(data) > maxRecordSize {
+		return fmt.Errorf("record of %d bytes exceeds the maximum of %d", len(data), maxRecordSize)
+	}
+
+	// Write size prefix (int32)
+	size := int32(len(data))
+	if err := binary.Write(w, binary.LittleEndian, size); err != nil {
+		return fmt.Errorf("failed to write size: %w", err)
+	}
+
+	// Write entry data
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to write entry: %w", err)
+	}
+	return nil
+}
+
+// writeEntry marshals entry and writes it as a size-prefixed record
+// wrapped by codec
+func writeEntry(w io.Writer, entry *WAL_Entry, codec recordCodec) error {
+	data, err := codec.marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal entry: %w", err)
+	}
+	return writeRecord(w, data)
+}
+
 // checkAndRotateSegment checks if segment rotation is needed and performs it
 func (wal *WAL) checkAndRotateSegment() error {
 	// Get current file size
-	stat, err := wal.currentSegment.Stat()
+	size, err := wal.storage.Size(wal.currentName)
 	if err != nil {
 		return err
 	}
 
-	if stat.Size() < int64(wal.maxFileSize) {
+	if size < int64(wal.maxFileSize) {
 		return nil
 	}
 
+	if _, err := wal.rotateSegment(); err != nil {
+		return err
+	}
+	wal.signalSnapshot()
+	return nil
+}
+
+// rotateSegment closes the current segment and opens the next one.
+// It returns the segment files that existed before the new one was created.
+func (wal *WAL) rotateSegment() ([]string, error) {
 	// Close current segment
 	if err := wal.bufferedWriter.Flush(); err != nil {
-		return err
+		return nil, err
 	}
-	if err := wal.currentSegment.Sync(); err != nil {
-		return err
+	if err := wal.syncSegment(); err != nil {
+		return nil, err
 	}
 	if err := wal.currentSegment.Close(); err != nil {
-		return err
+		return nil, err
 	}
 
 	// Find next segment ID
-	files, err := filepath.Glob(filepath.Join(wal.directory, segmentPrefix+"*"))
+	files, err := wal.storage.List(segmentPrefix)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	nextSegmentID := 0
 	if len(files) > 0 {
 		lastSegmentID, err := utils.GetLastSegmentID(files)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		nextSegmentID = lastSegmentID + 1
 	}
 
-	// Clean up old segments if needed
-	if err := wal.cleanupOldSegments(files); err != nil {
-		return err
-	}
-
 	// Create new segment
-	filePath := filepath.Join(wal.directory, fmt.Sprintf("%s%d", segmentPrefix, nextSegmentID))
-	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	name := segmentName(nextSegmentID)
+	file, err := wal.storage.OpenAppend(name)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	wal.currentSegment = file
+	wal.currentName = name
 	wal.bufferedWriter = bufio.NewWriter(file)
+	wal.segments.Add(1)
+	wal.metrics.rotations.Inc()
 
-	return nil
+	// Make the new segment durable before records synced to it are acknowledged
+	if err := wal.storage.Sync(); err != nil {
+		return nil, err
+	}
+
+	return files, nil
 }
 
-// cleanupOldSegments removes old segments if we exceed maxSegments
-func (wal *WAL) cleanupOldSegments(files []string) error {
-	if len(files) < wal.maxSegments {
-		return nil
-	}
+// NeedsSnapshot reports whether the WAL holds more than maxSegments
+// segments. Segments are only dropped once a snapshot covers them, since
+// they hold writes made after the last one, so the owner of the WAL should
+// call WriteSnapshot.
+func (wal *WAL) NeedsSnapshot() bool {
+	return wal.maxSegments > 0 && wal.segments.Load() > int64(wal.maxSegments)
+}
 
-	// Sort files by segment ID and remove oldest ones
-	sortedFiles, err := sortSegmentFiles(files)
-	if err != nil {
-		return err
-	}
+// SnapshotNeeded returns a channel that receives a value whenever rotating
+// to a new segment leaves the WAL needing a snapshot, so that its owner
+// can write one in the background. It is closed by Close.
+func (wal *WAL) SnapshotNeeded() <-chan struct{} {
+	return wal.snapshotNeeded
+}
 
-	// Remove oldest segments
-	toRemove := len(sortedFiles) - wal.maxSegments + 1 // +1 because we're about to create a new one
-	for i := 0; i < toRemove && i < len(sortedFiles); i++ {
-		if err := os.Remove(sortedFiles[i]); err != nil {
-			return fmt.Errorf("failed to remove old segment %s: %w", sortedFiles[i], err)
-		}
+// signalSnapshot notifies SnapshotNeeded if the WAL needs a snapshot,
+// without waiting for an earlier notification to be received. The caller
+// must hold wal.lock, or own the WAL before NewWal returns.
+func (wal *WAL) signalSnapshot() {
+	if !wal.NeedsSnapshot() {
+		return
+	}
+	select {
+	case wal.snapshotNeeded <- struct{}{}:
+	default:
 	}
-
-	return nil
 }
 
 // Sync the WAL to disk with predefined interval by using a timer
@@ -345,7 +515,7 @@ func (wal *WAL) syncLoop() {
 			}
 			if wal.currentSegment != nil {
 				// Sync the current segment file to disk
-				if err := wal.currentSegment.Sync(); err != nil {
+				if err := wal.syncSegment(); err != nil {
 					fmt.Printf("Error syncing current segment: %v\n", err)
 				}
 			}
@@ -357,12 +527,91 @@ func (wal *WAL) syncLoop() {
 	}
 }
 
+// syncSegment fsyncs the current segment and records how long it took
+func (wal *WAL) syncSegment() error {
+	start := time.Now()
+	err := wal.currentSegment.Sync()
+	wal.metrics.fsyncDuration.Observe(time.Since(start).Seconds())
+	return err
+}
+
+// Sync flushes buffered writes and fsyncs the current segment
+func (wal *WAL) Sync() error {
+	wal.lock.Lock()
+	defer wal.lock.Unlock()
+
+	if err := wal.bufferedWriter.Flush(); err != nil {
+		return err
+	}
+	return wal.syncSegment()
+}
+
+// WriteSnapshot atomically replaces the snapshot with entries, which must
+// describe the complete state as of the last appended entry. Once the
+// snapshot is durable the WAL moves to a new segment and drops the older
+// ones, since everything they hold is covered by the snapshot.
+func (wal *WAL) WriteSnapshot(entries []*WAL_Entry) error {
+	wal.lock.Lock()
+	defer wal.lock.Unlock()
+
+	tmpName := snapshotFile + ".tmp"
+	file, err := wal.storage.Create(tmpName)
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+
+	writer := bufio.NewWriter(file)
+	for _, entry := range entries {
+		// Every snapshot entry carries the sequence number the snapshot covers
+		entry.SequenceNumber = wal.lastSequenceNumber
+		if err := writeEntry(writer, entry, wal.codec()); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to write snapshot: %w", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to sync snapshot: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	if err := wal.storage.Rename(tmpName, snapshotFile); err != nil {
+		return fmt.Errorf("failed to install snapshot: %w", err)
+	}
+	if err := wal.storage.Sync(); err != nil {
+		return err
+	}
+
+	// The snapshot now covers every existing segment
+	files, err := wal.rotateSegment()
+	if err != nil {
+		return fmt.Errorf("failed to rotate segment: %w", err)
+	}
+	for _, file := range files {
+		if err := wal.storage.Remove(file); err != nil {
+			return fmt.Errorf("failed to remove old segment %s: %w", file, err)
+		}
+		wal.segments.Add(-1)
+	}
+
+	return nil
+}
+
 // Closes the WAL and clean up resources
 func (wal *WAL) Close() error {
 	wal.lock.Lock()
 	defer wal.lock.Unlock()
 
 	// Cancel context to stop sync loop
+	if wal.ctx.Err() == nil {
+		close(wal.snapshotNeeded)
+	}
 	wal.cancel()
 
 	// Stop timer
@@ -378,7 +627,7 @@ func (wal *WAL) Close() error {
 	}
 
 	if wal.currentSegment != nil {
-		if err := wal.currentSegment.Sync(); err != nil {
+		if err := wal.syncSegment(); err != nil {
 			return err
 		}
 		if err := wal.currentSegment.Close(); err != nil {
@@ -389,11 +638,15 @@ func (wal *WAL) Close() error {
 	return nil
 }
 
-// Reads all entries from all WAL segments and returns them as a slice of WAL_Entry
+// Reads the snapshot followed by all entries from all WAL segments and returns them as a slice of WAL_Entry.
+// Segment entries already covered by the snapshot are skipped.
 func (wal *WAL) ReadAll() ([]*WAL_Entry, error) {
-	var allEntries []*WAL_Entry
+	allEntries, snapshotSequenceNumber, err := wal.readSnapshot()
+	if err != nil {
+		return nil, err
+	}
 
-	files, err := filepath.Glob(filepath.Join(wal.directory, segmentPrefix+"*"))
+	files, err := wal.storage.List(segmentPrefix)
 	if err != nil {
 		return nil, err
 	}
@@ -409,38 +662,88 @@ func (wal *WAL) ReadAll() ([]*WAL_Entry, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to read segment %s: %w", filePath, err)
 		}
-		allEntries = append(allEntries, entries...)
+		for _, entry := range entries {
+			if entry.SequenceNumber > snapshotSequenceNumber {
+				allEntries = append(allEntries, entry)
+			}
+		}
 	}
 
 	return allEntries, nil
 }
 
+// readSnapshot reads the snapshot entries and the sequence number they cover.
+// A missing snapshot yields no entries.
+func (wal *WAL) readSnapshot() ([]*WAL_Entry, uint64, error) {
+	entries, err := wal.readSegment(snapshotFile)
+	if isNotExist(err) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	if len(entries) == 0 {
+		return nil, 0, nil
+	}
+	return entries, entries[0].SequenceNumber, nil
+}
+
+// findLastSequenceNumber returns the highest sequence number written so far.
+// A freshly rotated segment is empty, so earlier segments and the snapshot are consulted too.
+func (wal *WAL) findLastSequenceNumber() (uint64, error) {
+	_, lastSequenceNumber, err := wal.readSnapshot()
+	if err != nil {
+		return 0, err
+	}
+
+	files, err := wal.storage.List(segmentPrefix)
+	if err != nil {
+		return 0, err
+	}
+
+	sortedFiles, err := sortSegmentFiles(files)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := len(sortedFiles) - 1; i >= 0; i-- {
+		sequenceNumber, _, err := scanSegment(wal.storage, sortedFiles[i])
+		if err != nil {
+			return 0, err
+		}
+		if sequenceNumber > 0 {
+			return max(sequenceNumber, lastSequenceNumber), nil
+		}
+	}
+
+	return lastSequenceNumber, nil
+}
+
 // readSegment reads all entries from a single segment file
-func (wal *WAL) readSegment(filePath string) ([]*WAL_Entry, error) {
-	file, err := os.Open(filePath)
+func (wal *WAL) readSegment(name string) ([]*WAL_Entry, error) {
+	return readRecords(wal.storage, name, wal.keys)
+}
+
+// readRecords reads the entries of a segment or snapshot file, unwrapping
+// envelopes with keys. Reading stops at the first torn or corrupt record,
+// but an intact record that cannot be decrypted is an error.
+func readRecords(storage Storage, name string, keys *KeyRing) ([]*WAL_Entry, error) {
+	reader, err := storage.OpenRead(name)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
+	defer reader.Close()
+	file := bufio.NewReader(reader)
 
 	var entries []*WAL_Entry
 
 	for {
-		var size int32
-		if err := binary.Read(file, binary.LittleEndian, &size); err != nil {
-			if err == io.EOF {
-				break
-			}
-			return nil, err
+		data, err := readRecord(file)
+		if err == io.EOF || err == io.ErrUnexpectedEOF || errors.Is(err, errInvalidRecordSize) {
+			// End of file, a record torn by a crash, or a corrupt size
+			break
 		}
-
-		// Read entry data
-		data := make([]byte, size)
-		if _, err := io.ReadFull(file, data); err != nil {
-			if err == io.EOF {
-				// Partial entry at end of file, skip it
-				break
-			}
+		if err != nil {
 			return nil, err
 		}
 
@@ -450,6 +753,11 @@ func (wal *WAL) readSegment(filePath string) ([]*WAL_Entry, error) {
 			// Invalid entry, stop reading this segment
 			break
 		}
+		if entry.Type == EntryTypeENVELOPE {
+			if entry, err = unwrapEnvelope(entry, keys); err != nil {
+				return nil, err
+			}
+		}
 
 		entries = append(entries, entry)
 	}
@@ -457,60 +765,61 @@ func (wal *WAL) readSegment(filePath string) ([]*WAL_Entry, error) {
 	return entries, nil
 }
 
-// getLastSequenceNumberFromFile reads the last sequence number from a segment file
-func getLastSequenceNumberFromFile(filePath string) (uint64, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return 0, nil
+// readRecord reads the data of the next size-prefixed record. It returns
+// io.EOF at the end of the file and io.ErrUnexpectedEOF for a record torn by
+// a crash. The data is read as it arrives, so a corrupt size cannot allocate
+// much more than the file holds.
+func readRecord(r io.Reader) ([]byte, error) {
+	var size int32
+	if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+		return nil, err
+	}
+	if size <= 0 || size > maxRecordSize {
+		return nil, fmt.Errorf("%w: %d", errInvalidRecordSize, size)
+	}
+
+	var data bytes.Buffer
+	if _, err := io.CopyN(&data, r, int64(size)); err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
 		}
-		return 0, err
+		return nil, err
 	}
-	defer file.Close()
+	return data.Bytes(), nil
+}
 
-	var previousSize int32
-	var offset int64
+// scanSegment returns the sequence number of the last record of a segment
+// file that readRecords would return, and whether a record torn by a crash
+// or otherwise unreadable follows it
+func scanSegment(storage Storage, name string) (uint64, bool, error) {
+	reader, err := storage.OpenRead(name)
+	if err != nil {
+		if isNotExist(err) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	defer reader.Close()
+	file := bufio.NewReader(reader)
 
+	var lastSequenceNumber uint64
 	for {
-		var size int32
-		if err := binary.Read(file, binary.LittleEndian, &size); err != nil {
-			if err == io.EOF {
-				// We've reached the end, read the last entry
-				if offset == 0 {
-					return 0, nil // Empty file
-				}
-
-				// Seek to the beginning of the last entry
-				if _, err := file.Seek(offset, io.SeekStart); err != nil {
-					return 0, err
-				}
-
-				// Read the entry data
-				data := make([]byte, previousSize)
-				if _, err := io.ReadFull(file, data); err != nil {
-					return 0, err
-				}
-
-				// Unmarshal entry to get sequence number
-				var entry WAL_Entry
-				MustUnmarshal(data, &entry)
-				return entry.SequenceNumber, nil
-			}
-			return 0, err
+		data, err := readRecord(file)
+		if err == io.EOF {
+			return lastSequenceNumber, false, nil
+		}
+		if err == io.ErrUnexpectedEOF || errors.Is(err, errInvalidRecordSize) {
+			return lastSequenceNumber, true, nil
 		}
-
-		// Save current offset before skipping
-		offset, err = file.Seek(0, io.SeekCurrent)
 		if err != nil {
-			return 0, err
+			return 0, false, err
 		}
 
-		previousSize = size
-
-		// Skip the entry data
-		if _, err := file.Seek(int64(size), io.SeekCurrent); err != nil {
-			return 0, err
+		entry, err := unMarshalAndVerifyEntry(data)
+		if err != nil {
+			return lastSequenceNumber, true, nil
 		}
+		lastSequenceNumber = entry.SequenceNumber
 	}
 }
 